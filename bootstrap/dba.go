@@ -3,79 +3,228 @@ package bootstrap
 import (
 	"fmt"
 	"strconv"
+	"time"
 
 	"github.com/blueprint/blueprint/dba"
 	"github.com/spf13/viper"
 )
 
-// DBA bootstraps the dba object and dials the specified databases.
+// DBA bootstraps the default dba object with the specified databases.
 func DBA() error {
+	return DBAWith(dba.Default())
+}
 
-	// get database configuration information
-	dbs := viper.GetStringMapString("databases")
-
-	for typ := range dbs {
+// DBAWith bootstraps a DatabaseAdmin with the specified databases.
+//
+// Databases are configured as a list, each with a unique name it is looked up
+// by, such as with dba.SQLNamed("analytics"), a type, and the database to
+// dial, which defaults to its name:
+//
+//	databases:
+//	  - name: primary
+//	    type: postgres
+//	    database: app
+//	    addr: db.internal
+//	  - name: analytics
+//	    type: postgres
+//	    addr: warehouse.internal
+//	  - name: sessions
+//	    type: redis
+//	    db: 2
+//
+// Or as a map with a single database per type, keyed by type, each named after
+// the database it dials.
+//
+// Besides their address and credentials, databases take the settings of
+// dba.Options: max_open_conns, max_idle_conns, conn_max_lifetime,
+// conn_max_idle_time, dial_timeout, read_timeout, write_timeout and
+// pool_timeout. Setting tls to true, or any of tls_ca, tls_cert, tls_key and
+// tls_server_name without tls, encrypts connections, and
+// tls_insecure_skip_verify turns off verification of the server.
+func DBAWith(admin *dba.DatabaseAdmin) error {
 
-		switch typ {
-		case "mongo", "mongodb":
-			db := viper.GetStringMapString("databases." + typ)
-			mongo, err := dba.NewMongoDB(
-				db["name"],
-				db["user"],
-				db["pass"],
-				db["addr"],
-			)
-			if err != nil {
-				return err
+	// get a list of named databases
+	if list, ok := viper.Get("databases").([]interface{}); ok {
+		for i, item := range list {
+			db := stringMap(item)
+			if db["type"] == "" {
+				return fmt.Errorf("missing type of database %d", i)
 			}
-			dba.AddMongoDB(mongo)
-
-		case "redis", "redisdb":
-			db := viper.GetStringMapString("databases." + typ)
-			var poolsize int
-			if db["pool_size"] != "" {
-				i, err := strconv.Atoi(db["pool_size"])
-				if err != nil {
-					return err
-				}
-				poolsize = i
+			if db["name"] == "" {
+				return fmt.Errorf("missing name of %s database %d", db["type"], i)
 			}
-			var dbint int64
-			if db["db"] != "" {
-				i, err := strconv.ParseInt(db["db"], 10, 64)
-				if err != nil {
-					return err
-				}
-				dbint = i
+			if err := addDatabase(admin, db["type"], db); err != nil {
+				return err
 			}
-			redis, err := dba.NewRedis(
-				dbint,
-				db["user"],
-				db["pass"],
-				db["addr"],
-				poolsize,
-			)
+		}
+		return nil
+	}
+
+	// get a database per type
+	for typ := range viper.GetStringMapString("databases") {
+		if err := addDatabase(admin, typ, viper.GetStringMapString("databases."+typ)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addDatabase adds a database of a type to a DatabaseAdmin.
+func addDatabase(admin *dba.DatabaseAdmin, typ string, db map[string]string) error {
+	options, err := dbaOptions(typ, db)
+	if err != nil {
+		return err
+	}
+	database := db["database"]
+	if database == "" {
+		database = db["name"]
+	}
+
+	switch typ {
+	case "mongo", "mongodb":
+		mongo, err := dba.NewMongoDB(
+			database,
+			db["user"],
+			db["pass"],
+			db["addr"],
+		)
+		if err != nil {
+			return err
+		}
+		if db["name"] != "" {
+			mongo.SetName(db["name"])
+		}
+		mongo.SetOptions(options)
+		return admin.Add(mongo)
+
+	case "redis", "redisdb":
+		var poolsize int
+		if db["pool_size"] != "" {
+			i, err := strconv.Atoi(db["pool_size"])
 			if err != nil {
 				return err
 			}
-			dba.AddRedisDB(redis)
-
-		case "mysql", "postgres", "sqlite", "sql":
-			db := viper.GetStringMapString("databases." + typ)
-			sql, err := dba.NewSQL(
-				db["name"],
-				db["user"],
-				db["pass"],
-				db["addr"],
-				typ,
-			)
+			poolsize = i
+		}
+		var dbint int64
+		if db["db"] != "" {
+			i, err := strconv.ParseInt(db["db"], 10, 64)
 			if err != nil {
 				return err
 			}
-			dba.AddSQL(sql)
-		default:
-			return fmt.Errorf("unsupported database %s", typ)
+			dbint = i
+		}
+		redis, err := dba.NewRedis(
+			dbint,
+			db["user"],
+			db["pass"],
+			db["addr"],
+			poolsize,
+		)
+		if err != nil {
+			return err
+		}
+		if db["name"] != "" {
+			redis.SetName(db["name"])
+		}
+		redis.SetOptions(options)
+		return admin.Add(redis)
+
+	case "mysql", "postgres", "sqlite", "sql":
+		sql, err := dba.NewSQL(
+			database,
+			db["user"],
+			db["pass"],
+			db["addr"],
+			typ,
+		)
+		if err != nil {
+			return err
+		}
+		if db["name"] != "" {
+			sql.SetName(db["name"])
 		}
+		sql.SetOptions(options)
+		return admin.Add(sql)
 	}
-	return nil
+	return fmt.Errorf("unsupported database %s", typ)
+}
+
+// stringMap converts a database of a configuration list to a map of strings.
+func stringMap(item interface{}) map[string]string {
+	m := map[string]string{}
+	switch item := item.(type) {
+	case map[string]interface{}:
+		for key, value := range item {
+			m[key] = fmt.Sprint(value)
+		}
+	case map[interface{}]interface{}:
+		for key, value := range item {
+			m[fmt.Sprint(key)] = fmt.Sprint(value)
+		}
+	}
+	return m
+}
+
+// dbaOptions parses the pool, timeout and TLS settings of a database.
+func dbaOptions(typ string, db map[string]string) (dba.Options, error) {
+	var options dba.Options
+
+	ints := map[string]*int{
+		"max_open_conns": &options.MaxOpenConns,
+		"max_idle_conns": &options.MaxIdleConns,
+	}
+	for key, v := range ints {
+		if db[key] == "" {
+			continue
+		}
+		i, err := strconv.Atoi(db[key])
+		if err != nil {
+			return options, fmt.Errorf("invalid %s of %s database: %s", key, typ, err)
+		}
+		*v = i
+	}
+
+	durations := map[string]*time.Duration{
+		"conn_max_lifetime":  &options.ConnMaxLifetime,
+		"conn_max_idle_time": &options.ConnMaxIdleTime,
+		"dial_timeout":       &options.DialTimeout,
+		"read_timeout":       &options.ReadTimeout,
+		"write_timeout":      &options.WriteTimeout,
+		"pool_timeout":       &options.PoolTimeout,
+	}
+	for key, v := range durations {
+		if db[key] == "" {
+			continue
+		}
+		d, err := time.ParseDuration(db[key])
+		if err != nil {
+			return options, fmt.Errorf("invalid %s of %s database: %s", key, typ, err)
+		}
+		*v = d
+	}
+
+	var enabled, insecure bool
+	for key, v := range map[string]*bool{"tls": &enabled, "tls_insecure_skip_verify": &insecure} {
+		if db[key] == "" {
+			continue
+		}
+		b, err := strconv.ParseBool(db[key])
+		if err != nil {
+			return options, fmt.Errorf("invalid %s of %s database: %s", key, typ, err)
+		}
+		*v = b
+	}
+
+	t := &dba.TLS{
+		CAFile:             db["tls_ca"],
+		CertFile:           db["tls_cert"],
+		KeyFile:            db["tls_key"],
+		ServerName:         db["tls_server_name"],
+		InsecureSkipVerify: insecure,
+	}
+	if enabled || (db["tls"] == "" && *t != (dba.TLS{})) {
+		options.TLS = t
+	}
+	return options, nil
 }
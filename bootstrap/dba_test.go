@@ -0,0 +1,172 @@
+package bootstrap
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/blueprint/blueprint/dba"
+	"github.com/spf13/viper"
+)
+
+func TestDBAOptions(t *testing.T) {
+	options, err := dbaOptions("postgres", map[string]string{
+		"max_open_conns":     "20",
+		"max_idle_conns":     "5",
+		"conn_max_lifetime":  "1h",
+		"conn_max_idle_time": "5m",
+		"dial_timeout":       "2s",
+		"read_timeout":       "3s",
+		"write_timeout":      "4s",
+		"pool_timeout":       "500ms",
+		"tls_ca":             "/etc/ca.pem",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := dba.Options{
+		MaxOpenConns:    20,
+		MaxIdleConns:    5,
+		ConnMaxLifetime: time.Hour,
+		ConnMaxIdleTime: 5 * time.Minute,
+		DialTimeout:     2 * time.Second,
+		ReadTimeout:     3 * time.Second,
+		WriteTimeout:    4 * time.Second,
+		PoolTimeout:     500 * time.Millisecond,
+		TLS:             &dba.TLS{CAFile: "/etc/ca.pem"},
+	}
+	if !reflect.DeepEqual(options, expected) {
+		t.Errorf("expected %+v, got %+v", expected, options)
+	}
+}
+
+func TestDBAOptionsTLS(t *testing.T) {
+	tests := []struct {
+		db  map[string]string
+		tls *dba.TLS
+	}{
+		{map[string]string{}, nil},
+		{map[string]string{"tls": "true"}, &dba.TLS{}},
+		{map[string]string{"tls_insecure_skip_verify": "true"}, &dba.TLS{InsecureSkipVerify: true}},
+		{map[string]string{"tls": "false", "tls_ca": "/etc/ca.pem"}, nil},
+		{map[string]string{"tls_server_name": "db.internal"}, &dba.TLS{ServerName: "db.internal"}},
+	}
+	for _, test := range tests {
+		options, err := dbaOptions("mysql", test.db)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(options.TLS, test.tls) {
+			t.Errorf("%v: expected tls %+v, got %+v", test.db, test.tls, options.TLS)
+		}
+	}
+}
+
+func TestDBAOptionsInvalid(t *testing.T) {
+	for _, db := range []map[string]string{
+		{"max_open_conns": "many"},
+		{"dial_timeout": "5"},
+		{"tls": "maybe"},
+	} {
+		if _, err := dbaOptions("redis", db); err == nil {
+			t.Errorf("expected %v to fail", db)
+		}
+	}
+}
+
+func TestDBAWithList(t *testing.T) {
+	defer viper.Reset()
+	viper.Set("databases", []interface{}{
+		map[string]interface{}{"name": "primary", "type": "postgres", "database": "app", "user": "admin", "pass": "secret", "addr": "db.internal"},
+		map[string]interface{}{"name": "analytics", "type": "postgres", "user": "admin", "pass": "secret", "addr": "warehouse.internal", "max_open_conns": 5},
+		map[interface{}]interface{}{"name": "sessions", "type": "redis", "addr": "cache.internal", "db": 2},
+	})
+
+	admin := dba.NewDatabaseAdmin()
+	if err := DBAWith(admin); err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, db := range admin.All() {
+		names = append(names, dba.System(db)+" "+db.Name())
+	}
+	expected := []string{"postgres primary", "postgres analytics", "redis sessions"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("expected databases %v, got %v", expected, names)
+	}
+
+	db, err := admin.Get("analytics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats := db.Stats(); stats.MaxOpenConns != 5 {
+		t.Errorf("expected the options of the analytics database, got %+v", stats)
+	}
+	if _, err := admin.SQLNamed("primary"); !errors.Is(err, dba.ErrNotDialed) {
+		t.Errorf("expected an undialed primary database, got %v", err)
+	}
+	if _, err := admin.RedisNamed("sessions"); !errors.Is(err, dba.ErrNotDialed) {
+		t.Errorf("expected an undialed sessions database, got %v", err)
+	}
+}
+
+func TestDBAWithMap(t *testing.T) {
+	defer viper.Reset()
+	viper.Set("databases", map[string]interface{}{
+		"postgres": map[string]interface{}{"name": "app", "user": "admin", "pass": "secret", "addr": "db.internal"},
+		"mongodb":  map[string]interface{}{"database": "docs", "user": "admin", "pass": "secret", "addr": "mongo.internal"},
+		"redis":    map[string]interface{}{"addr": "cache.internal", "db": 2},
+	})
+
+	admin := dba.NewDatabaseAdmin()
+	if err := DBAWith(admin); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := admin.SQLNamed("app"); !errors.Is(err, dba.ErrNotDialed) {
+		t.Errorf("expected a sql database named after the database it dials, got %v", err)
+	}
+	if _, err := admin.MongoNamed("docs"); !errors.Is(err, dba.ErrNotDialed) {
+		t.Errorf("expected a mongodb database named after the database it dials, got %v", err)
+	}
+	if _, err := admin.RedisNamed("2"); !errors.Is(err, dba.ErrNotDialed) {
+		t.Errorf("expected a redis database named after its number, got %v", err)
+	}
+}
+
+func TestDBAWithInvalid(t *testing.T) {
+	defer viper.Reset()
+
+	tests := []struct {
+		databases []interface{}
+		err       string
+	}{
+		{
+			[]interface{}{map[string]interface{}{"name": "primary", "addr": "db.internal"}},
+			"missing type of database 0",
+		},
+		{
+			[]interface{}{
+				map[string]interface{}{"name": "sessions", "type": "redis", "addr": "cache.internal"},
+				map[string]interface{}{"type": "redis", "addr": "cache.internal"},
+			},
+			"missing name of redis database 1",
+		},
+		{
+			[]interface{}{
+				map[string]interface{}{"name": "primary", "type": "postgres", "user": "admin", "pass": "secret", "addr": "db.internal"},
+				map[string]interface{}{"name": "primary", "type": "redis", "addr": "cache.internal"},
+			},
+			"duplicate database primary",
+		},
+	}
+	for _, test := range tests {
+		viper.Set("databases", test.databases)
+		err := DBAWith(dba.NewDatabaseAdmin())
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("expected %q, got %v", test.err, err)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package bootstrap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/blueprint/blueprint/dba"
+	"github.com/spf13/viper"
+)
+
+// MigrateOptions configure Migrate.
+type MigrateOptions struct {
+
+	// Database is the name of the sql database to migrate. It may be left
+	// empty if there is only one.
+	Database string
+
+	// Dir holds the .sql migrations, the "migrations" setting by default or
+	// "migrations" without one. A missing directory holds no migrations.
+	Dir string
+
+	// FS holds Dir, the working directory if nil. Set it to an embed.FS to
+	// build the migrations into the binary.
+	FS fs.FS
+
+	// Migrations are Go migrations, run along with those of Dir.
+	Migrations []dba.Migration
+
+	// DryRun writes the migrations that would run instead of running them.
+	DryRun bool
+
+	// Out receives the migrations as they run and the status, os.Stdout by
+	// default.
+	Out io.Writer
+}
+
+// Migrate bootstraps configuration and migrates a sql database of the
+// configured databases, which is the only one dialed. args are those of a
+// migrate command:
+//
+//	up [version]   apply pending migrations, up to version if given
+//	down [steps]   roll back the last applied migration, or the last steps
+//	status         list migrations and when they were applied
+//
+// up is run if args are empty.
+func Migrate(ctx context.Context, o MigrateOptions, args ...string) error {
+	command := "up"
+	if len(args) > 0 {
+		command = args[0]
+	}
+	if len(args) > 2 || (command == "status" && len(args) > 1) {
+		return fmt.Errorf("too many arguments to migrate %s", command)
+	}
+
+	var n int64 = -1
+	if len(args) == 2 {
+		var err error
+		if n, err = strconv.ParseInt(args[1], 10, 64); err != nil || n < 0 {
+			return fmt.Errorf("invalid argument %q to migrate %s", args[1], command)
+		}
+	}
+
+	// bootstrap environment and configuration settings
+	if err := Config(); err != nil {
+		return err
+	}
+
+	admin := dba.NewDatabaseAdmin()
+	if err := DBAWith(admin); err != nil {
+		return err
+	}
+	db, err := migrateDatabase(admin, o.Database)
+	if err != nil {
+		return err
+	}
+	if err := admin.Dial(db.Name()); err != nil {
+		return err
+	}
+	defer db.Close()
+
+	migrations, err := o.migrations()
+	if err != nil {
+		return err
+	}
+	m, err := db.Migrator(migrations...)
+	if err != nil {
+		return err
+	}
+	m.DryRun = o.DryRun
+	m.Log = o.Out
+	if m.Log == nil {
+		m.Log = os.Stdout
+	}
+
+	switch command {
+	case "up":
+		_, err = m.UpTo(ctx, n)
+	case "down":
+		if n < 0 {
+			n = 1
+		}
+		_, err = m.Down(ctx, int(n))
+	case "status":
+		err = migrateStatus(ctx, m.Log, m)
+	default:
+		err = fmt.Errorf("unknown migrate command %q, use up, down or status", command)
+	}
+	return err
+}
+
+// migrations returns the Go migrations and those of Dir.
+func (o MigrateOptions) migrations() ([]dba.Migration, error) {
+	dir := o.Dir
+	if len(dir) == 0 {
+		dir = viper.GetString("migrations")
+	}
+	if len(dir) == 0 {
+		dir = "migrations"
+	}
+
+	fsys := o.FS
+	if fsys == nil {
+		fsys = os.DirFS(".")
+	}
+
+	files, err := dba.LoadMigrations(fsys, dir)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	return append(append([]dba.Migration(nil), o.Migrations...), files...), nil
+}
+
+// migrateDatabase returns the named sql database of admin, or its only one if
+// name is empty.
+func migrateDatabase(admin *dba.DatabaseAdmin, name string) (*dba.SQL, error) {
+	if len(name) > 0 {
+		db, err := admin.Get(name)
+		if err != nil {
+			return nil, err
+		}
+		s, ok := db.(*dba.SQL)
+		if !ok {
+			return nil, fmt.Errorf("database %s is not a sql database", name)
+		}
+		return s, nil
+	}
+
+	var found *dba.SQL
+	for _, db := range admin.All() {
+		if s, ok := db.(*dba.SQL); ok {
+			if found != nil {
+				return nil, errors.New("more than one sql database, name the one to migrate")
+			}
+			found = s
+		}
+	}
+	if found == nil {
+		return nil, errors.New("no sql database to migrate")
+	}
+	return found, nil
+}
+
+// migrateStatus writes the status of migrations as a table.
+func migrateStatus(ctx context.Context, out io.Writer, m *dba.Migrator) error {
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tSTATUS\tAPPLIED AT")
+	for _, s := range statuses {
+		status, at := "pending", ""
+		if s.Applied {
+			status = "applied"
+			if !s.AppliedAt.IsZero() {
+				at = s.AppliedAt.Format("2006-01-02 15:04:05 MST")
+			}
+		}
+		if s.Unknown {
+			status = "unknown"
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", s.Version, s.Name, status, at)
+	}
+	return w.Flush()
+}
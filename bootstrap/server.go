@@ -1,20 +1,34 @@
 package bootstrap
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
 	"path/filepath"
+	"sort"
 
 	"github.com/blueprint/blueprint/dba"
 	"github.com/blueprint/blueprint/docs"
+	"github.com/blueprint/blueprint/health"
 	"github.com/blueprint/blueprint/http/middleware"
+	"github.com/blueprint/blueprint/metrics"
 	"github.com/blueprint/blueprint/router"
+	"github.com/blueprint/blueprint/trace"
+	transport "github.com/blueprint/blueprint/transport"
 	"github.com/spf13/viper"
 )
 
-// Server takes a register function and bootstraps a server.
+// Server takes a register function and bootstraps a server with the default
+// dba object.
 func Server(r router.Router, register func(r router.Router)) error {
+	return ServerWith(dba.Default(), r, register)
+}
+
+// ServerWith takes a DatabaseAdmin and a register function and bootstraps a
+// server with the databases of the admin. Requests carry the admin in their
+// context, where handlers and models get it with dba.AdminFrom.
+func ServerWith(admin *dba.DatabaseAdmin, r router.Router, register func(r router.Router)) error {
 
 	// bootstrap environment and configuration settings
 	if err := Config(); err != nil {
@@ -22,45 +36,83 @@ func Server(r router.Router, register func(r router.Router)) error {
 	}
 
 	// bootstrap database admin
-	if err := DBA(); err != nil {
+	if err := DBAWith(admin); err != nil {
 		return err
 	}
 
+	// defer closing dba connections, including those opened before a dial
+	// or a later step fails
+	defer admin.Close()
+
 	// open all db connections
-	for _, db := range dba.All() {
-		if err := db.Dial(db.Name()); err != nil {
-			return err
-		}
+	if err := admin.Dial(); err != nil {
+		return err
+	}
+
+	// pass the database admin to handlers
+	r.Middleware(admin.Middleware)
+
+	// trace requests, continuing the traces of callers
+	exporter, err := tracing()
+	if err != nil {
+		return err
+	}
+	if exporter != nil {
+		defer exporter.Close()
+		r.Middleware(router.Trace(trace.DefaultTracer))
+	}
+
+	// record request metrics, served without api keys so scrapers need none
+	if path := viper.GetString("metrics"); len(path) > 0 {
+		m := metrics.New()
+		m.Path = path
+		m.Databases = admin.All
+		r.Middleware(m.Middleware)
+		m.Register(r.FlushMiddleware(""))
 	}
 
-	// defer closing dba connections
-	for _, db := range dba.All() {
-		defer db.Close()
+	// answer liveness and readiness probes, without api keys
+	if timeout := viper.GetDuration("health_timeout"); timeout > 0 {
+		health.Default.Timeout = timeout
 	}
+	health.Default.Register(r.FlushMiddleware("").Middleware(admin.Middleware))
 
-	// set api keys as middleware
+	// set api keys as middleware, privileged keys may purge trashed items
 	keys := viper.GetStringMapStringSlice("keys")
 	if len(keys) > 0 {
-		km := middleware.NewKeys(keys)
+		km := middleware.NewKeys(keys, viper.GetStringSlice("privileged_keys")...)
 		r.Middleware(km.Do)
 	}
 
+	// give every request a deadline, except streams and websockets
+	if timeout := viper.GetDuration("timeout"); timeout > 0 {
+		r.Middleware(router.Timeout(timeout))
+	}
+
+	// limit and harden request bodies
+	if limit := viper.GetInt64("body_limit"); limit > 0 {
+		r.Middleware(router.BodyLimit(limit))
+	}
+	decoding()
+
 	register(r)
 
 	port := viper.GetString("port")
 	static := viper.GetString("static")
 	tls := viper.GetStringMapString("tls")
 
-	// if a static directory path is provided, register it
-	if len(static) > 0 {
-		r.Static("/public", static)
-	} else {
-		r.Static("/public", string(os.PathSeparator)+"public")
+	// resolve and register the public static directory, if there is one
+	dir, err := StaticDir(static)
+	if err != nil {
+		return err
 	}
+	if len(dir) > 0 {
+		r.Static("/public", os.DirFS(dir), staticOptions()...)
 
-	// generate docs
-	if err := Docs(static, router.Endpoints()); err != nil {
-		return err
+		// generate docs
+		if err := Docs(dir, router.Endpoints()); err != nil {
+			return err
+		}
 	}
 
 	// prep port and green output
@@ -76,6 +128,102 @@ func Server(r router.Router, register func(r router.Router)) error {
 	return http.ListenAndServe(portStr, r)
 }
 
+// StaticDir resolves the public static directory. If dir does not exist it
+// is assumed to be relative to the binary executing. If dir is empty "public"
+// is used, and if neither exists StaticDir returns no directory and no error,
+// since static files are optional unless a directory is set.
+func StaticDir(dir string) (string, error) {
+	optional := len(dir) == 0
+	if optional {
+		dir = "public"
+	}
+
+	// check directory path is valid
+	if _, err := os.Stat(dir); err == nil {
+		return dir, nil
+	}
+
+	current, err := filepath.Abs(filepath.Dir(os.Args[0]))
+	if err != nil {
+		return "", err
+	}
+
+	rel := filepath.Join(current, dir)
+	if _, err := os.Stat(rel); err != nil {
+		if optional {
+			return "", nil
+		}
+		return "", fmt.Errorf("static directory %s not found", dir)
+	}
+	return rel, nil
+}
+
+// staticOptions reads static file serving options from configuration.
+//
+// static_spa enables single page application fallbacks, and static_cache maps
+// file patterns to Cache-Control values. Patterns are applied in sorted order
+// since configuration maps have none.
+func staticOptions() []router.StaticOption {
+	var options []router.StaticOption
+	if viper.GetBool("static_spa") {
+		options = append(options, router.SPA())
+	}
+
+	cache := viper.GetStringMapString("static_cache")
+	patterns := make([]string, 0, len(cache))
+	for pattern := range cache {
+		patterns = append(patterns, pattern)
+	}
+	sort.Strings(patterns)
+
+	for _, pattern := range patterns {
+		options = append(options, router.CacheControl(pattern, cache[pattern]))
+	}
+	return options
+}
+
+// decoding reads how request bodies are decoded from configuration.
+//
+// json_unknown_fields accepts fields that models do not have, and
+// json_max_depth limits how deeply JSON bodies may nest.
+func decoding() {
+	if viper.IsSet("json_unknown_fields") {
+		transport.DefaultDecoder.AllowUnknownFields = viper.GetBool("json_unknown_fields")
+	}
+	if viper.IsSet("json_max_depth") {
+		transport.DefaultDecoder.MaxDepth = viper.GetInt("json_max_depth")
+	}
+}
+
+// tracing sets the exporter of the default tracer from the "tracing"
+// settings, and returns it. The exporter writes spans to stdout, or to the
+// file of the "file" setting, and is nil if "exporter" is not set.
+func tracing() (*trace.WriterExporter, error) {
+	settings := viper.GetStringMapString("tracing")
+	trace.DefaultTracer.Service = settings["service"]
+
+	var exporter *trace.WriterExporter
+	switch settings["exporter"] {
+	case "":
+		return nil, nil
+	case "stdout":
+		exporter = trace.NewWriterExporter(os.Stdout)
+	case "file":
+		if len(settings["file"]) == 0 {
+			return nil, errors.New("missing tracing file")
+		}
+		var err error
+		if exporter, err = trace.NewFileExporter(settings["file"]); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("tracing exporter %s is not supported", settings["exporter"])
+	}
+
+	trace.DefaultTracer.Exporter = exporter
+	return exporter, nil
+}
+
 // Docs renders all the endpoint docs for the API application service.
 func Docs(static string, endpoints []router.Endpoint) error {
 	tmpl := filepath.Join(filepath.Dir(static), "templates", "endpoints.tmpl")
@@ -0,0 +1,49 @@
+package bootstrap
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/blueprint/blueprint/dba"
+	"github.com/blueprint/blueprint/router"
+	"github.com/spf13/viper"
+)
+
+// closer is a database that records whether it was dialed and closed.
+type closer struct {
+	name           string
+	fail           error
+	dialed, closed bool
+}
+
+func (c *closer) Dial(string) error {
+	if c.fail != nil {
+		return c.fail
+	}
+	c.dialed = true
+	return nil
+}
+
+func (c *closer) Name() string                   { return c.name }
+func (c *closer) Ping(ctx context.Context) error { return nil }
+func (c *closer) Stats() dba.Stats               { return dba.Stats{} }
+func (c *closer) Close()                         { c.closed = true }
+
+func TestServerWithDialFailure(t *testing.T) {
+	defer viper.Reset()
+	primary := &closer{name: "primary"}
+	replica := &closer{name: "replica", fail: errors.New("unreachable")}
+	admin := dba.NewDatabaseAdmin()
+	admin.SQL = []dba.Database{primary, replica}
+
+	err := ServerWith(admin, router.NewMux(), func(router.Router) {
+		t.Error("expected the server not to register routes")
+	})
+	if err == nil {
+		t.Fatal("expected the dial to fail")
+	}
+	if !primary.dialed || !primary.closed {
+		t.Errorf("expected the dialed primary database to be closed, got %+v", primary)
+	}
+}
@@ -1,8 +1,12 @@
 package blueprint
 
-// Database describes a methods to connect and close database connections.
+import "context"
+
+// Database describes a methods to connect, check and close database
+// connections.
 type Database interface {
 	Dial(name string) error
 	Name() string
+	Ping(ctx context.Context) error
 	Close()
 }
@@ -1,10 +1,16 @@
-// Package dba is an singleton that manages databases as would a Database
-// Administrator.
+// Package dba manages databases as would a Database Administrator. A
+// DatabaseAdmin keeps the databases of a server, and the package functions
+// use a default, singleton DatabaseAdmin.
 package dba
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"strconv"
+	"sync"
 
+	http "github.com/blueprint/blueprint/transport"
 	"gopkg.in/gorp.v1"
 	"gopkg.in/mgo.v2"
 	"gopkg.in/redis.v3"
@@ -12,37 +18,70 @@ import (
 
 // admin is a database administrator object that manages different SQL and NoSQL
 // databases. The admin object follows the singleton pattern in the db package
-// so that admin is easy to access across a project.
+// so that admin is easy to access across a project. It is the default, the
+// package functions use it, and servers and tests may keep a DatabaseAdmin of
+// their own instead.
 var admin *DatabaseAdmin
 
 func init() {
-	admin = &DatabaseAdmin{}
+	admin = NewDatabaseAdmin()
 }
 
-// Database describes a methods to connect and close database connections.
+// Default returns the DatabaseAdmin the package functions use.
+func Default() *DatabaseAdmin {
+	return admin
+}
+
+// Errors of database lookups.
+var (
+	ErrUnknownDatabase = errors.New("unknown database")
+	ErrNotDialed       = errors.New("undialed database")
+)
+
+// Database describes a methods to connect, check and close database
+// connections. Ping checks that a dialed database is reachable, giving up
+// once ctx is done, and Stats describe its connection pool.
 type Database interface {
 	Dial(name string) error
 	Name() string
+	Ping(ctx context.Context) error
+	Stats() Stats
 	Close()
 }
 
 // DatabaseAdmin represents a database administrator object who's duty is to
 // maintain all database values, connections, and state.
+//
+// The methods of a DatabaseAdmin are safe for concurrent use. The database
+// fields are kept for compatibility, and must not be changed while the
+// DatabaseAdmin is in use.
 type DatabaseAdmin struct {
 	Mongo []Database
 	Redis []Database
 	SQL   []Database
+
+	// mu guards the databases
+	mu sync.RWMutex
+}
+
+// NewDatabaseAdmin returns an empty DatabaseAdmin, for servers or tests that
+// keep databases of their own rather than those of the package.
+func NewDatabaseAdmin() *DatabaseAdmin {
+	return &DatabaseAdmin{}
 }
 
 // MGO takes a database name to search all mongodb databases db.DBA currently
 // maintains and returns a pointer to a mgo.Database instance if found.
 // mgo.Database represents a mongodb orm-ish driver for executing queries.
 // If no database is found nil is returned.
-func (d DatabaseAdmin) MGO(name string) *mgo.Database {
+func (d *DatabaseAdmin) MGO(name string) *mgo.Database {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
 	for _, db := range d.Mongo {
 		if db.Name() == name {
 			if m, ok := db.(*MongoDB); ok {
-				return m.mongodb
+				return m.dialed()
 			}
 			return nil
 		}
@@ -52,21 +91,25 @@ func (d DatabaseAdmin) MGO(name string) *mgo.Database {
 
 // AddMongoDB adds a MongoDB database object to the DatabaseAdmin databases.
 func (d *DatabaseAdmin) AddMongoDB(m *MongoDB) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
 	d.Mongo = append(d.Mongo, m)
 }
 
-// RedisClient takes a database name to search all redis databases db.DBA
+// RedisClient takes a database number to search all redis databases db.DBA
 // currently maintains and returns a pointer to a redis.Client instance if
 // found. redis.Client represents a redis driver for executing queries.
 // If no database is found nil is returned.
-func (d DatabaseAdmin) RedisClient(db int64) *redis.Client {
-	name := strconv.FormatInt(db, 10)
-	for _, db := range d.Redis {
-		if db.Name() == name {
-			if r, ok := db.(*RedisDB); ok {
-				return r.client
-			}
-			return nil
+//
+// Deprecated: databases are looked up by name, and several may share a
+// number on different servers. Use RedisNamed.
+func (d *DatabaseAdmin) RedisClient(db int64) *redis.Client {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	for _, rdb := range d.Redis {
+		if r, ok := rdb.(*RedisDB); ok && r.db == db {
+			return r.Client()
 		}
 	}
 	return nil
@@ -74,7 +117,8 @@ func (d DatabaseAdmin) RedisClient(db int64) *redis.Client {
 
 // AddRedisDB adds a Redis database object to the DatabaseAdmin databases.
 func (d *DatabaseAdmin) AddRedisDB(r *RedisDB) {
-	r.db = int64(len(d.Redis))
+	d.mu.Lock()
+	defer d.mu.Unlock()
 	d.Redis = append(d.Redis, r)
 }
 
@@ -82,11 +126,14 @@ func (d *DatabaseAdmin) AddRedisDB(r *RedisDB) {
 // currently maintains and returns a pointer to a gorp.DbMap instance if found.
 // gorp.DbMap represents an SQL orm-ish driver for multiple different SQL
 // databases (sqlite, mysql, postgres). If no database is found nil is returned.
-func (d DatabaseAdmin) GORP(name string) *gorp.DbMap {
+func (d *DatabaseAdmin) GORP(name string) *gorp.DbMap {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
 	for _, db := range d.SQL {
 		if db.Name() == name {
 			if m, ok := db.(*SQL); ok {
-				return m.sql
+				return m.dialed()
 			}
 			return nil
 		}
@@ -96,16 +143,226 @@ func (d DatabaseAdmin) GORP(name string) *gorp.DbMap {
 
 // AddSQL adds a sql database object to the DatabaseAdmin databases.
 func (d *DatabaseAdmin) AddSQL(s *SQL) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
 	d.SQL = append(d.SQL, s)
 }
 
 // All returns all the databases this DatabaseAdmin maintains.
 func (d *DatabaseAdmin) All() []Database {
-	dbs := append(d.Mongo, d.SQL...)
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.all()
+}
+
+// all returns all the databases. The caller must hold mu.
+func (d *DatabaseAdmin) all() []Database {
+	dbs := append(append([]Database(nil), d.Mongo...), d.SQL...)
 	dbs = append(dbs, d.Redis...)
 	return dbs
 }
 
+// Add adds a MongoDB, RedisDB or SQL database object to the DatabaseAdmin
+// databases. Names are unique across all types of databases, so a database
+// named like one added before is rejected.
+func (d *DatabaseAdmin) Add(db Database) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if existing, err := d.get(db.Name()); err == nil {
+		return fmt.Errorf("duplicate database %s, already used by a %s database", db.Name(), System(existing))
+	}
+
+	switch db := db.(type) {
+	case *MongoDB:
+		d.Mongo = append(d.Mongo, db)
+	case *RedisDB:
+		d.Redis = append(d.Redis, db)
+	case *SQL:
+		d.SQL = append(d.SQL, db)
+	default:
+		return fmt.Errorf("unsupported database %T", db)
+	}
+	return nil
+}
+
+// Get returns the database named name, of any type, or ErrUnknownDatabase.
+func (d *DatabaseAdmin) Get(name string) (Database, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.get(name)
+}
+
+// get returns the database named name. The caller must hold mu.
+func (d *DatabaseAdmin) get(name string) (Database, error) {
+	for _, db := range d.all() {
+		if db.Name() == name {
+			return db, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnknownDatabase, name)
+}
+
+// MongoNamed returns the mgo.Database of the mongodb database named name. It
+// fails with ErrUnknownDatabase if there is no such database, and with
+// ErrNotDialed if it has not been dialed.
+func (d *DatabaseAdmin) MongoNamed(name string) (*mgo.Database, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	db, err := d.named(name, "mongodb")
+	if err != nil {
+		return nil, err
+	}
+	if m := db.(*MongoDB).dialed(); m != nil {
+		return m, nil
+	}
+	return nil, fmt.Errorf("%w: mongodb %s", ErrNotDialed, name)
+}
+
+// RedisNamed returns the redis.Client of the redis database named name. It
+// fails with ErrUnknownDatabase if there is no such database, and with
+// ErrNotDialed if it has not been dialed.
+func (d *DatabaseAdmin) RedisNamed(name string) (*redis.Client, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	db, err := d.named(name, "redis")
+	if err != nil {
+		return nil, err
+	}
+	if client := db.(*RedisDB).Client(); client != nil {
+		return client, nil
+	}
+	return nil, fmt.Errorf("%w: redis %s", ErrNotDialed, name)
+}
+
+// SQLNamed returns the gorp.DbMap of the sql database named name. It fails
+// with ErrUnknownDatabase if there is no such database, and with
+// ErrNotDialed if it has not been dialed.
+func (d *DatabaseAdmin) SQLNamed(name string) (*gorp.DbMap, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	db, err := d.named(name, "sql")
+	if err != nil {
+		return nil, err
+	}
+	if dbmap := db.(*SQL).dialed(); dbmap != nil {
+		return dbmap, nil
+	}
+	return nil, fmt.Errorf("%w: sql %s", ErrNotDialed, name)
+}
+
+// named returns the database named name if it is of the kind asked for. The
+// caller must hold mu.
+func (d *DatabaseAdmin) named(name, kind string) (Database, error) {
+	db, err := d.get(name)
+	if err != nil {
+		return nil, fmt.Errorf("%w: no %s database named %s", ErrUnknownDatabase, kind, name)
+	}
+
+	var ok bool
+	switch kind {
+	case "mongodb":
+		_, ok = db.(*MongoDB)
+	case "redis":
+		_, ok = db.(*RedisDB)
+	case "sql":
+		_, ok = db.(*SQL)
+	}
+	if !ok {
+		return nil, fmt.Errorf("%w: %s is a %s database, not %s", ErrUnknownDatabase, name, System(db), kind)
+	}
+	return db, nil
+}
+
+// Dial dials the named databases, or every database if none are named, with
+// the database each was created for. It returns the first error along with
+// the name of the database that failed. Each dial is traced in a span of its
+// own. Databases are dialed without holding up lookups, which fail with
+// ErrNotDialed until their database is dialed.
+func (d *DatabaseAdmin) Dial(names ...string) error {
+	dbs, err := d.dialing(names)
+	if err != nil {
+		return err
+	}
+
+	for _, db := range dbs {
+		err := Do(context.Background(), db, "dial", func(context.Context) error {
+			switch db := db.(type) {
+			case *MongoDB:
+				return db.Dial(db.database)
+			case *RedisDB:
+				return db.Dial(strconv.FormatInt(db.db, 10))
+			case *SQL:
+				return db.Dial(db.database)
+			}
+			return db.Dial(db.Name())
+		})
+		if err != nil {
+			return fmt.Errorf("dialing database %s: %w", db.Name(), err)
+		}
+	}
+	return nil
+}
+
+// dialing returns the named databases, or every database if none are named.
+func (d *DatabaseAdmin) dialing(names []string) ([]Database, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	if len(names) == 0 {
+		return d.all(), nil
+	}
+	dbs := make([]Database, 0, len(names))
+	for _, name := range names {
+		db, err := d.get(name)
+		if err != nil {
+			return nil, err
+		}
+		dbs = append(dbs, db)
+	}
+	return dbs, nil
+}
+
+// Close closes every dialed database. Databases that were not dialed are
+// left as they are.
+func (d *DatabaseAdmin) Close() {
+	for _, db := range d.All() {
+		db.Close()
+	}
+}
+
+// Middleware puts the DatabaseAdmin in the context of requests, where
+// handlers and the models they call get it with AdminFrom. It satisfies the
+// router.Middleware type.
+func (d *DatabaseAdmin) Middleware(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		req.SetContext(WithAdmin(req.Context(), d))
+		h.ServeHTTP(resp, req)
+	})
+}
+
+// contextKey is the type of the keys context values are stored under.
+type contextKey int
+
+const adminContextKey contextKey = iota
+
+// WithAdmin returns a copy of a context holding a DatabaseAdmin.
+func WithAdmin(ctx context.Context, d *DatabaseAdmin) context.Context {
+	return context.WithValue(ctx, adminContextKey, d)
+}
+
+// AdminFrom returns the DatabaseAdmin held by a context, or the Default one
+// if it holds none.
+func AdminFrom(ctx context.Context) *DatabaseAdmin {
+	if d, ok := ctx.Value(adminContextKey).(*DatabaseAdmin); ok && d != nil {
+		return d
+	}
+	return admin
+}
+
 // MGO takes a database name to search all mongodb databases the db package
 // currently maintains and returns a pointer to a mgo.Database instance if
 // found. mgo.Database represents a mongodb orm-ish driver for executing queries.
@@ -123,6 +380,8 @@ func AddMongoDB(m *MongoDB) {
 // currently maintains and returns a pointer to a redis.Client instance if
 // found. redis.Client represents a RedisClient client for executing queries.
 // If no database is found nil is returned.
+//
+// Deprecated: use RedisNamed.
 func RedisClient(db int64) *redis.Client {
 	return admin.RedisClient(db)
 }
@@ -149,3 +408,100 @@ func AddSQL(s *SQL) {
 func All() []Database {
 	return admin.All()
 }
+
+// Add adds a database object to the singleton DatabaseAdmin's databases,
+// rejecting names already in use.
+func Add(db Database) error {
+	return admin.Add(db)
+}
+
+// Get returns the database the singleton DatabaseAdmin maintains by name.
+func Get(name string) (Database, error) {
+	return admin.Get(name)
+}
+
+// MongoNamed returns the mgo.Database of the mongodb database the singleton
+// DatabaseAdmin maintains by name.
+func MongoNamed(name string) (*mgo.Database, error) {
+	return admin.MongoNamed(name)
+}
+
+// RedisNamed returns the redis.Client of the redis database the singleton
+// DatabaseAdmin maintains by name.
+func RedisNamed(name string) (*redis.Client, error) {
+	return admin.RedisNamed(name)
+}
+
+// SQLNamed returns the gorp.DbMap of the sql database the singleton
+// DatabaseAdmin maintains by name.
+func SQLNamed(name string) (*gorp.DbMap, error) {
+	return admin.SQLNamed(name)
+}
+
+// Dial dials the named databases of the singleton DatabaseAdmin, or all of
+// them if none are named.
+func Dial(names ...string) error {
+	return admin.Dial(names...)
+}
+
+// Close closes every dialed database of the singleton DatabaseAdmin.
+func Close() {
+	admin.Close()
+}
+
+// pinger pings a database whose driver takes no context, one ping at a
+// time. Pings made while one is in flight share its result rather than
+// starting another, so probes of an unreachable database do not pile up
+// goroutines. The zero value is ready to use.
+type pinger struct {
+	mu     sync.Mutex
+	flight *flight
+}
+
+// flight is a ping in flight. err is set before done is closed.
+type flight struct {
+	done chan struct{}
+	err  error
+}
+
+// wait calls ping, unless a ping is already in flight, and waits for it to
+// return, or for ctx to be done. The ping carries on in the background after
+// ctx is done, and later calls wait for it.
+func (p *pinger) wait(ctx context.Context, ping func() error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	f := p.flight
+	if f == nil {
+		f = &flight{done: make(chan struct{})}
+		p.flight = f
+		go func() {
+			f.err = ping()
+			p.mu.Lock()
+			if p.flight == f {
+				p.flight = nil
+			}
+			p.mu.Unlock()
+			close(f.done)
+		}()
+	}
+	p.mu.Unlock()
+
+	select {
+	case <-f.done:
+		return f.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// reset forgets the ping in flight, so the next call to wait pings again.
+// Databases reset their pinger when Dial replaces the client, so a ping of
+// the old client still in flight is not shared by pings of the new one.
+func (p *pinger) reset() {
+	p.mu.Lock()
+	p.flight = nil
+	p.mu.Unlock()
+}
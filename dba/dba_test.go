@@ -0,0 +1,210 @@
+package dba
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gopkg.in/gorp.v1"
+)
+
+func TestPingerShared(t *testing.T) {
+	var p pinger
+	var calls int64
+	release := make(chan struct{})
+	ping := func() error {
+		atomic.AddInt64(&calls, 1)
+		<-release
+		return errors.New("unreachable")
+	}
+
+	// probes that give up leave the ping in flight
+	for i := 0; i < 3; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+		if err := p.wait(ctx, ping); !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("expected the probe to time out, got %v", err)
+		}
+		cancel()
+	}
+
+	if n := atomic.LoadInt64(&calls); n != 1 {
+		t.Fatalf("expected 1 ping in flight, got %d", n)
+	}
+
+	// a waiting probe gets its result
+	done := make(chan error)
+	go func() { done <- p.wait(context.Background(), ping) }()
+	close(release)
+	if err := <-done; err == nil || err.Error() != "unreachable" {
+		t.Errorf("expected the ping error, got %v", err)
+	}
+
+	// the next probe pings again
+	if err := p.wait(context.Background(), func() error { return nil }); err != nil {
+		t.Errorf("expected a new ping to succeed, got %v", err)
+	}
+}
+
+func TestAdd(t *testing.T) {
+	d := NewDatabaseAdmin()
+	primary, err := NewSQL("app", "admin", "secret", "db.internal", "postgres")
+	if err != nil {
+		t.Fatal(err)
+	}
+	primary.SetName("primary")
+	if err := d.Add(primary); err != nil {
+		t.Fatal(err)
+	}
+
+	sessions, err := NewRedis(2, "", "", "cache.internal", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sessions.SetName("primary")
+	if err := d.Add(sessions); err == nil {
+		t.Error("expected a database named like another to be rejected")
+	}
+	if len(d.All()) != 1 {
+		t.Errorf("expected 1 database, got %d", len(d.All()))
+	}
+
+	sessions.SetName("sessions")
+	if err := d.Add(sessions); err != nil {
+		t.Fatal(err)
+	}
+	if db, err := d.Get("sessions"); err != nil || db != sessions {
+		t.Errorf("expected the sessions database, got %v %v", db, err)
+	}
+	if _, err := d.Get("missing"); !errors.Is(err, ErrUnknownDatabase) {
+		t.Errorf("expected an unknown database, got %v", err)
+	}
+}
+
+func TestNamed(t *testing.T) {
+	d := NewDatabaseAdmin()
+	primary, err := NewSQL("app", "admin", "secret", "db.internal", "postgres")
+	if err != nil {
+		t.Fatal(err)
+	}
+	primary.SetName("primary")
+	sessions, err := NewRedis(2, "", "", "cache.internal", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sessions.SetName("sessions")
+	docs, err := NewMongoDB("docs", "admin", "secret", "mongo.internal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, db := range []Database{primary, sessions, docs} {
+		if err := d.Add(db); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// undialed databases
+	if _, err := d.SQLNamed("primary"); !errors.Is(err, ErrNotDialed) {
+		t.Errorf("expected an undialed sql database, got %v", err)
+	}
+	if _, err := d.RedisNamed("sessions"); !errors.Is(err, ErrNotDialed) {
+		t.Errorf("expected an undialed redis database, got %v", err)
+	}
+	if _, err := d.MongoNamed("docs"); !errors.Is(err, ErrNotDialed) {
+		t.Errorf("expected an undialed mongodb database, got %v", err)
+	}
+
+	// unknown names, and names of another type of database
+	if _, err := d.SQLNamed("missing"); !errors.Is(err, ErrUnknownDatabase) {
+		t.Errorf("expected an unknown sql database, got %v", err)
+	}
+	if _, err := d.SQLNamed("sessions"); !errors.Is(err, ErrUnknownDatabase) {
+		t.Errorf("expected a redis database to be unknown as sql, got %v", err)
+	}
+	if _, err := d.RedisNamed("docs"); !errors.Is(err, ErrUnknownDatabase) {
+		t.Errorf("expected a mongodb database to be unknown as redis, got %v", err)
+	}
+	if _, err := d.MongoNamed("primary"); !errors.Is(err, ErrUnknownDatabase) {
+		t.Errorf("expected a sql database to be unknown as mongodb, got %v", err)
+	}
+
+	// dialed databases
+	dbmap := &gorp.DbMap{}
+	primary.mu.Lock()
+	primary.sql = dbmap
+	primary.mu.Unlock()
+	if db, err := d.SQLNamed("primary"); err != nil || db != dbmap {
+		t.Errorf("expected the dialed sql database, got %v %v", db, err)
+	}
+}
+
+func TestDialConcurrent(t *testing.T) {
+	d := NewDatabaseAdmin()
+	primary, err := NewSQL("app", "admin", "secret", "127.0.0.1", "postgres")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Add(primary); err != nil {
+		t.Fatal(err)
+	}
+
+	// lookups and stats run while the database is dialed
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				if _, err := d.SQLNamed("app"); err != nil && !errors.Is(err, ErrNotDialed) {
+					t.Error(err)
+					return
+				}
+				primary.Stats()
+				d.GORP("app")
+			}
+		}()
+	}
+	if err := d.Dial(); err != nil {
+		t.Fatal(err)
+	}
+	wg.Wait()
+	defer d.Close()
+
+	if dbmap, err := d.SQLNamed("app"); err != nil || dbmap == nil {
+		t.Errorf("expected the dialed database, got %v %v", dbmap, err)
+	}
+}
+
+func TestCloseUndialed(t *testing.T) {
+	d := NewDatabaseAdmin()
+	primary, err := NewSQL("app", "admin", "secret", "127.0.0.1", "postgres")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sessions, err := NewRedis(2, "", "", "127.0.0.1:6379", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	docs, err := NewMongoDB("docs", "admin", "secret", "127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, db := range []Database{primary, sessions, docs} {
+		if err := d.Add(db); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// only the sql database is dialed, which needs no server
+	if err := d.Dial("app"); err != nil {
+		t.Fatal(err)
+	}
+	d.Close()
+	d.Close()
+
+	if _, err := d.SQLNamed("app"); !errors.Is(err, ErrNotDialed) {
+		t.Errorf("expected a closed database to be undialed, got %v", err)
+	}
+}
@@ -1,9 +1,13 @@
 package dba
 
 import (
+	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"log"
+	"net"
+	"sync"
 
 	"gopkg.in/mgo.v2"
 	"gopkg.in/mgo.v2/bson"
@@ -11,11 +15,17 @@ import (
 
 // MongoDB describes a mongodb database object.
 type MongoDB struct {
+	name     string
+	database string
+	user     string
+	pass     string
+	addr     string
+	options  Options
+	pings    pinger
+
+	// mu guards mongodb, which Dial sets while others may read it
+	mu      sync.RWMutex
 	mongodb *mgo.Database
-	name    string
-	user    string
-	pass    string
-	addr    string
 }
 
 // NewMongoDB creates a new mongodb database object.
@@ -29,11 +39,24 @@ func NewMongoDB(name, user, pass, addr string) (*MongoDB, error) {
 	if addr == "" {
 		return nil, errors.New("missing mongodb address")
 	}
-	return &MongoDB{name: name, user: user, pass: pass, addr: addr}, nil
+	return &MongoDB{name: name, database: name, user: user, pass: pass, addr: addr}, nil
+}
+
+// SetOptions configures the connection pool, timeouts and TLS of the mongodb
+// database object. It must be called before Dial.
+func (m *MongoDB) SetOptions(o Options) {
+	m.options = o
+}
+
+// SetName names the mongodb database object, such as "analytics", for lookups
+// in a DatabaseAdmin. The object is named after the database it dials
+// otherwise.
+func (m *MongoDB) SetName(name string) {
+	m.name = name
 }
 
 // Name satisfies the Database interface.  The mongodb database object returns
-// the name of the last database the Dial method attempted to connect with.
+// the name it is looked up by.
 func (m *MongoDB) Name() string {
 	return m.name
 }
@@ -56,30 +79,90 @@ func (m *MongoDB) Dial(name string) error {
 		return err
 	}
 
+	dialInfo.Timeout = m.options.DialTimeout
+	dialInfo.PoolLimit = m.options.MaxOpenConns
+	if m.options.TLS != nil {
+		config, err := m.options.TLS.Config()
+		if err != nil {
+			return err
+		}
+		dialer := &net.Dialer{Timeout: m.options.DialTimeout}
+		dialInfo.DialServer = func(addr *mgo.ServerAddr) (net.Conn, error) {
+			return tls.DialWithDialer(dialer, "tcp", addr.String(), config)
+		}
+	}
+
 	// dial and establish database session
 	s, err := mgo.DialWithInfo(dialInfo)
 	if err != nil {
 		return err
 	}
+	timeout := m.options.ReadTimeout
+	if m.options.WriteTimeout > timeout {
+		timeout = m.options.WriteTimeout
+	}
+	if timeout > 0 {
+		s.SetSocketTimeout(timeout)
+	}
 
 	// set db session and check the connection is not nil
-	m.mongodb = s.DB(name)
-	if m.mongodb == nil {
+	db := s.DB(name)
+	if db == nil {
 		return fmt.Errorf("error connection to mongodb database %s", name)
 	}
 
+	m.mu.Lock()
+	m.mongodb = db
+	m.pings.reset()
+	m.mu.Unlock()
 	return nil
 }
 
+// dialed returns the mgo.Database the Dial method created, or nil if the
+// database has not been dialed.
+func (m *MongoDB) dialed() *mgo.Database {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.mongodb
+}
+
+// Ping satisfies the Database interface. The mongodb database object pings
+// the session the Dial method created.
+func (m *MongoDB) Ping(ctx context.Context) error {
+	db := m.dialed()
+	if db == nil {
+		return fmt.Errorf("%w: mongodb %s", ErrNotDialed, m.name)
+	}
+	return Do(ctx, m, "ping", func(ctx context.Context) error {
+		return m.pings.wait(ctx, db.Session.Ping)
+	})
+}
+
+// Stats satisfies the Database interface. The mongodb driver keeps socket
+// stats for the whole process rather than per session, so the mongodb
+// database object only reports the size of its pool.
+func (m *MongoDB) Stats() Stats {
+	return Stats{MaxOpenConns: m.options.MaxOpenConns}
+}
+
 // Close satisfies the Database interface.  The mongodb database object closes
-// the connection the Dial method created.
+// the connection the Dial method created. Closing an undialed, or closed,
+// mongodb database object does nothing.
 func (m *MongoDB) Close() {
+	m.mu.Lock()
+	db := m.mongodb
+	m.mongodb = nil
+	m.mu.Unlock()
+	if db == nil {
+		return
+	}
+
 	defer func() { // catch any potential errors
 		if err := recover(); err != nil {
 			log.Fatal(err)
 		}
 	}()
-	m.mongodb.Session.Close()
+	db.Session.Close()
 }
 
 // BSONID takes a string ID and converts it to a bson.ObjectId. If the string
@@ -0,0 +1,543 @@
+package dba
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MigrateFunc changes the schema of a database within a transaction.
+type MigrateFunc func(ctx context.Context, tx *sql.Tx) error
+
+// Migration is a versioned schema change. Its up and down changes are Go
+// functions, or SQL statements, such as those of .sql files loaded by
+// LoadMigrations. Functions run instead of statements if both are set.
+//
+// Each change runs in a transaction along with the update of the version
+// table. MySQL commits schema statements, such as CREATE TABLE, as they run,
+// so a failed MySQL migration may leave part of its changes behind.
+type Migration struct {
+	Version int64
+	Name    string
+
+	Up   MigrateFunc
+	Down MigrateFunc
+
+	UpSQL   string
+	DownSQL string
+}
+
+// MigrationStatus tells whether a migration was applied, and when.
+type MigrationStatus struct {
+	Version   int64
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+
+	// Unknown is set for versions applied to the database that no migration
+	// has, such as those of a newer release.
+	Unknown bool
+}
+
+// migrationFile matches migration files, such as "0001_create_users.up.sql".
+var migrationFile = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// LoadMigrations loads the .sql migrations of a directory of fsys, such as an
+// embed.FS, or os.DirFS for a directory on disk. Files are named after the
+// version and name of their migration, and whether they migrate up or down,
+// such as "0001_create_users.up.sql" and "0001_create_users.down.sql".
+// Other files are ignored.
+func LoadMigrations(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int64]*Migration{}
+	for _, entry := range entries {
+		match := migrationFile.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version of %s: %s", entry.Name(), err)
+		}
+		b, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		}
+		if m.Name != match[2] {
+			return nil, fmt.Errorf("migration %d is named both %s and %s", version, m.Name, match[2])
+		}
+		if match[3] == "up" {
+			m.UpSQL = string(b)
+		} else {
+			m.DownSQL = string(b)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Migrator migrates the schema of a sql database. Concurrent migrators, such
+// as those of replicas starting together, take turns through an advisory lock
+// of PostgreSQL or MySQL, so each migration runs once. SQLite has no such
+// lock, and relies on the version table to reject a migration applied twice.
+type Migrator struct {
+
+	// Table is the version table, "schema_migrations" by default. It is
+	// created as needed.
+	Table string
+
+	// DryRun writes the migrations that would run to Log instead of running
+	// them.
+	DryRun bool
+
+	// Log receives the migrations as they run, and the plan of dry runs.
+	Log io.Writer
+
+	db         *SQL
+	migrations []Migration
+}
+
+// Migrator returns a Migrator of the sql database object for migrations,
+// which may come from Go code, files, or both. Versions must be positive and
+// unique.
+func (s *SQL) Migrator(migrations ...Migration) (*Migrator, error) {
+	sorted := append([]Migration(nil), migrations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Version < sorted[j].Version })
+
+	for i, m := range sorted {
+		if m.Version <= 0 {
+			return nil, fmt.Errorf("migration %s has version %d, versions must be positive", m.Name, m.Version)
+		}
+		if i > 0 && sorted[i-1].Version == m.Version {
+			return nil, fmt.Errorf("duplicate migration version %d of %s and %s", m.Version, sorted[i-1].Name, m.Name)
+		}
+	}
+	return &Migrator{Table: "schema_migrations", db: s, migrations: sorted}, nil
+}
+
+// Up applies all pending migrations, oldest first, and returns how many ran.
+func (m *Migrator) Up(ctx context.Context) (int, error) {
+	return m.UpTo(ctx, -1)
+}
+
+// UpTo applies the pending migrations up to and including version, or all
+// of them if version is negative, and returns how many ran.
+func (m *Migrator) UpTo(ctx context.Context, version int64) (int, error) {
+	var n int
+	err := m.locked(ctx, func(conn *sql.Conn, applied map[int64]time.Time) error {
+		for _, migration := range m.migrations {
+			if version >= 0 && migration.Version > version {
+				break
+			}
+			if _, ok := applied[migration.Version]; ok {
+				continue
+			}
+			if err := m.run(ctx, conn, migration, true); err != nil {
+				return err
+			}
+			n++
+		}
+		return nil
+	})
+	return n, err
+}
+
+// Down rolls back the last steps applied migrations, newest first, and
+// returns how many ran.
+func (m *Migrator) Down(ctx context.Context, steps int) (int, error) {
+	var n int
+	err := m.locked(ctx, func(conn *sql.Conn, applied map[int64]time.Time) error {
+		versions := make([]int64, 0, len(applied))
+		for version := range applied {
+			versions = append(versions, version)
+		}
+		sort.Slice(versions, func(i, j int) bool { return versions[i] > versions[j] })
+
+		for _, version := range versions {
+			if n == steps {
+				break
+			}
+			migration, ok := m.find(version)
+			if !ok {
+				return fmt.Errorf("applied migration %d is unknown, and can not be rolled back", version)
+			}
+			if err := m.run(ctx, conn, migration, false); err != nil {
+				return err
+			}
+			n++
+		}
+		return nil
+	})
+	return n, err
+}
+
+// Status returns the status of every migration, and of every applied version
+// no migration has.
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	if !validTable.MatchString(m.Table) {
+		return nil, fmt.Errorf("invalid migration table name %q", m.Table)
+	}
+	conn, err := m.conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	applied, err := m.applied(ctx, conn, true)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		at, ok := applied[migration.Version]
+		statuses = append(statuses, MigrationStatus{Version: migration.Version, Name: migration.Name, Applied: ok, AppliedAt: at})
+		delete(applied, migration.Version)
+	}
+	for version, at := range applied {
+		statuses = append(statuses, MigrationStatus{Version: version, Applied: true, AppliedAt: at, Unknown: true})
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version })
+	return statuses, nil
+}
+
+// locked calls f with a connection holding the migration lock, and the
+// applied versions. Dry runs take no lock and change nothing.
+func (m *Migrator) locked(ctx context.Context, f func(conn *sql.Conn, applied map[int64]time.Time) error) error {
+	if !validTable.MatchString(m.Table) {
+		return fmt.Errorf("invalid migration table name %q", m.Table)
+	}
+	conn, err := m.conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if !m.DryRun {
+		var unlock func()
+		err := Do(ctx, m.db, "lock migrations", func(ctx context.Context) (err error) {
+			unlock, err = m.lock(ctx, conn)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		defer unlock()
+
+		create := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (version BIGINT PRIMARY KEY, name VARCHAR(255) NOT NULL, applied_at TIMESTAMP NOT NULL)", m.Table)
+		if _, err := conn.ExecContext(ctx, create); err != nil {
+			return fmt.Errorf("creating migration table %s: %w", m.Table, err)
+		}
+	}
+
+	applied, err := m.applied(ctx, conn, m.DryRun)
+	if err != nil {
+		return err
+	}
+	return f(conn, applied)
+}
+
+// find returns the migration of a version.
+func (m *Migrator) find(version int64) (Migration, bool) {
+	i := sort.Search(len(m.migrations), func(i int) bool { return m.migrations[i].Version >= version })
+	if i < len(m.migrations) && m.migrations[i].Version == version {
+		return m.migrations[i], true
+	}
+	return Migration{}, false
+}
+
+// conn returns a connection of the sql database.
+func (m *Migrator) conn(ctx context.Context) (*sql.Conn, error) {
+	dbmap := m.db.dialed()
+	if dbmap == nil {
+		return nil, fmt.Errorf("%w: sql %s", ErrNotDialed, m.db.name)
+	}
+	return dbmap.Db.Conn(ctx)
+}
+
+// validTable matches table names that need no quoting.
+var validTable = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+
+// applied returns the applied versions and when they were applied. Before the
+// first migration there is no version table, which dry runs and statuses do
+// not create, so they set missing to take a table that does not exist as no
+// versions applied. Other errors, such as of an unreachable database, are
+// returned.
+func (m *Migrator) applied(ctx context.Context, conn *sql.Conn, missing bool) (map[int64]time.Time, error) {
+	applied := map[int64]time.Time{}
+	err := Do(ctx, m.db, "select "+m.Table, func(ctx context.Context) error {
+		if missing {
+			exists, err := m.exists(ctx, conn)
+			if err != nil || !exists {
+				return err
+			}
+		}
+
+		rows, err := conn.QueryContext(ctx, fmt.Sprintf("SELECT version, applied_at FROM %s", m.Table))
+		if err != nil {
+			return fmt.Errorf("reading migration table %s: %w", m.Table, err)
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var version int64
+			var at interface{}
+			if err := rows.Scan(&version, &at); err != nil {
+				return err
+			}
+			applied[version] = timestamp(at)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return applied, nil
+}
+
+// exists checks if the version table exists, in the schema it names or the
+// current one.
+func (m *Migrator) exists(ctx context.Context, conn *sql.Conn) (bool, error) {
+	schema, table := "", m.Table
+	if i := strings.Index(m.Table, "."); i > -1 {
+		schema, table = m.Table[:i], m.Table[i+1:]
+	}
+
+	var query string
+	args := []interface{}{table}
+	switch m.db.driver {
+	case "postgres":
+		query, args = "SELECT CASE WHEN to_regclass($1) IS NULL THEN 0 ELSE 1 END", []interface{}{m.Table}
+	case "mysql":
+		query, args = "SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = COALESCE(NULLIF(?, ''), DATABASE()) AND table_name = ?", []interface{}{schema, table}
+	default:
+		master := "sqlite_master"
+		if len(schema) > 0 {
+			master = schema + "." + master
+		}
+		query = fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE type = 'table' AND name = ?", master)
+	}
+
+	var n int64
+	if err := conn.QueryRowContext(ctx, query, args...).Scan(&n); err != nil {
+		return false, fmt.Errorf("reading migration table %s: %w", m.Table, err)
+	}
+	return n > 0, nil
+}
+
+// timestamp converts a scanned TIMESTAMP to a time. MySQL returns timestamps
+// as text unless its DSN sets parseTime, which is left to the app.
+func timestamp(v interface{}) time.Time {
+	var s string
+	switch v := v.(type) {
+	case time.Time:
+		return v
+	case []byte:
+		s = string(v)
+	case string:
+		s = v
+	}
+	for _, layout := range []string{"2006-01-02 15:04:05.999999999", time.RFC3339Nano} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// run runs a migration up or down in a transaction that also records it in
+// the version table. Dry runs write it to the log instead.
+func (m *Migrator) run(ctx context.Context, conn *sql.Conn, migration Migration, up bool) error {
+	direction, f, statements := "up", migration.Up, migration.UpSQL
+	if !up {
+		direction, f, statements = "down", migration.Down, migration.DownSQL
+	}
+	if f == nil && len(strings.TrimSpace(statements)) == 0 {
+		return fmt.Errorf("migration %d %s has no %s migration", migration.Version, migration.Name, direction)
+	}
+
+	m.logf("-- migrate %s %d %s\n", direction, migration.Version, migration.Name)
+	if m.DryRun {
+		if f != nil {
+			m.logf("-- (go function)\n")
+		} else {
+			m.logf("%s\n", strings.TrimSpace(statements))
+		}
+		return nil
+	}
+
+	op := fmt.Sprintf("migrate %s %d", direction, migration.Version)
+	return Do(ctx, m.db, op, func(ctx context.Context) error {
+		tx, err := conn.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		if err := m.change(ctx, tx, migration, up, f, statements); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %d %s %s: %w", migration.Version, migration.Name, direction, err)
+		}
+		return tx.Commit()
+	})
+}
+
+// change runs the function or statements of a migration and records it.
+func (m *Migrator) change(ctx context.Context, tx *sql.Tx, migration Migration, up bool, f MigrateFunc, statements string) error {
+	if f != nil {
+		if err := f(ctx, tx); err != nil {
+			return err
+		}
+	} else {
+		for _, statement := range splitStatements(statements) {
+			if _, err := tx.ExecContext(ctx, statement); err != nil {
+				return err
+			}
+		}
+	}
+
+	if up {
+		insert := fmt.Sprintf("INSERT INTO %s (version, name, applied_at) VALUES (%s, %s, %s)",
+			m.Table, m.placeholder(1), m.placeholder(2), m.placeholder(3))
+		_, err := tx.ExecContext(ctx, insert, migration.Version, migration.Name, time.Now().UTC())
+		return err
+	}
+	remove := fmt.Sprintf("DELETE FROM %s WHERE version = %s", m.Table, m.placeholder(1))
+	_, err := tx.ExecContext(ctx, remove, migration.Version)
+	return err
+}
+
+// lock takes the advisory migration lock of the database on conn, waiting
+// for other migrators to release it, and returns its release.
+func (m *Migrator) lock(ctx context.Context, conn *sql.Conn) (func(), error) {
+	switch m.db.driver {
+	case "postgres":
+		h := fnv.New64a()
+		h.Write([]byte("dba migrate " + m.Table))
+		key := int64(h.Sum64())
+		if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", key); err != nil {
+			return nil, fmt.Errorf("locking migrations: %w", err)
+		}
+		return func() { conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", key) }, nil
+
+	case "mysql":
+		name := "dba_migrate_" + m.Table
+		timeout := -1 // wait for as long as it takes
+		if deadline, ok := ctx.Deadline(); ok {
+			// GET_LOCK waits in whole seconds, and takes a timeout of zero
+			// as not waiting and a negative one as waiting forever
+			timeout = int(time.Until(deadline).Seconds())
+			if timeout < 1 {
+				return nil, errors.New("locking migrations: less than a second left to wait for another migrator")
+			}
+		}
+		var locked sql.NullInt64
+		if err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", name, timeout).Scan(&locked); err != nil {
+			return nil, fmt.Errorf("locking migrations: %w", err)
+		}
+		if locked.Int64 != 1 {
+			return nil, errors.New("locking migrations: timed out waiting for another migrator")
+		}
+		return func() { conn.ExecContext(context.Background(), "SELECT RELEASE_LOCK(?)", name) }, nil
+	}
+	return func() {}, nil
+}
+
+// placeholder returns the query placeholder of the nth argument.
+func (m *Migrator) placeholder(n int) string {
+	if m.db.driver == "postgres" {
+		return "$" + strconv.Itoa(n)
+	}
+	return "?"
+}
+
+// logf writes to the Log, if there is one.
+func (m *Migrator) logf(format string, args ...interface{}) {
+	if m.Log != nil {
+		fmt.Fprintf(m.Log, format, args...)
+	}
+}
+
+// splitStatements splits SQL into statements at semicolons, other than those
+// within quotes, comments and PostgreSQL dollar quoted bodies, so functions
+// and strings holding semicolons stay whole.
+func splitStatements(script string) []string {
+	var (
+		statements []string
+		start      int
+		quote      string // the delimiter of the quote, comment or body the scan is in
+	)
+
+	for i := 0; i < len(script); i++ {
+		rest := script[i:]
+		if len(quote) > 0 {
+			if strings.HasPrefix(rest, quote) {
+				i += len(quote) - 1
+				quote = ""
+			}
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(rest, "--"):
+			quote = "\n"
+		case strings.HasPrefix(rest, "/*"):
+			quote = "*/"
+			i++
+		case rest[0] == '\'' || rest[0] == '"' || rest[0] == '`':
+			quote = rest[:1]
+		case rest[0] == '$':
+			if tag := dollarTag.FindString(rest); len(tag) > 0 {
+				quote = tag
+				i += len(tag) - 1
+			}
+		case rest[0] == ';':
+			if statement := strings.TrimSpace(script[start:i]); len(statement) > 0 {
+				statements = append(statements, statement)
+			}
+			start = i + 1
+		}
+	}
+
+	if statement := strings.TrimSpace(script[start:]); len(statement) > 0 && !onlyComments(statement) {
+		statements = append(statements, statement)
+	}
+	return statements
+}
+
+// dollarTag matches the start of a PostgreSQL dollar quoted body, such as $$
+// or $body$.
+var dollarTag = regexp.MustCompile(`^\$[A-Za-z_]*\$`)
+
+// onlyComments reports whether a statement holds nothing but line comments.
+func onlyComments(statement string) bool {
+	for _, line := range strings.Split(statement, "\n") {
+		if line = strings.TrimSpace(line); len(line) > 0 && !strings.HasPrefix(line, "--") {
+			return false
+		}
+	}
+	return true
+}
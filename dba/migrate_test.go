@@ -0,0 +1,442 @@
+package dba
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"gopkg.in/gorp.v1"
+)
+
+func init() {
+	sql.Register("dba-recorder", recorderDriver{})
+}
+
+// recorders are the recorders of the open test databases, by name.
+var recorders sync.Map
+
+// recorder is a database that records the statements run on it and keeps a
+// version table, for running migrators without a database server.
+type recorder struct {
+	mu       sync.Mutex
+	calls    []string
+	versions map[int64]time.Time
+
+	// fail fails the statements holding it
+	fail string
+
+	// missing reports the version table as not existing
+	missing bool
+}
+
+// recorded returns the statements run so far, and forgets them.
+func (r *recorder) recorded() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	calls := r.calls
+	r.calls = nil
+	return calls
+}
+
+// record records a call, failing it if it holds fail.
+func (r *recorder) record(call string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls = append(r.calls, call)
+	if len(r.fail) > 0 && strings.Contains(call, r.fail) {
+		return errors.New("failed: " + call)
+	}
+	return nil
+}
+
+// testSQL returns a sql database object of driver dialed to a recorder.
+func testSQL(t *testing.T, driver string) (*SQL, *recorder) {
+	r := &recorder{versions: map[int64]time.Time{}}
+	recorders.Store(t.Name(), r)
+	db, err := sql.Open("dba-recorder", t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		db.Close()
+		recorders.Delete(t.Name())
+	})
+	return &SQL{name: "app", driver: driver, sql: &gorp.DbMap{Db: db}}, r
+}
+
+type recorderDriver struct{}
+
+func (recorderDriver) Open(name string) (driver.Conn, error) {
+	r, ok := recorders.Load(name)
+	if !ok {
+		return nil, errors.New("no recorder " + name)
+	}
+	return &recorderConn{r.(*recorder)}, nil
+}
+
+type recorderConn struct{ r *recorder }
+
+func (c *recorderConn) Prepare(query string) (driver.Stmt, error) {
+	return &recorderStmt{c.r, query}, nil
+}
+func (c *recorderConn) Close() error              { return nil }
+func (c *recorderConn) Begin() (driver.Tx, error) { return c, c.r.record("BEGIN") }
+func (c *recorderConn) Commit() error             { return c.r.record("COMMIT") }
+func (c *recorderConn) Rollback() error           { return c.r.record("ROLLBACK") }
+
+type recorderStmt struct {
+	r     *recorder
+	query string
+}
+
+func (s *recorderStmt) Close() error  { return nil }
+func (s *recorderStmt) NumInput() int { return -1 }
+
+func (s *recorderStmt) Exec(args []driver.Value) (driver.Result, error) {
+	if err := s.r.record(s.query); err != nil {
+		return nil, err
+	}
+	s.r.mu.Lock()
+	defer s.r.mu.Unlock()
+	switch {
+	case strings.HasPrefix(s.query, "INSERT"):
+		s.r.versions[args[0].(int64)] = args[2].(time.Time)
+	case strings.HasPrefix(s.query, "DELETE"):
+		delete(s.r.versions, args[0].(int64))
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (s *recorderStmt) Query(args []driver.Value) (driver.Rows, error) {
+	call := s.query
+	for _, arg := range args {
+		call += " " + fmt.Sprint(arg)
+	}
+	if err := s.r.record(call); err != nil {
+		return nil, err
+	}
+	if strings.Contains(s.query, "sqlite_master") && s.r.missing {
+		return &recorderRows{columns: []string{"count"}, rows: [][]driver.Value{{int64(0)}}}, nil
+	}
+	if !strings.HasPrefix(s.query, "SELECT version") {
+		return &recorderRows{columns: []string{"locked"}, rows: [][]driver.Value{{int64(1)}}}, nil
+	}
+
+	s.r.mu.Lock()
+	defer s.r.mu.Unlock()
+	rows := &recorderRows{columns: []string{"version", "applied_at"}}
+	for version, at := range s.r.versions {
+		rows.rows = append(rows.rows, []driver.Value{version, at})
+	}
+	return rows, nil
+}
+
+type recorderRows struct {
+	columns []string
+	rows    [][]driver.Value
+}
+
+func (r *recorderRows) Columns() []string { return r.columns }
+func (r *recorderRows) Close() error      { return nil }
+
+func (r *recorderRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+func TestSplitStatements(t *testing.T) {
+	tests := []struct {
+		script     string
+		statements []string
+	}{
+		{"", nil},
+		{"CREATE TABLE a (id INT);\nCREATE TABLE b (id INT)", []string{"CREATE TABLE a (id INT)", "CREATE TABLE b (id INT)"}},
+		{"INSERT INTO a VALUES ('x;y', \"z;\", `w;`);", []string{"INSERT INTO a VALUES ('x;y', \"z;\", `w;`)"}},
+		{"SELECT 1; -- one; two\nSELECT 2;", []string{"SELECT 1", "-- one; two\nSELECT 2"}},
+		{"SELECT /* a; b */ 1;", []string{"SELECT /* a; b */ 1"}},
+		{"SELECT 1;\n-- trailing comment;\n-- another", []string{"SELECT 1"}},
+		{
+			"CREATE FUNCTION f() RETURNS int AS $$ SELECT 1; $$ LANGUAGE sql;\nCREATE FUNCTION g() RETURNS int AS $body$ SELECT 2; $body$ LANGUAGE sql;",
+			[]string{
+				"CREATE FUNCTION f() RETURNS int AS $$ SELECT 1; $$ LANGUAGE sql",
+				"CREATE FUNCTION g() RETURNS int AS $body$ SELECT 2; $body$ LANGUAGE sql",
+			},
+		},
+		{"SELECT $1;", []string{"SELECT $1"}},
+	}
+	for _, test := range tests {
+		if statements := splitStatements(test.script); !reflect.DeepEqual(statements, test.statements) {
+			t.Errorf("%q: expected %q, got %q", test.script, test.statements, statements)
+		}
+	}
+}
+
+func TestLoadMigrations(t *testing.T) {
+	fsys := fstest.MapFS{
+		"migrations/0002_add_email.up.sql":      {Data: []byte("ALTER TABLE users ADD email TEXT;")},
+		"migrations/0001_create_users.up.sql":   {Data: []byte("CREATE TABLE users (id INT);")},
+		"migrations/0001_create_users.down.sql": {Data: []byte("DROP TABLE users;")},
+		"migrations/README.md":                  {Data: []byte("migrations")},
+		"migrations/0003_nested.up.sql/x":       {Data: []byte("not a migration")},
+	}
+	migrations, err := LoadMigrations(fsys, "migrations")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Migration{
+		{Version: 1, Name: "create_users", UpSQL: "CREATE TABLE users (id INT);", DownSQL: "DROP TABLE users;"},
+		{Version: 2, Name: "add_email", UpSQL: "ALTER TABLE users ADD email TEXT;"},
+	}
+	if !reflect.DeepEqual(migrations, expected) {
+		t.Errorf("expected %+v, got %+v", expected, migrations)
+	}
+
+	fsys["migrations/0001_make_users.down.sql"] = &fstest.MapFile{Data: []byte("DROP TABLE users;")}
+	if _, err := LoadMigrations(fsys, "migrations"); err == nil || !strings.Contains(err.Error(), "named both") {
+		t.Errorf("expected a clash of names, got %v", err)
+	}
+
+	if _, err := LoadMigrations(fsys, "missing"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected a missing directory, got %v", err)
+	}
+}
+
+func TestTimestamp(t *testing.T) {
+	at := time.Date(2026, 10, 19, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		v  interface{}
+		at time.Time
+	}{
+		{at, at},
+		{[]byte("2026-10-19 10:00:00"), at},
+		{"2026-10-19 10:00:00.5", at.Add(500 * time.Millisecond)},
+		{"2026-10-19T10:00:00Z", at},
+		{"yesterday", time.Time{}},
+		{nil, time.Time{}},
+	}
+	for _, test := range tests {
+		if got := timestamp(test.v); !got.Equal(test.at) {
+			t.Errorf("%v: expected %s, got %s", test.v, test.at, got)
+		}
+	}
+}
+
+func TestMigratorVersions(t *testing.T) {
+	s, _ := testSQL(t, "sqlite")
+
+	m, err := s.Migrator(Migration{Version: 3, Name: "c"}, Migration{Version: 1, Name: "a"}, Migration{Version: 2, Name: "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, migration := range m.migrations {
+		names = append(names, migration.Name)
+	}
+	if !reflect.DeepEqual(names, []string{"a", "b", "c"}) {
+		t.Errorf("expected migrations ordered by version, got %v", names)
+	}
+
+	if _, err := s.Migrator(Migration{Version: 1, Name: "a"}, Migration{Version: 1, Name: "b"}); err == nil {
+		t.Error("expected duplicate versions to be rejected")
+	}
+	if _, err := s.Migrator(Migration{Version: 0, Name: "a"}); err == nil {
+		t.Error("expected a version that is not positive to be rejected")
+	}
+}
+
+// testMigrations are migrations of statements and of a function.
+func testMigrations() []Migration {
+	return []Migration{
+		{Version: 1, Name: "create_users", UpSQL: "CREATE TABLE users (id INT);", DownSQL: "DROP TABLE users;"},
+		{Version: 2, Name: "add_email", UpSQL: "ALTER TABLE users ADD email TEXT;", DownSQL: "ALTER TABLE users DROP email;"},
+		{
+			Version: 3,
+			Name:    "backfill",
+			Up: func(ctx context.Context, tx *sql.Tx) error {
+				_, err := tx.ExecContext(ctx, "UPDATE users SET email = ''")
+				return err
+			},
+			Down: func(ctx context.Context, tx *sql.Tx) error { return nil },
+		},
+	}
+}
+
+func TestMigratorUpDown(t *testing.T) {
+	ctx := context.Background()
+	s, r := testSQL(t, "sqlite")
+	m, err := s.Migrator(testMigrations()...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// up to a version
+	if n, err := m.UpTo(ctx, 1); err != nil || n != 1 {
+		t.Fatalf("expected 1 migration up, got %d %v", n, err)
+	}
+	calls := r.recorded()
+	expected := []string{
+		"CREATE TABLE IF NOT EXISTS schema_migrations (version BIGINT PRIMARY KEY, name VARCHAR(255) NOT NULL, applied_at TIMESTAMP NOT NULL)",
+		"SELECT version, applied_at FROM schema_migrations",
+		"BEGIN",
+		"CREATE TABLE users (id INT)",
+		"INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)",
+		"COMMIT",
+	}
+	if !reflect.DeepEqual(calls, expected) {
+		t.Errorf("expected calls %q, got %q", expected, calls)
+	}
+
+	// the rest, skipping the applied version
+	if n, err := m.Up(ctx); err != nil || n != 2 {
+		t.Fatalf("expected 2 migrations up, got %d %v", n, err)
+	}
+	calls = r.recorded()
+	if strings.Contains(strings.Join(calls, "\n"), "CREATE TABLE users") || !strings.Contains(strings.Join(calls, "\n"), "UPDATE users") {
+		t.Errorf("expected the pending migrations only, got %q", calls)
+	}
+	if n, err := m.Up(ctx); err != nil || n != 0 {
+		t.Errorf("expected no migration up, got %d %v", n, err)
+	}
+
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, status := range statuses {
+		if !status.Applied || status.AppliedAt.IsZero() || status.Unknown {
+			t.Errorf("expected an applied migration, got %+v", status)
+		}
+	}
+
+	// down, newest first
+	if n, err := m.Down(ctx, 2); err != nil || n != 2 {
+		t.Fatalf("expected 2 migrations down, got %d %v", n, err)
+	}
+	calls = r.recorded()
+	expected = []string{"BEGIN", "ALTER TABLE users DROP email", "DELETE FROM schema_migrations WHERE version = ?", "COMMIT"}
+	if len(calls) < 8 || !reflect.DeepEqual(calls[len(calls)-4:], expected) {
+		t.Errorf("expected the third then the second migration rolled back, got %q", calls)
+	}
+	if len(r.versions) != 1 || r.versions[1].IsZero() {
+		t.Errorf("expected only the first version applied, got %v", r.versions)
+	}
+	if n, err := m.Down(ctx, 5); err != nil || n != 1 {
+		t.Errorf("expected the last migration down, got %d %v", n, err)
+	}
+	if n, err := m.Down(ctx, 1); err != nil || n != 0 {
+		t.Errorf("expected no migration down, got %d %v", n, err)
+	}
+}
+
+func TestMigratorFailure(t *testing.T) {
+	ctx := context.Background()
+	s, r := testSQL(t, "sqlite")
+	m, err := s.Migrator(testMigrations()...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r.fail = "ALTER TABLE"
+	if n, err := m.Up(ctx); err == nil || n != 1 || !strings.Contains(err.Error(), "migration 2 add_email up") {
+		t.Fatalf("expected the second migration to fail, got %d %v", n, err)
+	}
+	if calls := r.recorded(); calls[len(calls)-1] != "ROLLBACK" {
+		t.Errorf("expected the failed migration rolled back, got %q", calls)
+	}
+	if _, ok := r.versions[2]; ok || len(r.versions) != 1 {
+		t.Errorf("expected only the first version applied, got %v", r.versions)
+	}
+
+	// applied versions no migration has are unknown, and not rolled back
+	r.fail = ""
+	r.versions[9] = time.Now()
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last := statuses[len(statuses)-1]; last.Version != 9 || !last.Unknown {
+		t.Errorf("expected an unknown version, got %+v", last)
+	}
+	if _, err := m.Down(ctx, 1); err == nil || !strings.Contains(err.Error(), "unknown") {
+		t.Errorf("expected an unknown version to fail, got %v", err)
+	}
+}
+
+func TestMigratorDryRun(t *testing.T) {
+	s, r := testSQL(t, "sqlite")
+	m, err := s.Migrator(testMigrations()...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.missing = true // there is no version table yet
+
+	var log bytes.Buffer
+	m.DryRun, m.Log = true, &log
+	if n, err := m.Up(context.Background()); err != nil || n != 3 {
+		t.Fatalf("expected 3 migrations planned, got %d %v", n, err)
+	}
+	expected := "-- migrate up 1 create_users\nCREATE TABLE users (id INT);\n-- migrate up 2 add_email\nALTER TABLE users ADD email TEXT;\n-- migrate up 3 backfill\n-- (go function)\n"
+	if log.String() != expected {
+		t.Errorf("expected the plan %q, got %q", expected, log.String())
+	}
+	if calls := r.recorded(); len(calls) != 1 || len(r.versions) != 0 {
+		t.Errorf("expected nothing changed, got %q", calls)
+	}
+
+	// a version table that cannot be read is not taken as missing
+	r.missing = false
+	for _, fail := range []string{"sqlite_master", "SELECT version"} {
+		r.fail = fail
+		if _, err := m.Up(context.Background()); err == nil {
+			t.Errorf("%s: expected the dry run to fail", fail)
+		}
+		if _, err := m.Status(context.Background()); err == nil {
+			t.Errorf("%s: expected the status to fail", fail)
+		}
+	}
+}
+
+func TestMigratorLock(t *testing.T) {
+	s, r := testSQL(t, "mysql")
+	m, err := s.Migrator(testMigrations()[:1]...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if _, err := m.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+	calls := r.recorded()
+	if lock := calls[0]; lock != "SELECT GET_LOCK(?, ?) dba_migrate_schema_migrations 9" && lock != "SELECT GET_LOCK(?, ?) dba_migrate_schema_migrations 10" {
+		t.Errorf("expected the lock to wait for the seconds left, got %q", lock)
+	}
+	if release := calls[len(calls)-1]; release != "SELECT RELEASE_LOCK(?)" {
+		t.Errorf("expected the lock released, got %q", release)
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	if _, err := m.Down(ctx, 1); err == nil || !strings.Contains(err.Error(), "less than a second") {
+		t.Errorf("expected a deadline under a second to be rejected, got %v", err)
+	}
+	if calls := r.recorded(); len(calls) != 0 {
+		t.Errorf("expected no lock taken, got %q", calls)
+	}
+}
@@ -0,0 +1,110 @@
+package dba
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Options configure the connection pool, timeouts and TLS of a database. Zero
+// values keep the defaults of the driver. Options not listed for a database
+// are ignored by it.
+type Options struct {
+
+	// MaxOpenConns limits the connections of the pool. It sets the maximum
+	// open connections of SQL, the pool limit of MongoDB and the pool size of
+	// Redis.
+	MaxOpenConns int
+
+	// MaxIdleConns limits the idle connections of a SQL pool.
+	MaxIdleConns int
+
+	// ConnMaxLifetime closes SQL connections older than it.
+	ConnMaxLifetime time.Duration
+
+	// ConnMaxIdleTime closes SQL and Redis connections idle for longer.
+	ConnMaxIdleTime time.Duration
+
+	// DialTimeout limits how long connecting may take.
+	DialTimeout time.Duration
+
+	// ReadTimeout and WriteTimeout limit network reads and writes. They are
+	// supported by MySQL, MongoDB, whose socket timeout is the larger of the
+	// two, and Redis.
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+
+	// PoolTimeout limits how long Redis waits for a free connection.
+	PoolTimeout time.Duration
+
+	// TLS encrypts connections if set.
+	TLS *TLS
+}
+
+// TLS configures encrypted connections. Files are PEM encoded.
+type TLS struct {
+
+	// CAFile verifies the server with the certificate authorities it holds
+	// instead of those of the system.
+	CAFile string
+
+	// CertFile and KeyFile hold the certificate of the client, if the server
+	// asks for one.
+	CertFile string
+	KeyFile  string
+
+	// ServerName verifies the server by a name other than its address.
+	ServerName string
+
+	// InsecureSkipVerify accepts any certificate of the server. It is meant
+	// for testing only.
+	InsecureSkipVerify bool
+}
+
+// Config returns the tls.Config of the settings.
+func (t *TLS) Config() (*tls.Config, error) {
+	config := &tls.Config{
+		ServerName:         t.ServerName,
+		InsecureSkipVerify: t.InsecureSkipVerify,
+	}
+
+	if len(t.CAFile) > 0 {
+		pem, err := os.ReadFile(t.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates in tls ca file %s", t.CAFile)
+		}
+	}
+
+	if len(t.CertFile) > 0 || len(t.KeyFile) > 0 {
+		if len(t.CertFile) == 0 || len(t.KeyFile) == 0 {
+			return nil, errors.New("tls cert and key files must be given together")
+		}
+		cert, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	return config, nil
+}
+
+// Stats describe the connection pool of a database.
+type Stats struct {
+	MaxOpenConns int `json:"max_open_conns"`
+	OpenConns    int `json:"open_conns"`
+	InUse        int `json:"in_use"`
+	Idle         int `json:"idle"`
+
+	// WaitCount is the number of times a connection was waited for, and
+	// Timeouts the number of those waits that gave up.
+	WaitCount int64 `json:"wait_count"`
+	Timeouts  int64 `json:"timeouts"`
+}
@@ -0,0 +1,92 @@
+package dba
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeCert writes a self-signed certificate and its key to dir, and returns
+// their files.
+func writeCert(t *testing.T, dir string) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "db.internal"},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+func TestTLSConfig(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeCert(t, dir)
+
+	config, err := (&TLS{CAFile: certFile, CertFile: certFile, KeyFile: keyFile, ServerName: "db.internal"}).Config()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.ServerName != "db.internal" || config.InsecureSkipVerify {
+		t.Errorf("unexpected server verification %q %v", config.ServerName, config.InsecureSkipVerify)
+	}
+	if config.RootCAs == nil {
+		t.Error("expected the certificate authorities of the ca file")
+	}
+	if len(config.Certificates) != 1 {
+		t.Errorf("expected the client certificate, got %d", len(config.Certificates))
+	}
+
+	config, err = (&TLS{InsecureSkipVerify: true}).Config()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !config.InsecureSkipVerify || config.RootCAs != nil || len(config.Certificates) != 0 {
+		t.Errorf("expected an unverified config of the system authorities, got %+v", config)
+	}
+
+	empty := filepath.Join(dir, "empty.pem")
+	if err := os.WriteFile(empty, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	failures := []*TLS{
+		{CAFile: empty},
+		{CAFile: filepath.Join(dir, "missing.pem")},
+		{CertFile: certFile},
+		{KeyFile: keyFile},
+	}
+	for _, tls := range failures {
+		if _, err := tls.Config(); err == nil {
+			t.Errorf("expected %+v to fail", tls)
+		}
+	}
+}
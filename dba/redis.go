@@ -1,20 +1,31 @@
 package dba
 
 import (
+	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"log"
+	"net"
 	"strconv"
+	"sync"
+	"time"
 
 	"gopkg.in/redis.v3"
 )
 
 // RedisDB describes a redis database object.
 type RedisDB struct {
-	client           *redis.Client
+	name             string
 	user, pass, addr string
 	poolSize         int
 	db               int64
+	options          Options
+	pings            pinger
+
+	// mu guards client, which Dial sets while others may read it
+	mu     sync.RWMutex
+	client *redis.Client
 }
 
 // NewRedis creates a new redis database object.
@@ -31,9 +42,29 @@ func NewRedis(db int64, user, pass, addr string, poolSize int) (*RedisDB, error)
 	}, nil
 }
 
+// SetOptions configures the connection pool, timeouts and TLS of the redis
+// database object. It must be called before Dial. A MaxOpenConns option
+// replaces the pool size the object was created with.
+func (r *RedisDB) SetOptions(o Options) {
+	r.options = o
+	if o.MaxOpenConns > 0 {
+		r.poolSize = o.MaxOpenConns
+	}
+}
+
+// SetName names the redis database object, such as "sessions", for lookups
+// in a DatabaseAdmin. The object is named after the number of the database it
+// dials otherwise.
+func (r *RedisDB) SetName(name string) {
+	r.name = name
+}
+
 // Name satisfies the Database interface.  The redis database object returns
-// the name of the last database the Dial method attempted to connect with.
+// the name it is looked up by.
 func (r *RedisDB) Name() string {
+	if len(r.name) > 0 {
+		return r.name
+	}
 	return strconv.FormatInt(r.db, 10)
 }
 
@@ -54,40 +85,116 @@ func (r *RedisDB) Dial(name string) error {
 	}
 
 	// establish a client
-	client := redis.NewClient(&redis.Options{
-		Addr:     r.addr,
-		Password: r.pass,
-		DB:       i,
-		PoolSize: r.poolSize,
-	})
+	options := &redis.Options{
+		Addr:         r.addr,
+		Password:     r.pass,
+		DB:           i,
+		PoolSize:     r.poolSize,
+		DialTimeout:  r.options.DialTimeout,
+		ReadTimeout:  r.options.ReadTimeout,
+		WriteTimeout: r.options.WriteTimeout,
+		PoolTimeout:  r.options.PoolTimeout,
+		IdleTimeout:  r.options.ConnMaxIdleTime,
+	}
+	if r.options.TLS != nil {
+		config, err := r.options.TLS.Config()
+		if err != nil {
+			return redisError(r.db, err)
+		}
+		dialer := &net.Dialer{Timeout: r.options.DialTimeout}
+		options.Dialer = func() (net.Conn, error) {
+			return tls.DialWithDialer(dialer, "tcp", r.addr, config)
+		}
+	}
+	client := redis.NewClient(options)
 
-	// ping test the connection
-	_, err = client.Ping().Result()
+	// ping test the connection, which has no read timeout by default
+	timeout := r.options.DialTimeout
+	if timeout <= 0 {
+		timeout = dialPingTimeout
+	}
+	// with a pinger of its own, as a ping of the old client may be in flight
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	var pings pinger
+	err = pings.wait(ctx, func() error { return client.Ping().Err() })
 	if err != nil {
+		client.Close()
 		return redisError(r.db, err)
 	}
 
 	// set database client
+	r.mu.Lock()
 	r.client = client
+	r.pings.reset()
+	r.mu.Unlock()
+	return nil
+}
+
+// dialPingTimeout limits the ping of Dial if there is no dial timeout.
+const dialPingTimeout = 5 * time.Second
+
+// Client returns the redis client the Dial method created, or nil if the
+// database has not been dialed.
+func (r *RedisDB) Client() *redis.Client {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.client
+}
 
-	// double check the database session connection was sucessful
-	if r.client == nil {
-		return redisError(r.db, errors.New("client is nil"))
+// Ping satisfies the Database interface. The redis database object pings
+// the client the Dial method created.
+func (r *RedisDB) Ping(ctx context.Context) error {
+	client := r.Client()
+	if client == nil {
+		return fmt.Errorf("%w: redis %s", ErrNotDialed, r.Name())
 	}
+	return Do(ctx, r, "ping", func(ctx context.Context) error {
+		return r.pings.wait(ctx, func() error {
+			if err := client.Ping().Err(); err != nil {
+				return redisError(r.db, err)
+			}
+			return nil
+		})
+	})
+}
 
-	return nil
+// Stats satisfies the Database interface. The redis database object returns
+// the stats of the connection pool of the client the Dial method created.
+func (r *RedisDB) Stats() Stats {
+	stats := Stats{MaxOpenConns: r.poolSize}
+	client := r.Client()
+	if client == nil {
+		return stats
+	}
+	pool := client.PoolStats()
+	stats.OpenConns = int(pool.TotalConns)
+	stats.Idle = int(pool.FreeConns)
+	stats.InUse = stats.OpenConns - stats.Idle
+	stats.WaitCount = int64(pool.Waits)
+	stats.Timeouts = int64(pool.Timeouts)
+	return stats
 }
 
 // Close satisfies the Database interface.  The redis database object closes
-// the connection the Dial method created.
+// the connection the Dial method created. Closing an undialed, or closed,
+// redis database object does nothing.
 func (r *RedisDB) Close() {
+	r.mu.Lock()
+	client := r.client
+	r.client = nil
+	r.mu.Unlock()
+	if client == nil {
+		return
+	}
+
 	// if there is an error, recover, log, and stop the world
 	defer func() {
 		if err := recover(); err != nil {
 			log.Fatal(redisError(r.db, fmt.Errorf("%s", err)))
 		}
 	}()
-	r.client.Close()
+	client.Close()
 }
 
 func redisError(db int64, err error) error {
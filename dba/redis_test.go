@@ -0,0 +1,39 @@
+package dba
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRedisRedial(t *testing.T) {
+	r, err := NewRedis(0, "", "", "cache.internal:6379", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.SetOptions(Options{DialTimeout: 50 * time.Millisecond})
+
+	// a ping of the old client times out and stays in flight
+	release := make(chan struct{})
+	defer close(release)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	stale := func() error {
+		<-release
+		return errors.New("unreachable")
+	}
+	if err := r.pings.wait(ctx, stale); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the ping to time out, got %v", err)
+	}
+
+	// the new client pings on its own rather than sharing the stale ping
+	if err := r.Dial("0"); err != nil {
+		t.Fatalf("expected the redial to succeed, got %v", err)
+	}
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := r.Ping(ctx); err != nil {
+		t.Errorf("expected the ping of the new client to succeed, got %v", err)
+	}
+}
@@ -1,13 +1,17 @@
 package dba
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
 	"log"
+	"math"
+	"strings"
+	"sync"
 
-	_ "github.com/go-sql-driver/mysql" // mysql driver
-	_ "github.com/lib/pq"              // postgresql driver
+	"github.com/go-sql-driver/mysql" // mysql driver
+	_ "github.com/lib/pq"            // postgresql driver
 
 	// TODO:(jackspirou) this sqlite driver is written in C, so it takes a very
 	// long time to build.
@@ -23,8 +27,12 @@ import (
 
 // SQL describes an sql database object.
 type SQL struct {
-	sql                            *gorp.DbMap
-	name, user, pass, addr, driver string
+	name, database, user, pass, addr, driver string
+	options                                  Options
+
+	// mu guards sql, which Dial sets while others may read it
+	mu  sync.RWMutex
+	sql *gorp.DbMap
 }
 
 // NewSQL creates a new sql database object.
@@ -51,16 +59,30 @@ func NewSQL(name, user, pass, addr, driver string) (*SQL, error) {
 	}
 
 	return &SQL{
-		name:   name,
-		user:   user,
-		pass:   pass,
-		addr:   addr,
-		driver: driver,
+		name:     name,
+		database: name,
+		user:     user,
+		pass:     pass,
+		addr:     addr,
+		driver:   driver,
 	}, nil
 }
 
+// SetOptions configures the connection pool, timeouts and TLS of the sql
+// database object. It must be called before Dial.
+func (s *SQL) SetOptions(o Options) {
+	s.options = o
+}
+
+// SetName names the sql database object, such as "analytics", for lookups
+// in a DatabaseAdmin. The object is named after the database it dials
+// otherwise.
+func (s *SQL) SetName(name string) {
+	s.name = name
+}
+
 // Name satisfies the Database interface.  The sql database object returns
-// the name of the last database the Dial method attempted to connect with.
+// the name it is looked up by.
 func (s *SQL) Name() string {
 	return s.name
 }
@@ -77,8 +99,10 @@ func (s *SQL) Dial(name string) error {
 	}()
 
 	// connection string
-	dial := fmt.Sprintf("user=%sdbname=%shost=%spassword=%s",
-		s.user, name, s.addr, s.pass)
+	dial, err := s.dsn(name)
+	if err != nil {
+		return err
+	}
 
 	// dial and establish database session
 	db, err := sql.Open(s.driver, dial)
@@ -86,8 +110,22 @@ func (s *SQL) Dial(name string) error {
 		return err
 	}
 
+	// size the connection pool
+	if s.options.MaxOpenConns > 0 {
+		db.SetMaxOpenConns(s.options.MaxOpenConns)
+	}
+	if s.options.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(s.options.MaxIdleConns)
+	}
+	if s.options.ConnMaxLifetime > 0 {
+		db.SetConnMaxLifetime(s.options.ConnMaxLifetime)
+	}
+	if s.options.ConnMaxIdleTime > 0 {
+		db.SetConnMaxIdleTime(s.options.ConnMaxIdleTime)
+	}
+
 	// set database session and dialect default settings
-	s.sql = &gorp.DbMap{
+	dbmap := &gorp.DbMap{
 		Db: db,
 		Dialect: gorp.MySQLDialect{
 			Engine:   "InnoDB",
@@ -95,18 +133,128 @@ func (s *SQL) Dial(name string) error {
 		},
 	}
 
+	s.mu.Lock()
+	s.sql = dbmap
+	s.mu.Unlock()
 	return nil
 }
 
+// dialed returns the gorp.DbMap the Dial method created, or nil if the
+// database has not been dialed.
+func (s *SQL) dialed() *gorp.DbMap {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.sql
+}
+
+// Ping satisfies the Database interface. The sql database object pings the
+// connection pool the Dial method created.
+func (s *SQL) Ping(ctx context.Context) error {
+	dbmap := s.dialed()
+	if dbmap == nil {
+		return fmt.Errorf("%w: sql %s", ErrNotDialed, s.name)
+	}
+	return Do(ctx, s, "ping", dbmap.Db.PingContext)
+}
+
+// Stats satisfies the Database interface. The sql database object returns
+// the stats of the connection pool the Dial method created.
+func (s *SQL) Stats() Stats {
+	dbmap := s.dialed()
+	if dbmap == nil {
+		return Stats{MaxOpenConns: s.options.MaxOpenConns}
+	}
+	stats := dbmap.Db.Stats()
+	return Stats{
+		MaxOpenConns: stats.MaxOpenConnections,
+		OpenConns:    stats.OpenConnections,
+		InUse:        stats.InUse,
+		Idle:         stats.Idle,
+		WaitCount:    stats.WaitCount,
+	}
+}
+
 // Close satisfies the Database interface.  The sql database object closes the
-// connection the Dial method created.
+// connection the Dial method created. Closing an undialed, or closed, sql
+// database object does nothing.
 func (s *SQL) Close() {
+	s.mu.Lock()
+	dbmap := s.sql
+	s.sql = nil
+	s.mu.Unlock()
+	if dbmap == nil {
+		return
+	}
+
 	defer func() { // catch any potential errors
 		if r := recover(); r != nil {
 			log.Fatalf("error closing sql database %s: %s", s.name, r)
 		}
 	}()
-	s.sql.Db.Close()
+	dbmap.Db.Close()
+}
+
+// dsn returns the connection string of a database. MySQL takes a DSN of its
+// own, the other drivers keyword and value pairs.
+func (s *SQL) dsn(name string) (string, error) {
+	if s.driver == "mysql" {
+		config := mysql.NewConfig()
+		config.User = s.user
+		config.Passwd = s.pass
+		config.Net = "tcp"
+		config.Addr = s.addr
+		config.DBName = name
+		config.Timeout = s.options.DialTimeout
+		config.ReadTimeout = s.options.ReadTimeout
+		config.WriteTimeout = s.options.WriteTimeout
+		if s.options.TLS != nil {
+			tlsConfig, err := s.options.TLS.Config()
+			if err != nil {
+				return "", err
+			}
+			// the registry is global, so key it on the unique name of the
+			// object rather than the database it dials
+			key := "dba-" + s.name
+			if err := mysql.RegisterTLSConfig(key, tlsConfig); err != nil {
+				return "", err
+			}
+			config.TLSConfig = key
+		}
+		return config.FormatDSN(), nil
+	}
+
+	pairs := []string{
+		"user=" + quote(s.user),
+		"dbname=" + quote(name),
+		"host=" + quote(s.addr),
+		"password=" + quote(s.pass),
+	}
+	if s.options.DialTimeout > 0 {
+		seconds := int(math.Ceil(s.options.DialTimeout.Seconds()))
+		pairs = append(pairs, fmt.Sprintf("connect_timeout=%d", seconds))
+	}
+	if t := s.options.TLS; t != nil {
+		mode := "verify-full"
+		if t.InsecureSkipVerify {
+			mode = "require"
+		}
+		pairs = append(pairs, "sslmode="+mode)
+		if len(t.CAFile) > 0 {
+			pairs = append(pairs, "sslrootcert="+quote(t.CAFile))
+		}
+		if len(t.CertFile) > 0 {
+			pairs = append(pairs, "sslcert="+quote(t.CertFile), "sslkey="+quote(t.KeyFile))
+		}
+	}
+	return strings.Join(pairs, " "), nil
+}
+
+// quote quotes a value of a keyword and value connection string.
+func quote(v string) string {
+	if len(v) > 0 && !strings.ContainsAny(v, ` '\`) {
+		return v
+	}
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(v) + "'"
 }
 
 func sqlDriver(driver string) bool {
@@ -0,0 +1,80 @@
+package dba
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+func TestQuote(t *testing.T) {
+	tests := []struct{ in, out string }{
+		{"app", "app"},
+		{"", "''"},
+		{"two words", "'two words'"},
+		{"it's", `'it\'s'`},
+		{`back\slash`, `'back\\slash'`},
+	}
+	for _, test := range tests {
+		if out := quote(test.in); out != test.out {
+			t.Errorf("quote(%q): expected %s, got %s", test.in, test.out, out)
+		}
+	}
+}
+
+func TestDSN(t *testing.T) {
+	s, err := NewSQL("app", "admin", "pa ss", "db.internal", "postgres")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetOptions(Options{
+		DialTimeout: 1500 * time.Millisecond,
+		TLS:         &TLS{CAFile: "/etc/ca.pem", CertFile: "/etc/cert.pem", KeyFile: "/etc/key.pem"},
+	})
+
+	dsn, err := s.dsn("app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "user=admin dbname=app host=db.internal password='pa ss' connect_timeout=2 sslmode=verify-full sslrootcert=/etc/ca.pem sslcert=/etc/cert.pem sslkey=/etc/key.pem"
+	if dsn != expected {
+		t.Errorf("expected %s, got %s", expected, dsn)
+	}
+
+	s.SetOptions(Options{TLS: &TLS{InsecureSkipVerify: true}})
+	if dsn, _ := s.dsn("app"); !strings.HasSuffix(dsn, " sslmode=require") {
+		t.Errorf("expected sslmode require when skipping verification, got %s", dsn)
+	}
+}
+
+func TestDSNMySQL(t *testing.T) {
+	s, err := NewSQL("app", "admin", "secret", "db.internal:3306", "mysql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetName("primary")
+	s.SetOptions(Options{
+		DialTimeout: time.Second,
+		ReadTimeout: 2 * time.Second,
+		TLS:         &TLS{ServerName: "db.internal"},
+	})
+
+	dsn, err := s.dsn("app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	config, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.User != "admin" || config.Passwd != "secret" || config.Addr != "db.internal:3306" || config.DBName != "app" {
+		t.Errorf("unexpected connection settings %+v", config)
+	}
+	if config.Timeout != time.Second || config.ReadTimeout != 2*time.Second {
+		t.Errorf("expected the dial and read timeouts, got %s and %s", config.Timeout, config.ReadTimeout)
+	}
+	if config.TLSConfig != "dba-primary" {
+		t.Errorf("expected the tls config to be keyed on the object name, got %s", config.TLSConfig)
+	}
+}
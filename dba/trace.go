@@ -0,0 +1,47 @@
+package dba
+
+import (
+	"context"
+
+	"github.com/blueprint/blueprint/trace"
+)
+
+// Do runs op, an operation on db, in a span that is a child of the span held
+// by ctx, such as the span of the request the operation is made for. The span
+// is named after the database system and op, such as "mysql insert users",
+// and is passed to fn in its context. The package traces its own dials,
+// pings and migrations the same way.
+//
+//	err := dba.Do(ctx, db, "insert users", func(ctx context.Context) error {
+//		return dba.GORP("app").Insert(user)
+//	})
+func Do(ctx context.Context, db Database, op string, fn func(ctx context.Context) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	system := System(db)
+	ctx, span := trace.Start(ctx, system+" "+op)
+	defer span.End()
+	span.SetAttribute("db.system", system)
+	span.SetAttribute("db.name", db.Name())
+	span.SetAttribute("db.operation", op)
+
+	err := fn(ctx)
+	span.SetError(err)
+	return err
+}
+
+// System returns the kind of a database, such as "mongodb", "redis", or the
+// driver of a SQL database.
+func System(db Database) string {
+	switch db := db.(type) {
+	case *MongoDB:
+		return "mongodb"
+	case *RedisDB:
+		return "redis"
+	case *SQL:
+		return db.driver
+	}
+	return "unknown"
+}
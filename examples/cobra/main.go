@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"log"
 
 	"github.com/spf13/cobra"
@@ -9,7 +10,7 @@ import (
 	"github.com/target/gophersaurus/router"
 )
 
-// Define the root and serve commands.
+// Define the root, serve and migrate commands.
 var (
 	RootCmd  = &cobra.Command{}
 	ServeCmd = &cobra.Command{
@@ -21,8 +22,22 @@ var (
 			log.Fatal(bootstrap.Server(router.NewMux(), register))
 		},
 	}
+	MigrateCmd = &cobra.Command{
+		Use:   "migrate [up [version] | down [steps] | status]",
+		Short: "Migrate SQL Database",
+		Long:  "Apply, Roll Back or List the Schema Migrations of a SQL Database",
+		Args:  cobra.MaximumNArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := bootstrap.Migrate(context.Background(), migrate, args...); err != nil {
+				log.Fatal(err)
+			}
+		},
+	}
 )
 
+// migrate holds the options of the migrate command.
+var migrate bootstrap.MigrateOptions
+
 // register takes a router and assocates HTTP endpoints to methods.
 func register(r router.Router) {
 	r.GET("/", home)
@@ -44,7 +59,14 @@ func home(resp http.ResponseWriter, req *http.Request) {
 	resp.WriteFormat(req, result)
 }
 
+func init() {
+	// bind migrate flags
+	MigrateCmd.Flags().StringVarP(&migrate.Database, "database", "d", "", "The sql database to migrate")
+	MigrateCmd.Flags().StringVar(&migrate.Dir, "dir", "migrations", "Where the .sql migrations are")
+	MigrateCmd.Flags().BoolVar(&migrate.DryRun, "dry-run", false, "Print the migrations instead of running them")
+}
+
 func main() {
-	RootCmd.AddCommand(ServeCmd)
+	RootCmd.AddCommand(ServeCmd, MigrateCmd)
 	RootCmd.Execute()
 }
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"log"
 
 	"github.com/spf13/cobra"
@@ -10,7 +11,7 @@ import (
 	"github.com/target/gophersaurus/router"
 )
 
-// Define the root and serve commands.
+// Define the root, serve and migrate commands.
 var (
 	RootCmd  = &cobra.Command{}
 	ServeCmd = &cobra.Command{
@@ -22,8 +23,22 @@ var (
 			log.Fatal(bootstrap.Server(router.NewMux(), register))
 		},
 	}
+	MigrateCmd = &cobra.Command{
+		Use:   "migrate [up [version] | down [steps] | status]",
+		Short: "Migrate SQL Database",
+		Long:  "Apply, Roll Back or List the Schema Migrations of a SQL Database",
+		Args:  cobra.MaximumNArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := bootstrap.Migrate(context.Background(), migrate, args...); err != nil {
+				log.Fatal(err)
+			}
+		},
+	}
 )
 
+// migrate holds the options of the migrate command.
+var migrate bootstrap.MigrateOptions
+
 // register takes a router and assocates HTTP endpoints to methods.
 func register(r router.Router) {
 	r.GET("/", home)
@@ -57,9 +72,17 @@ func init() {
 	// bind env flag
 	ServeCmd.Flags().StringP("env", "e", "dev", "The environment that we are running")
 	viper.BindPFlag("env", ServeCmd.Flags().Lookup("env"))
+
+	// bind migrate flags
+	MigrateCmd.Flags().StringVarP(&migrate.Database, "database", "d", "", "The sql database to migrate")
+	MigrateCmd.Flags().BoolVar(&migrate.DryRun, "dry-run", false, "Print the migrations instead of running them")
+
+	// bind migrations flag
+	MigrateCmd.Flags().String("migrations", "migrations", "Where the .sql migrations are")
+	viper.BindPFlag("migrations", MigrateCmd.Flags().Lookup("migrations"))
 }
 
 func main() {
-	RootCmd.AddCommand(ServeCmd)
+	RootCmd.AddCommand(ServeCmd, MigrateCmd)
 	RootCmd.Execute()
 }
@@ -0,0 +1,238 @@
+// Package health answers liveness and readiness probes, such as those of an
+// orchestrator. Liveness tells that the process is up and should not be
+// restarted, readiness that its dependencies are reachable and it may be sent
+// traffic.
+//
+//	health.Add("payments", func(ctx context.Context) error {
+//		return payments.Ping(ctx)
+//	})
+//	health.Default.Register(r)
+package health
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/blueprint/blueprint/dba"
+	"github.com/blueprint/blueprint/router"
+	http "github.com/blueprint/blueprint/transport"
+)
+
+// The statuses of checks and reports.
+const (
+	StatusUp   = "up"
+	StatusDown = "down"
+)
+
+// Check checks a dependency, returning an error if it is unhealthy. Checks
+// should give up once ctx is done.
+type Check func(ctx context.Context) error
+
+// Result is the outcome of a check.
+type Result struct {
+	Name     string  `json:"name" xml:"name" yaml:"name"`
+	Status   string  `json:"status" xml:"status" yaml:"status"`
+	Duration float64 `json:"duration_ms" xml:"duration_ms" yaml:"duration_ms"`
+	Error    string  `json:"error,omitempty" xml:"error,omitempty" yaml:"error,omitempty"`
+
+	// Stats describe the connection pool of a database check.
+	Stats *dba.Stats `json:"stats,omitempty" xml:"stats,omitempty" yaml:"stats,omitempty"`
+}
+
+// Report is the outcome of a probe. Its status is up only if all of its
+// checks are.
+type Report struct {
+	Status string   `json:"status" xml:"status" yaml:"status"`
+	Checks []Result `json:"checks" xml:"checks" yaml:"checks"`
+}
+
+// Health runs liveness and readiness checks.
+type Health struct {
+
+	// LivePath and ReadyPath are the routes of the probes, "/healthz" and
+	// "/readyz" by default.
+	LivePath  string
+	ReadyPath string
+
+	// Timeout limits how long each check may take, 2 seconds by default.
+	Timeout time.Duration
+
+	// Databases returns the databases readiness pings. If nil, the databases
+	// of the DatabaseAdmin held by the request context are pinged, those of
+	// the dba package unless set by DatabaseAdmin.Middleware.
+	Databases func() []dba.Database
+
+	// mu guards the checks
+	mu    sync.RWMutex
+	live  map[string]Check
+	ready map[string]Check
+}
+
+// New returns Health with the default paths and timeout.
+func New() *Health {
+	return &Health{
+		LivePath:  "/healthz",
+		ReadyPath: "/readyz",
+		Timeout:   2 * time.Second,
+		live:      map[string]Check{},
+		ready:     map[string]Check{},
+	}
+}
+
+// Default is used by Add and AddLive, and mounted by bootstrap.Server.
+var Default = New()
+
+// Add adds a readiness check to Default.
+func Add(name string, check Check) {
+	Default.Add(name, check)
+}
+
+// AddLive adds a liveness check to Default.
+func AddLive(name string, check Check) {
+	Default.AddLive(name, check)
+}
+
+// Add adds a readiness check, replacing any check of the same name.
+func (h *Health) Add(name string, check Check) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.ready[name] = check
+}
+
+// AddLive adds a liveness check, replacing any check of the same name.
+// Liveness failures get a process restarted, so they should only check the
+// process itself, such as for a deadlock, and never its dependencies.
+func (h *Health) AddLive(name string, check Check) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.live[name] = check
+}
+
+// Register routes the probes to LivePath and ReadyPath. The routes take the
+// middleware of the router, so use a router without API keys, such as one
+// returned by FlushMiddleware, if probes should not need one.
+func (h *Health) Register(r router.Router) {
+	r.GET(h.LivePath, h.Live)
+	r.GET(h.ReadyPath, h.Ready)
+}
+
+// Live answers liveness probes with the report of the liveness checks, and
+// 503 Service Unavailable if any of them is down.
+func (h *Health) Live(resp http.ResponseWriter, req *http.Request) {
+	h.mu.RLock()
+	checks := copyChecks(h.live, nil)
+	h.mu.RUnlock()
+
+	h.write(resp, req, h.Run(req.Context(), checks))
+}
+
+// Ready answers readiness probes with the report of a ping of each database,
+// along with the stats of its pool, and of the readiness checks, and 503
+// Service Unavailable if any of them is down.
+func (h *Health) Ready(resp http.ResponseWriter, req *http.Request) {
+	checks := map[string]Check{}
+	databases := h.Databases
+	if databases == nil {
+		databases = dba.AdminFrom(req.Context()).All
+	}
+
+	dbs := map[string]dba.Database{}
+	for _, db := range databases() {
+		name := dba.System(db) + " " + db.Name()
+		checks[name] = db.Ping
+		dbs[name] = db
+	}
+
+	h.mu.RLock()
+	checks = copyChecks(h.ready, checks)
+	h.mu.RUnlock()
+
+	report := h.Run(req.Context(), checks)
+	for i, result := range report.Checks {
+		if db, ok := dbs[result.Name]; ok {
+			stats := db.Stats()
+			report.Checks[i].Stats = &stats
+		}
+	}
+	h.write(resp, req, report)
+}
+
+// Run runs checks concurrently, each with the Timeout, and reports their
+// results sorted by name.
+func (h *Health) Run(ctx context.Context, checks map[string]Check) Report {
+	report := Report{Status: StatusUp, Checks: make([]Result, 0, len(checks))}
+	results := make(chan Result, len(checks))
+
+	for name, check := range checks {
+		go func(name string, check Check) {
+			results <- h.run(ctx, name, check)
+		}(name, check)
+	}
+	for range checks {
+		result := <-results
+		if result.Status == StatusDown {
+			report.Status = StatusDown
+		}
+		report.Checks = append(report.Checks, result)
+	}
+
+	sort.Slice(report.Checks, func(i, j int) bool { return report.Checks[i].Name < report.Checks[j].Name })
+	return report
+}
+
+// run runs a check with the Timeout. Checks that ignore their context are
+// left running and reported down once the Timeout passes.
+func (h *Health) run(ctx context.Context, name string, check Check) Result {
+	if h.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.Timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if p := recover(); p != nil {
+				done <- fmt.Errorf("panic: %v", p)
+			}
+		}()
+		done <- check(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	result := Result{Name: name, Status: StatusUp, Duration: float64(time.Since(start)) / float64(time.Millisecond)}
+	if err != nil {
+		result.Status, result.Error = StatusDown, err.Error()
+	}
+	return result
+}
+
+// write writes a report in the format of the request.
+func (h *Health) write(resp http.ResponseWriter, req *http.Request, report Report) {
+	resp.Header().Set("Cache-Control", "no-store")
+	if report.Status == StatusDown {
+		resp.Status(http.StatusServiceUnavailable)
+	}
+	resp.WriteFormat(req, report)
+}
+
+// copyChecks copies checks into dst, which is allocated if nil.
+func copyChecks(checks, dst map[string]Check) map[string]Check {
+	if dst == nil {
+		dst = make(map[string]Check, len(checks))
+	}
+	for name, check := range checks {
+		dst[name] = check
+	}
+	return dst
+}
@@ -0,0 +1,122 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	stdhttp "net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/blueprint/blueprint/dba"
+	"github.com/blueprint/blueprint/router"
+)
+
+// database is a dba.Database that pings with err.
+type database struct {
+	name string
+	err  error
+}
+
+func (d *database) Dial(name string) error { return nil }
+func (d *database) Name() string           { return d.name }
+func (d *database) Close()                 {}
+
+func (d *database) Ping(ctx context.Context) error {
+	return d.err
+}
+
+func (d *database) Stats() dba.Stats {
+	return dba.Stats{MaxOpenConns: 10, OpenConns: 3, InUse: 1, Idle: 2}
+}
+
+func probe(t *testing.T, mux *router.Mux, path string) (int, Report) {
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+
+	var report Report
+	if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+		t.Fatalf("%s: %s: %s", path, err, rec.Body.String())
+	}
+	return rec.Code, report
+}
+
+func TestHealth(t *testing.T) {
+	primary := &database{name: "primary"}
+	h := New()
+	h.Timeout = 50 * time.Millisecond
+	h.Databases = func() []dba.Database { return []dba.Database{primary} }
+	h.Add("queue", func(ctx context.Context) error { return nil })
+
+	mux := router.NewMux()
+	h.Register(mux)
+
+	code, report := probe(t, mux, "/readyz")
+	if code != stdhttp.StatusOK || report.Status != StatusUp || len(report.Checks) != 2 {
+		t.Fatalf("expected a ready report got %d %+v", code, report)
+	}
+	if report.Checks[0].Name != "queue" || report.Checks[1].Name != "unknown primary" {
+		t.Errorf("expected checks sorted by name got %+v", report.Checks)
+	}
+	if report.Checks[0].Stats != nil || report.Checks[1].Stats == nil || report.Checks[1].Stats.InUse != 1 {
+		t.Errorf("expected pool stats of the database only got %+v", report.Checks)
+	}
+
+	primary.err = errors.New("connection refused")
+	h.Add("stuck", func(ctx context.Context) error { select {} })
+	code, report = probe(t, mux, "/readyz")
+	if code != stdhttp.StatusServiceUnavailable || report.Status != StatusDown {
+		t.Fatalf("expected an unready report got %d %+v", code, report)
+	}
+
+	var statuses, reasons []string
+	for _, check := range report.Checks {
+		statuses = append(statuses, check.Status)
+		reasons = append(reasons, check.Error)
+	}
+	if want := []string{StatusUp, StatusDown, StatusDown}; !reflect.DeepEqual(statuses, want) {
+		t.Errorf("expected statuses %v got %v", want, statuses)
+	}
+	if want := []string{"", context.DeadlineExceeded.Error(), "connection refused"}; !reflect.DeepEqual(reasons, want) {
+		t.Errorf("expected errors %v got %v", want, reasons)
+	}
+
+	// liveness does not depend on the databases
+	code, report = probe(t, mux, "/healthz")
+	if code != stdhttp.StatusOK || report.Status != StatusUp || len(report.Checks) != 0 {
+		t.Errorf("expected a live report got %d %+v", code, report)
+	}
+
+	h.AddLive("panics", func(ctx context.Context) error { panic("deadlock") })
+	code, report = probe(t, mux, "/healthz")
+	if code != stdhttp.StatusServiceUnavailable || report.Checks[0].Error != "panic: deadlock" {
+		t.Errorf("expected a panicking check to be down got %d %+v", code, report)
+	}
+}
+
+func TestHealthAdmin(t *testing.T) {
+	analytics, err := dba.NewSQL("analytics", "admin", "secret", "warehouse.internal", "postgres")
+	if err != nil {
+		t.Fatal(err)
+	}
+	admin := dba.NewDatabaseAdmin()
+	if err := admin.Add(analytics); err != nil {
+		t.Fatal(err)
+	}
+
+	mux := router.NewMux()
+	mux.Middleware(admin.Middleware)
+	New().Register(mux)
+
+	// the database is not dialed, so it is down
+	code, report := probe(t, mux, "/readyz")
+	if code != stdhttp.StatusServiceUnavailable || len(report.Checks) != 1 || report.Checks[0].Name != "postgres analytics" {
+		t.Fatalf("expected the databases of the request admin got %d %+v", code, report)
+	}
+	if !strings.Contains(report.Checks[0].Error, dba.ErrNotDialed.Error()) {
+		t.Errorf("expected an undialed database got %+v", report.Checks[0])
+	}
+}
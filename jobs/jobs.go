@@ -0,0 +1,374 @@
+// Package jobs runs long running actions in the background. Requests are
+// answered with 202 Accepted and a Location header pointing at the status of
+// their job, which clients poll until a result is ready.
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/blueprint/blueprint/router"
+	http "github.com/blueprint/blueprint/transport"
+)
+
+// Error values follow the two lowercased words of the transport package.
+const (
+	BusyWorkers  = "busy workers"
+	CancelledJob = "cancelled job"
+	FinishedJob  = "finished job"
+	UnknownJob   = "unknown job"
+)
+
+var (
+	ErrBusyWorkers  = errors.New(BusyWorkers)
+	ErrCancelledJob = errors.New(CancelledJob)
+	ErrFinishedJob  = errors.New(FinishedJob)
+	ErrUnknownJob   = errors.New(UnknownJob)
+)
+
+func init() {
+	http.ApplyErrorCode(ErrBusyWorkers, http.StatusServiceUnavailable)
+	http.ApplyErrorCode(ErrCancelledJob, http.StatusGone)
+	http.ApplyErrorCode(ErrFinishedJob, http.StatusConflict)
+	http.ApplyErrorCode(ErrUnknownJob, http.StatusNotFound)
+}
+
+// Status is the state of a job.
+type Status string
+
+// Job states, a job is finished once it succeeded, failed or was cancelled.
+const (
+	Pending   Status = "pending"
+	Running   Status = "running"
+	Succeeded Status = "succeeded"
+	Failed    Status = "failed"
+	Cancelled Status = "cancelled"
+)
+
+// Finished reports if a job in this state is done.
+func (s Status) Finished() bool {
+	return s == Succeeded || s == Failed || s == Cancelled
+}
+
+// Job describes the state of a long running action. The result is not part of
+// the status, it is read from its own endpoint.
+type Job struct {
+	ID      string    `json:"id" xml:"id" yaml:"id"`
+	Status  Status    `json:"status" xml:"status" yaml:"status"`
+	Error   string    `json:"error,omitempty" xml:"error,omitempty" yaml:"error,omitempty"`
+	Code    int       `json:"code,omitempty" xml:"code,omitempty" yaml:"code,omitempty"`
+	Created time.Time `json:"created" xml:"created" yaml:"created"`
+	Updated time.Time `json:"updated" xml:"updated" yaml:"updated"`
+
+	Result interface{} `json:"-" xml:"-" yaml:"-"`
+}
+
+// Func is the work of a job. It returns the job's result and should return
+// early once ctx is cancelled.
+type Func func(ctx context.Context) (interface{}, error)
+
+// DefaultWorkers is the number of workers of pools created without a number.
+var DefaultWorkers = 4
+
+// Pool runs jobs on a bounded number of workers, keeping their state in a
+// Store. Jobs are queued until a worker is free, and rejected with
+// ErrBusyWorkers once the queue is full.
+type Pool struct {
+	// Path is the path job status is routed under by Register, and that
+	// Location headers point at.
+	Path string
+
+	store Store
+	queue chan *task
+	wg    sync.WaitGroup
+
+	// mu guards sending to the queue and every change of job state, so that
+	// cancelling a job never races a worker finishing it
+	mu      sync.Mutex
+	closed  bool
+	cancels map[string]context.CancelFunc
+}
+
+// task is a queued job along with its work.
+type task struct {
+	ctx context.Context
+	id  string
+	f   Func
+}
+
+// NewPool starts a pool of workers that queues up to size jobs.
+func NewPool(workers, size int, store Store) *Pool {
+	if workers <= 0 {
+		workers = DefaultWorkers
+	}
+
+	p := &Pool{
+		Path:    "/jobs",
+		store:   store,
+		queue:   make(chan *task, size),
+		cancels: map[string]context.CancelFunc{},
+	}
+
+	p.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go p.work()
+	}
+	return p
+}
+
+// Register routes the status, result and cancellation of jobs to Path.
+func (p *Pool) Register(r router.Router) {
+	r.GET(p.Path+"/:job", p.Show)
+	r.GET(p.Path+"/:job/result", p.Result)
+	r.DELETE(p.Path+"/:job", p.Cancel)
+}
+
+// Close stops accepting jobs, cancels those still running and waits for the
+// workers to stop.
+func (p *Pool) Close() {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return
+	}
+	p.closed = true
+	close(p.queue)
+	for _, cancel := range p.cancels {
+		cancel()
+	}
+	p.mu.Unlock()
+
+	p.wg.Wait()
+}
+
+// Submit queues work as a new job. The work gets the values of ctx, but is not
+// cancelled along with it, so it outlives the request that submitted it.
+func (p *Pool) Submit(ctx context.Context, f Func) (*Job, error) {
+	id, err := newID()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	job := &Job{ID: id, Status: Pending, Created: now, Updated: now}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// only Submit sends to the queue, so a free slot stays free
+	if p.closed || len(p.queue) == cap(p.queue) {
+		return nil, ErrBusyWorkers
+	}
+
+	if err := p.store.Save(ctx, job); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	p.cancels[id] = cancel
+	p.queue <- &task{ctx: ctx, id: id, f: f}
+	return job, nil
+}
+
+// Accept submits work for a request and answers 202 Accepted with the status
+// of its job, and a Location header pointing at it.
+func (p *Pool) Accept(resp http.ResponseWriter, req *http.Request, f Func) {
+	job, err := p.Submit(req.Context(), f)
+	if err != nil {
+		resp.WriteErrs(req, err)
+		return
+	}
+
+	resp.Header().Set("Location", p.location(job))
+	resp.Status(http.StatusAccepted)
+	resp.WriteFormat(req, job)
+}
+
+// Async returns an Action that runs the work prepare returns for a request as
+// a job. Requests are read by prepare, before they are answered, so errors
+// such as invalid input are still written to the response.
+func (p *Pool) Async(prepare func(req *http.Request) (Func, error)) http.HandlerFunc {
+	return func(resp http.ResponseWriter, req *http.Request) {
+		f, err := prepare(req)
+		if err != nil {
+			resp.WriteErrs(req, err)
+			return
+		}
+		p.Accept(resp, req, f)
+	}
+}
+
+// Show is a GET request for the status of a job.
+func (p *Pool) Show(resp http.ResponseWriter, req *http.Request) {
+	job, err := p.find(req)
+	if err != nil {
+		resp.WriteErrs(req, err)
+		return
+	}
+
+	resp.WriteFormat(req, job)
+}
+
+// Result is a GET request for the result of a job. Jobs that are not finished
+// are answered with 202 Accepted and their status.
+func (p *Pool) Result(resp http.ResponseWriter, req *http.Request) {
+	job, err := p.find(req)
+	if err != nil {
+		resp.WriteErrs(req, err)
+		return
+	}
+
+	switch job.Status {
+	case Succeeded:
+		resp.WriteFormat(req, job.Result)
+	case Failed:
+		resp.Status(job.Code)
+		resp.WriteErrs(req, errors.New(job.Error))
+	case Cancelled:
+		resp.WriteErrs(req, ErrCancelledJob)
+	default:
+		resp.Header().Set("Location", p.location(job))
+		resp.Status(http.StatusAccepted)
+		resp.WriteFormat(req, job)
+	}
+}
+
+// Cancel is a DELETE request for cancelling a job. Jobs are cancelled on the
+// instance running them, others only see the job as cancelled once its work
+// returns.
+func (p *Pool) Cancel(resp http.ResponseWriter, req *http.Request) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	job, err := p.find(req)
+	if err != nil {
+		resp.WriteErrs(req, err)
+		return
+	}
+
+	if job.Status.Finished() {
+		resp.WriteErrs(req, ErrFinishedJob)
+		return
+	}
+
+	if cancel, ok := p.cancels[job.ID]; ok {
+		cancel()
+	}
+
+	job.Status = Cancelled
+	job.Updated = time.Now().UTC()
+	if err := p.store.Save(req.Context(), job); err != nil {
+		resp.WriteErrs(req, err)
+		return
+	}
+
+	resp.WriteFormat(req, job)
+}
+
+// find loads the job of a request.
+func (p *Pool) find(req *http.Request) (*Job, error) {
+	id := req.Param("job")
+	if len(id) == 0 {
+		return nil, ErrUnknownJob
+	}
+	return p.store.Find(req.Context(), id)
+}
+
+// location returns the path of a job's status.
+func (p *Pool) location(job *Job) string {
+	return p.Path + "/" + job.ID
+}
+
+// work runs queued jobs until the pool is closed.
+func (p *Pool) work() {
+	defer p.wg.Done()
+	for t := range p.queue {
+		p.run(t)
+	}
+}
+
+// run runs a job, unless it was cancelled while it was queued.
+func (p *Pool) run(t *task) {
+	defer func() {
+		p.mu.Lock()
+		p.cancels[t.id]()
+		delete(p.cancels, t.id)
+		p.mu.Unlock()
+	}()
+
+	started := p.update(t, func(job *Job) bool {
+		if job.Status != Pending {
+			return false
+		}
+
+		// jobs of a closed pool are cancelled before they start
+		job.Status = Running
+		if t.ctx.Err() != nil {
+			job.Status = Cancelled
+		}
+		return true
+	})
+	if !started || t.ctx.Err() != nil {
+		return
+	}
+
+	result, err := call(t.ctx, t.f)
+
+	p.update(t, func(job *Job) bool {
+		switch {
+		case job.Status == Cancelled || errors.Is(t.ctx.Err(), context.Canceled):
+			job.Status = Cancelled
+		case err != nil:
+			code, ok := http.StatusCode(err)
+			if !ok {
+				code = http.StatusInternalServerError
+			}
+			job.Status, job.Error, job.Code = Failed, err.Error(), code
+		default:
+			job.Status, job.Result = Succeeded, result
+		}
+		return true
+	})
+}
+
+// update changes the stored state of a task's job, if change reports a
+// change, and reports if the change was saved.
+func (p *Pool) update(t *task, change func(job *Job) bool) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// the state of cancelled jobs is still saved
+	ctx := context.WithoutCancel(t.ctx)
+
+	job, err := p.store.Find(ctx, t.id)
+	if err != nil || !change(job) {
+		return false
+	}
+
+	job.Updated = time.Now().UTC()
+	return p.store.Save(ctx, job) == nil
+}
+
+// call runs the work of a job, turning panics into errors.
+func call(ctx context.Context, f Func) (result interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("jobs: job panicked: %v", r)
+		}
+	}()
+	return f(ctx)
+}
+
+// newID returns a random job id.
+func newID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,150 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	stdhttp "net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	http "github.com/blueprint/blueprint/transport"
+	"github.com/julienschmidt/httprouter"
+)
+
+// request executes a pool action for a job.
+func request(method, uri, id, format string, action http.HandlerFunc) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, uri, nil)
+	rec := httptest.NewRecorder()
+	action.ServeHTTP(http.NewResponse(rec, format), http.NewRequest(r, httprouter.Params{{Key: "job", Value: id}}))
+	return rec
+}
+
+// wait polls the status of a job until it is finished.
+func wait(t *testing.T, p *Pool, id string) *Job {
+	for i := 0; i < 200; i++ {
+		job, err := p.store.Find(context.Background(), id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if job.Status.Finished() {
+			return job
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("job %s did not finish", id)
+	return nil
+}
+
+func TestAccept(t *testing.T) {
+	p := NewPool(2, 10, NewMemoryStore(time.Minute))
+	defer p.Close()
+
+	action := p.Async(func(req *http.Request) (Func, error) {
+		return func(ctx context.Context) (interface{}, error) {
+			return map[string]int{"rows": 3}, nil
+		}, nil
+	})
+
+	rec := request("POST", "/reports", "", "json", action)
+	if rec.Code != stdhttp.StatusAccepted {
+		t.Fatalf("expected status %d got %d", stdhttp.StatusAccepted, rec.Code)
+	}
+
+	var job Job
+	if err := json.Unmarshal(rec.Body.Bytes(), &job); err != nil {
+		t.Fatal(err)
+	}
+	if location := rec.Header().Get("Location"); location != "/jobs/"+job.ID {
+		t.Errorf("expected location /jobs/%s got %s", job.ID, location)
+	}
+
+	wait(t, p, job.ID)
+	rec = request("GET", "/jobs/"+job.ID+"/result", job.ID, "json", p.Result)
+	if body := strings.TrimSpace(rec.Body.String()); body != `{"rows":3}` {
+		t.Errorf("expected result got %s", body)
+	}
+
+	for _, format := range []string{"json", "xml", "yml"} {
+		rec := request("GET", "/jobs/"+job.ID, job.ID, format, p.Show)
+		if rec.Code != stdhttp.StatusOK || !strings.Contains(rec.Body.String(), "succeeded") {
+			t.Errorf("%s: expected succeeded status got %d %s", format, rec.Code, rec.Body.String())
+		}
+	}
+}
+
+func TestFailed(t *testing.T) {
+	p := NewPool(1, 1, NewMemoryStore(0))
+	defer p.Close()
+
+	job, err := p.Submit(context.Background(), func(ctx context.Context) (interface{}, error) {
+		return nil, http.ErrInvalidInput
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if job = wait(t, p, job.ID); job.Status != Failed || job.Code != stdhttp.StatusBadRequest {
+		t.Errorf("expected failed job with code 400 got %+v", job)
+	}
+	if rec := request("GET", "/jobs/"+job.ID+"/result", job.ID, "json", p.Result); rec.Code != stdhttp.StatusBadRequest {
+		t.Errorf("expected status %d got %d", stdhttp.StatusBadRequest, rec.Code)
+	}
+}
+
+func TestCancel(t *testing.T) {
+	p := NewPool(1, 1, NewMemoryStore(0))
+	defer p.Close()
+
+	started := make(chan bool)
+	job, _ := p.Submit(context.Background(), func(ctx context.Context) (interface{}, error) {
+		close(started)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	<-started
+
+	if rec := request("GET", "/jobs/"+job.ID+"/result", job.ID, "json", p.Result); rec.Code != stdhttp.StatusAccepted {
+		t.Errorf("expected status %d got %d", stdhttp.StatusAccepted, rec.Code)
+	}
+
+	if rec := request("DELETE", "/jobs/"+job.ID, job.ID, "json", p.Cancel); rec.Code != stdhttp.StatusOK {
+		t.Errorf("expected status %d got %d", stdhttp.StatusOK, rec.Code)
+	}
+	if job = wait(t, p, job.ID); job.Status != Cancelled {
+		t.Errorf("expected cancelled job got %s", job.Status)
+	}
+
+	if rec := request("DELETE", "/jobs/"+job.ID, job.ID, "json", p.Cancel); rec.Code != stdhttp.StatusConflict {
+		t.Errorf("expected status %d got %d", stdhttp.StatusConflict, rec.Code)
+	}
+	if rec := request("GET", "/jobs/missing", "missing", "json", p.Show); rec.Code != stdhttp.StatusNotFound {
+		t.Errorf("expected status %d got %d", stdhttp.StatusNotFound, rec.Code)
+	}
+}
+
+func TestBusyWorkers(t *testing.T) {
+	p := NewPool(1, 1, NewMemoryStore(0))
+	release := make(chan bool)
+	defer func() {
+		close(release)
+		p.Close()
+	}()
+
+	block := func(ctx context.Context) (interface{}, error) {
+		<-release
+		return nil, nil
+	}
+
+	// one job runs and one is queued, so the next is rejected
+	var err error
+	for i := 0; i < 3 && err == nil; i++ {
+		_, err = p.Submit(context.Background(), block)
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !errors.Is(err, ErrBusyWorkers) {
+		t.Errorf("expected %s got %v", ErrBusyWorkers, err)
+	}
+}
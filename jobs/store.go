@@ -0,0 +1,128 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/blueprint/blueprint/dba"
+	"gopkg.in/redis.v3"
+)
+
+// Store keeps the state and result of jobs. Find returns ErrUnknownJob for
+// jobs it does not hold.
+type Store interface {
+	Save(ctx context.Context, job *Job) error
+	Find(ctx context.Context, id string) (*Job, error)
+}
+
+// MemoryStore keeps jobs in memory, for a single instance.
+type MemoryStore struct {
+	// TTL is how long finished jobs are kept, forever if zero.
+	TTL time.Duration
+
+	mu   sync.Mutex
+	jobs map[string]Job
+}
+
+// NewMemoryStore returns a new MemoryStore keeping finished jobs for ttl.
+func NewMemoryStore(ttl time.Duration) *MemoryStore {
+	return &MemoryStore{TTL: ttl, jobs: map[string]Job{}}
+}
+
+// Save satisfies the Store interface. Expired jobs are removed on save.
+func (m *MemoryStore) Save(ctx context.Context, job *Job) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.TTL > 0 {
+		expired := time.Now().UTC().Add(-m.TTL)
+		for id, j := range m.jobs {
+			if j.Status.Finished() && j.Updated.Before(expired) {
+				delete(m.jobs, id)
+			}
+		}
+	}
+
+	m.jobs[job.ID] = *job
+	return nil
+}
+
+// Find satisfies the Store interface.
+func (m *MemoryStore) Find(ctx context.Context, id string) (*Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	job, ok := m.jobs[id]
+	if !ok {
+		return nil, ErrUnknownJob
+	}
+	return &job, nil
+}
+
+// RedisStore keeps jobs in a redis database, so that the status of jobs can
+// be read from every instance sharing it. Results are stored as JSON.
+type RedisStore struct {
+	// Prefix is put in front of job ids to build redis keys.
+	Prefix string
+
+	// TTL is how long jobs are kept, forever if zero.
+	TTL time.Duration
+
+	db *dba.RedisDB
+}
+
+// NewRedisStore returns a new RedisStore using a dialed redis database.
+func NewRedisStore(db *dba.RedisDB, ttl time.Duration) *RedisStore {
+	return &RedisStore{Prefix: "jobs:", TTL: ttl, db: db}
+}
+
+// redisJob is a job as it is stored in redis, along with its result.
+type redisJob struct {
+	Job
+	Result json.RawMessage `json:"result,omitempty"`
+}
+
+// Save satisfies the Store interface.
+func (r *RedisStore) Save(ctx context.Context, job *Job) error {
+	stored := redisJob{Job: *job}
+	if job.Result != nil {
+		result, err := json.Marshal(job.Result)
+		if err != nil {
+			return err
+		}
+		stored.Result = result
+	}
+
+	b, err := json.Marshal(stored)
+	if err != nil {
+		return err
+	}
+	return r.db.Client().Set(r.Prefix+job.ID, b, r.TTL).Err()
+}
+
+// Find satisfies the Store interface. Results are returned as they were
+// decoded from JSON.
+func (r *RedisStore) Find(ctx context.Context, id string) (*Job, error) {
+	b, err := r.db.Client().Get(r.Prefix + id).Bytes()
+	if err == redis.Nil {
+		return nil, ErrUnknownJob
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var stored redisJob
+	if err := json.Unmarshal(b, &stored); err != nil {
+		return nil, err
+	}
+
+	job := stored.Job
+	if len(stored.Result) > 0 {
+		if err := json.Unmarshal(stored.Result, &job.Result); err != nil {
+			return nil, err
+		}
+	}
+	return &job, nil
+}
@@ -0,0 +1,283 @@
+// Package metrics records HTTP request metrics and exposes them, along with
+// database pool gauges, in the Prometheus text format.
+//
+//	m := metrics.New()
+//	r.Middleware(m.Middleware)
+//	m.Register(r)
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/blueprint/blueprint/dba"
+	"github.com/blueprint/blueprint/router"
+	http "github.com/blueprint/blueprint/transport"
+)
+
+// MediaText is the media type of the Prometheus text format.
+const MediaText = "text/plain; version=0.0.4; charset=utf-8"
+
+// DefaultBuckets are the upper bounds of request latency histograms, in
+// seconds.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// DefaultSizeBuckets are the upper bounds of response size histograms, in
+// bytes.
+var DefaultSizeBuckets = []float64{100, 1000, 10000, 100000, 1e6, 1e7}
+
+// Metrics records the requests of the routes it is middleware for.
+type Metrics struct {
+
+	// Path is the route metrics are exposed on, "/metrics" by default.
+	Path string
+
+	// Buckets and SizeBuckets are the histogram upper bounds of request
+	// latencies and response sizes. Change them before the first request.
+	Buckets     []float64
+	SizeBuckets []float64
+
+	// Databases returns the databases pool gauges are written for, dba.All
+	// by default.
+	Databases func() []dba.Database
+
+	inFlight int64
+
+	// mu guards the series and gauges
+	mu     sync.Mutex
+	series map[series]*stats
+	gauges []gauge
+}
+
+// series identifies the requests of a route with the same method and status
+// class.
+type series struct {
+	method string
+	route  string
+	status string
+}
+
+// stats are the recorded requests of a series.
+type stats struct {
+	count    uint64
+	duration *histogram
+	size     *histogram
+}
+
+// gauge is a custom gauge.
+type gauge struct {
+	name  string
+	help  string
+	value func() float64
+}
+
+// New returns Metrics with the default path and buckets.
+func New() *Metrics {
+	return &Metrics{
+		Path:        "/metrics",
+		Buckets:     DefaultBuckets,
+		SizeBuckets: DefaultSizeBuckets,
+		Databases:   dba.All,
+		series:      map[series]*stats{},
+	}
+}
+
+// Middleware records the requests of the routes it wraps, labeled by route
+// pattern, method and status class, such as "2xx". It satisfies the
+// router.Middleware type.
+func (m *Metrics) Middleware(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		atomic.AddInt64(&m.inFlight, 1)
+		defer atomic.AddInt64(&m.inFlight, -1)
+
+		start := time.Now()
+		rw := router.NewResponseWriter(resp)
+		h.ServeHTTP(http.WrapResponse(resp, rw), req)
+
+		m.observe(req.Method, http.RouteFrom(req.Context()), rw.Status(), rw.Size(), time.Since(start))
+	})
+}
+
+// Gauge registers a custom gauge, read each time metrics are exposed. Names
+// should follow Prometheus conventions, such as "jobs_queued".
+func (m *Metrics) Gauge(name, help string, value func() float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.gauges = append(m.gauges, gauge{name: name, help: help, value: value})
+}
+
+// Register routes the metrics to Path. The route takes the middleware of the
+// router, so use a router without API keys, such as one returned by
+// FlushMiddleware, if scrapers should not need one.
+func (m *Metrics) Register(r router.Router) {
+	r.GET(m.Path, m.Serve)
+}
+
+// Serve writes the metrics in the Prometheus text format.
+func (m *Metrics) Serve(resp http.ResponseWriter, req *http.Request) {
+	resp.Header().Set("Content-Type", MediaText)
+	resp.WriteHeader(http.StatusOK)
+	m.WriteTo(resp)
+}
+
+// observe records a request.
+func (m *Metrics) observe(method, route string, status, size int, d time.Duration) {
+	if status == 0 {
+		status = http.StatusOK // nothing written is an empty 200 response
+	}
+	if len(route) == 0 {
+		route = "unknown"
+	}
+	key := series{method: method, route: route, status: strconv.Itoa(status/100) + "xx"}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.series[key]
+	if !ok {
+		s = &stats{duration: newHistogram(m.Buckets), size: newHistogram(m.SizeBuckets)}
+		m.series[key] = s
+	}
+	s.count++
+	s.duration.observe(d.Seconds())
+	s.size.observe(float64(size))
+}
+
+// WriteTo writes the metrics in the Prometheus text format. It satisfies the
+// io.WriterTo interface.
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	var b strings.Builder
+
+	m.mu.Lock()
+	keys := make([]series, 0, len(m.series))
+	for key := range m.series {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].route != keys[j].route {
+			return keys[i].route < keys[j].route
+		}
+		if keys[i].method != keys[j].method {
+			return keys[i].method < keys[j].method
+		}
+		return keys[i].status < keys[j].status
+	})
+
+	header(&b, "http_requests_total", "counter", "Requests handled, by route pattern, method and status class.")
+	for _, key := range keys {
+		sample(&b, "http_requests_total", key.labels(), float64(m.series[key].count))
+	}
+	header(&b, "http_request_duration_seconds", "histogram", "Request latencies in seconds.")
+	for _, key := range keys {
+		m.series[key].duration.write(&b, "http_request_duration_seconds", key.labels())
+	}
+	header(&b, "http_response_size_bytes", "histogram", "Response body sizes in bytes.")
+	for _, key := range keys {
+		m.series[key].size.write(&b, "http_response_size_bytes", key.labels())
+	}
+	gauges := append([]gauge(nil), m.gauges...)
+	m.mu.Unlock()
+
+	header(&b, "http_requests_in_flight", "gauge", "Requests being handled.")
+	sample(&b, "http_requests_in_flight", nil, float64(atomic.LoadInt64(&m.inFlight)))
+
+	if m.Databases != nil {
+		pools(&b, m.Databases())
+	}
+
+	for _, g := range gauges {
+		header(&b, g.name, "gauge", g.help)
+		sample(&b, g.name, nil, g.value())
+	}
+
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+// labels returns the labels of a series.
+func (s series) labels() []label {
+	return []label{{"method", s.method}, {"route", s.route}, {"status", s.status}}
+}
+
+// label is a name and value pair of a sample.
+type label struct {
+	name  string
+	value string
+}
+
+// header writes the HELP and TYPE lines of a metric.
+func header(b *strings.Builder, name, typ, help string) {
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+// sample writes a sample line.
+func sample(b *strings.Builder, name string, labels []label, value float64) {
+	b.WriteString(name)
+	if len(labels) > 0 {
+		b.WriteByte('{')
+		for i, l := range labels {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			b.WriteString(l.name + `="` + escape(l.value) + `"`)
+		}
+		b.WriteByte('}')
+	}
+	b.WriteString(" " + format(value) + "\n")
+}
+
+// escape escapes a label value.
+func escape(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v)
+}
+
+// format formats a sample value.
+func format(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// histogram counts observations in buckets.
+type histogram struct {
+	bounds []float64
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+func newHistogram(bounds []float64) *histogram {
+	return &histogram{bounds: bounds, counts: make([]uint64, len(bounds))}
+}
+
+// observe adds a value to the first bucket it fits.
+func (h *histogram) observe(v float64) {
+	h.count++
+	h.sum += v
+	if i := sort.SearchFloat64s(h.bounds, v); i < len(h.bounds) {
+		h.counts[i]++
+	}
+}
+
+// write writes the cumulative buckets, sum and count of the histogram.
+func (h *histogram) write(b *strings.Builder, name string, labels []label) {
+	var cumulative uint64
+	for i, bound := range h.bounds {
+		cumulative += h.counts[i]
+		sample(b, name+"_bucket", append(labels, label{"le", format(bound)}), float64(cumulative))
+	}
+	sample(b, name+"_bucket", append(labels, label{"le", "+Inf"}), float64(h.count))
+	sample(b, name+"_sum", labels, h.sum)
+	sample(b, name+"_count", labels, float64(h.count))
+}
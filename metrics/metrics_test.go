@@ -0,0 +1,116 @@
+package metrics
+
+import (
+	stdhttp "net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/blueprint/blueprint/dba"
+	"github.com/blueprint/blueprint/router"
+	http "github.com/blueprint/blueprint/transport"
+)
+
+func TestMetrics(t *testing.T) {
+	m := New()
+	m.Gauge("jobs_queued", "Jobs waiting for a worker.", func() float64 { return 7 })
+
+	mux := router.NewMux()
+	mux.Middleware(m.Middleware)
+	mux.GET("/users/:user", func(resp http.ResponseWriter, req *http.Request) {
+		if req.Param("user") == "missing" {
+			resp.WriteErrs(req, http.ErrInvalidID)
+			return
+		}
+		resp.WriteJSON(false, "gopher")
+	})
+	m.Register(mux.FlushMiddleware(""))
+
+	for _, path := range []string{"/users/1", "/users/2", "/users/missing"} {
+		mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if rec.Code != stdhttp.StatusOK || rec.Header().Get("Content-Type") != MediaText {
+		t.Fatalf("unexpected response %d %s", rec.Code, rec.Header().Get("Content-Type"))
+	}
+
+	body := rec.Body.String()
+	for _, line := range []string{
+		"# TYPE http_requests_total counter",
+		`http_requests_total{method="GET",route="/users/:user",status="2xx"} 2`,
+		`http_requests_total{method="GET",route="/users/:user",status="4xx"} 1`,
+		`http_request_duration_seconds_bucket{method="GET",route="/users/:user",status="2xx",le="+Inf"} 2`,
+		`http_request_duration_seconds_count{method="GET",route="/users/:user",status="4xx"} 1`,
+		`http_response_size_bytes_bucket{method="GET",route="/users/:user",status="2xx",le="100"} 2`,
+		`http_response_size_bytes_sum{method="GET",route="/users/:user",status="2xx"} 16`,
+		"http_requests_in_flight 0",
+		"jobs_queued 7",
+	} {
+		if !strings.Contains(body, line+"\n") {
+			t.Errorf("expected the metrics to hold %q\n%s", line, body)
+		}
+	}
+	if strings.Contains(body, `route="/metrics"`) {
+		t.Error("expected the metrics route to not record itself")
+	}
+}
+
+func TestMetricsPools(t *testing.T) {
+	sql, err := dba.NewSQL("app", "admin", "secret", "db.internal", "postgres")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sql.SetOptions(dba.Options{MaxOpenConns: 20})
+	redis, err := dba.NewRedis(2, "", "", "cache.internal", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mongo, err := dba.NewMongoDB("docs", "admin", "secret", "mongo.internal")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var b strings.Builder
+	pools(&b, []dba.Database{sql, redis, mongo})
+
+	body := b.String()
+	for _, line := range []string{
+		"# TYPE dba_sql_connections gauge",
+		`dba_sql_connections{database="app",state="in_use"} 0`,
+		`dba_sql_max_open_connections{database="app"} 20`,
+		`dba_sql_waits_total{database="app"} 0`,
+		`dba_redis_connections{database="2",state="idle"} 0`,
+		`dba_redis_max_open_connections{database="2"} 10`,
+		`dba_redis_wait_timeouts_total{database="2"} 0`,
+	} {
+		if !strings.Contains(body, line+"\n") {
+			t.Errorf("expected the pools to hold %q\n%s", line, body)
+		}
+	}
+	if strings.Contains(body, "docs") || strings.Contains(body, "dba_sql_wait_timeouts_total") {
+		t.Errorf("expected no mongodb pools or sql wait timeouts\n%s", body)
+	}
+}
+
+func TestMetricsResponse(t *testing.T) {
+	m := New()
+	h := m.Middleware(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		resp.WriteJSON(false, "gone")
+	}))
+
+	rec := httptest.NewRecorder()
+	resp := http.NewResponse(rec, "json")
+	resp.Status(stdhttp.StatusGone)
+	h.ServeHTTP(resp, http.NewRequest(httptest.NewRequest("GET", "/gone", nil), nil))
+	if rec.Code != stdhttp.StatusGone {
+		t.Errorf("expected the status set before the middleware got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	m.Serve(http.NewResponse(rec, "json"), http.NewRequest(httptest.NewRequest("GET", "/metrics", nil), nil))
+	if line := `http_requests_total{method="GET",route="unknown",status="4xx"} 1`; !strings.Contains(rec.Body.String(), line) {
+		t.Errorf("expected the metrics to hold %q\n%s", line, rec.Body)
+	}
+}
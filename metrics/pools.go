@@ -0,0 +1,59 @@
+package metrics
+
+import (
+	"strings"
+
+	"github.com/blueprint/blueprint/dba"
+)
+
+// pools writes the connection pool gauges of the SQL and Redis databases,
+// labeled by database name. The mongodb driver keeps socket stats for the
+// whole process rather than per database, so MongoDB pools are left out.
+func pools(b *strings.Builder, dbs []dba.Database) {
+	var sqls, redises []dba.Database
+	for _, db := range dbs {
+		switch db.(type) {
+		case *dba.SQL:
+			sqls = append(sqls, db)
+		case *dba.RedisDB:
+			redises = append(redises, db)
+		}
+	}
+
+	pool(b, "sql", "SQL", sqls)
+	pool(b, "redis", "Redis", redises)
+}
+
+// pool writes the gauges of the databases of a system, if there are any.
+// Waits that timed out are only counted by Redis.
+func pool(b *strings.Builder, system, title string, dbs []dba.Database) {
+	if len(dbs) == 0 {
+		return
+	}
+
+	stats := make([]dba.Stats, len(dbs))
+	for i, db := range dbs {
+		stats[i] = db.Stats()
+	}
+	prefix := "dba_" + system + "_"
+
+	header(b, prefix+"connections", "gauge", title+" pool connections, by state.")
+	for i, db := range dbs {
+		sample(b, prefix+"connections", []label{{"database", db.Name()}, {"state", "in_use"}}, float64(stats[i].InUse))
+		sample(b, prefix+"connections", []label{{"database", db.Name()}, {"state", "idle"}}, float64(stats[i].Idle))
+	}
+	header(b, prefix+"max_open_connections", "gauge", title+" pool size, zero for the default of the driver.")
+	for i, db := range dbs {
+		sample(b, prefix+"max_open_connections", []label{{"database", db.Name()}}, float64(stats[i].MaxOpenConns))
+	}
+	header(b, prefix+"waits_total", "counter", "Waits for a free "+title+" pool connection.")
+	for i, db := range dbs {
+		sample(b, prefix+"waits_total", []label{{"database", db.Name()}}, float64(stats[i].WaitCount))
+	}
+	if system == "redis" {
+		header(b, prefix+"wait_timeouts_total", "counter", "Waits for a free "+title+" pool connection that timed out.")
+		for i, db := range dbs {
+			sample(b, prefix+"wait_timeouts_total", []label{{"database", db.Name()}}, float64(stats[i].Timeouts))
+		}
+	}
+}
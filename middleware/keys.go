@@ -9,12 +9,16 @@ import (
 
 // Keys describes API keys.
 type Keys struct {
-	success http.Handler
-	keymap  map[string][]string
+	success    http.Handler
+	keymap     map[string][]string
+	privileged map[string]bool
 }
 
 // NewKeys returns takes a map of string to string and returns a Keys object.
-func NewKeys(keys map[string][]string) Keys {
+//
+// It also takes optional keys that are privileged to perform destructive
+// actions, such as purging trashed items.
+func NewKeys(keys map[string][]string, privileged ...string) Keys {
 	for _, whitelist := range keys {
 		for i, uri := range whitelist {
 			if uri == "localhost" {
@@ -22,7 +26,12 @@ func NewKeys(keys map[string][]string) Keys {
 			}
 		}
 	}
-	return Keys{keymap: keys}
+
+	k := Keys{keymap: keys, privileged: map[string]bool{}}
+	for _, key := range privileged {
+		k.privileged[key] = true
+	}
+	return k
 }
 
 // Do takes a handler and executes key middleware.
@@ -66,7 +75,7 @@ func (k Keys) ServeHTTP(resp http.ResponseWriter, req *http.Request) {
 			resp.WriteErrs(req, err)
 			return
 		}
-		k.success.ServeHTTP(resp, req)
+		k.serve(resp, req, key)
 		return
 	}
 
@@ -76,8 +85,14 @@ func (k Keys) ServeHTTP(resp http.ResponseWriter, req *http.Request) {
 			resp.WriteErrs(req, err)
 			return
 		}
-		k.success.ServeHTTP(resp, req)
+		k.serve(resp, req, key)
 		return
 	}
 	resp.WriteErrs(req, errors.New(http.InvalidPermission))
 }
+
+// serve passes an authorized request on, along with the API key it used.
+func (k Keys) serve(resp http.ResponseWriter, req *http.Request, key string) {
+	req.SetContext(http.WithAPIKey(req.Context(), http.APIKey{Key: key, Privileged: k.privileged[key]}))
+	k.success.ServeHTTP(resp, req)
+}
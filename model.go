@@ -1,5 +1,7 @@
 package blueprint
 
+import "context"
+
 // Model represents a data model used by resource controllers to automate
 // RESTful CRUD operations.
 type Model interface {
@@ -14,3 +16,108 @@ type Model interface {
 	Delete() error
 	Validate() error
 }
+
+// ContextModel is implemented by models whose queries take a context.
+// Resource controllers call these methods with the request context in place of
+// their Model counterparts, so request deadlines and cancellation reach the
+// database.
+type ContextModel interface {
+	FindAllContext(ctx context.Context) ([]Model, error)
+	FindByIDContext(ctx context.Context, id string) error
+	FindAllByOwnerContext(ctx context.Context, owner Model) ([]Model, error)
+	SaveContext(ctx context.Context) error
+	DeleteContext(ctx context.Context) error
+}
+
+// Identifier is implemented by models that can report their own ID. Resource
+// controllers use it to build links, and otherwise look for an "id" field.
+type Identifier interface {
+	GetID() string
+}
+
+// Owned is implemented by nested models that store the id of the item that
+// owns them. Extended resource controllers compare it with the id of the
+// owner in the request path, so items of another owner are not found.
+// BelongsTo assigns an owner rather than checking it, so nested models should
+// implement Owned.
+type Owned interface {
+	OwnerID() string
+}
+
+// FieldSelector is implemented by models that restrict which fields clients
+// may request through sparse fieldsets. SelectableFields returns Go struct
+// field names, dot separated for nested fields.
+type FieldSelector interface {
+	SelectableFields() []string
+}
+
+// OwnerBatchFinder is implemented by models that can find the items of many
+// owners at once. FindAllByOwners returns the items of each owner in the order
+// the owners are given. Resource controllers use it to include owned items
+// without a query per owner.
+type OwnerBatchFinder interface {
+	FindAllByOwners(owners []Model) ([][]Model, error)
+}
+
+// BulkSaver is implemented by models that can save or delete many items at
+// once. Bulk resource controllers use it to write a whole request together, and
+// require it for all-or-nothing requests, which transactional stores should
+// apply completely or not at all.
+type BulkSaver interface {
+	SaveAll(items []Model) error
+	DeleteAll(items []Model) error
+}
+
+// SoftDeleter is implemented by models that move deleted items to a trash
+// instead of removing them. Resource controllers trash items when they are
+// deleted, hide trashed items from listings, and only call Delete when an item
+// is purged. BulkSaver models that are also SoftDeleters should trash items in
+// DeleteAll.
+type SoftDeleter interface {
+	Trash() error
+	Restore() error
+	Trashed() bool
+}
+
+// BeforeCreator is implemented by models with a hook that resource
+// controllers call with the request context before a new item is validated
+// and saved. A hook error aborts the action, as with every lifecycle hook.
+type BeforeCreator interface {
+	BeforeCreate(ctx context.Context) error
+}
+
+// AfterCreator is implemented by models with a hook called after a new item
+// is saved.
+type AfterCreator interface {
+	AfterCreate(ctx context.Context) error
+}
+
+// BeforeUpdater is implemented by models with a hook called before a changed
+// item is validated and saved.
+type BeforeUpdater interface {
+	BeforeUpdate(ctx context.Context) error
+}
+
+// AfterUpdater is implemented by models with a hook called after a changed
+// item is saved.
+type AfterUpdater interface {
+	AfterUpdate(ctx context.Context) error
+}
+
+// BeforeDeleter is implemented by models with a hook called before an item is
+// deleted or trashed. The item is loaded first, so the hook can inspect it.
+type BeforeDeleter interface {
+	BeforeDelete(ctx context.Context) error
+}
+
+// AfterDeleter is implemented by models with a hook called after an item is
+// deleted or trashed.
+type AfterDeleter interface {
+	AfterDelete(ctx context.Context) error
+}
+
+// AfterFinder is implemented by models with a hook called after an item is
+// found.
+type AfterFinder interface {
+	AfterFind(ctx context.Context) error
+}
@@ -0,0 +1,179 @@
+package resource
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/blueprint/blueprint"
+	http "github.com/blueprint/blueprint/transport"
+)
+
+// Route is a custom action of a resource controller. Mux.Resource routes it
+// under the collection path, such as GET /orders/export, or under the path of
+// single items for member actions, such as POST /orders/:order/cancel.
+type Route struct {
+	Method  string
+	Name    string
+	Member  bool
+	Handler http.HandlerFunc
+}
+
+// Actioner is implemented by resource controllers that choose the actions
+// Mux.Resource routes for them and add custom ones.
+type Actioner interface {
+	Routed(action Action) bool
+	Routes() []Route
+}
+
+// MemberFunc is a custom action on a single item, called with the item the
+// request identifies.
+type MemberFunc func(resp http.ResponseWriter, req *http.Request, item blueprint.Model)
+
+// Actions holds the actions a resource controller is routed with. Resource
+// controllers embed it, so read-only resources are routed with:
+//
+//	r.Only = []resource.Action{resource.IndexAction, resource.ShowAction}
+type Actions struct {
+	// Only routes just these actions, if any are set.
+	Only []Action
+
+	// Except routes every action but these.
+	Except []Action
+
+	routes []Route
+}
+
+// Routed satisfies the Actioner interface.
+func (a *Actions) Routed(action Action) bool {
+	if len(a.Only) > 0 && !contains(a.Only, action) {
+		return false
+	}
+	return !contains(a.Except, action)
+}
+
+// Routes satisfies the Actioner interface.
+func (a *Actions) Routes() []Route {
+	return a.routes
+}
+
+// Collection adds a custom action on the collection of items, routed at the
+// collection path followed by the name. Items are routed at the same path
+// followed by their id, so items may not be stored with the name of a
+// collection action as their id.
+func (a *Actions) Collection(method, name string, f http.HandlerFunc) {
+	a.add(method, name, false, f)
+}
+
+// reserved checks that an item is not stored with the name of a collection
+// action as its id, as the action would be run in place of its routes.
+func (a *Actions) reserved(item blueprint.Model) error {
+	var names []string
+	for _, route := range a.routes {
+		if !route.Member {
+			names = append(names, route.Name)
+		}
+	}
+	if len(names) == 0 {
+		return nil
+	}
+
+	_, id, err := identify(item)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		if id == name {
+			return fmt.Errorf("%w: %s is the name of a collection action", http.ErrInvalidID, id)
+		}
+	}
+	return nil
+}
+
+// add adds a custom action.
+func (a *Actions) add(method, name string, member bool, f http.HandlerFunc) {
+	switch method {
+	case "GET", "POST", "PUT", "PATCH", "DELETE":
+	default:
+		panic(fmt.Sprintf("resource: unsupported method %s for action %s", method, name))
+	}
+
+	name = strings.Trim(name, "/")
+	if len(name) == 0 {
+		panic("resource: custom actions require a name")
+	}
+	a.routes = append(a.routes, Route{Method: method, Name: name, Member: member, Handler: f})
+}
+
+// contains reports if a list of actions holds an action.
+func contains(actions []Action, action Action) bool {
+	for _, a := range actions {
+		if a == action {
+			return true
+		}
+	}
+	return false
+}
+
+// Member adds a custom action on single items, routed at the item path
+// followed by the name. The item is found as it is for Show, trashed items
+// are rejected, and policies are asked to allow the action named after the
+// custom action.
+func (r *Resource) Member(method, name string, f MemberFunc) {
+	r.add(method, name, true, func(resp http.ResponseWriter, req *http.Request) {
+		id, err := r.ID(req)
+		if err != nil {
+			resp.WriteErrs(req, err)
+			return
+		}
+
+		item, err := r.load(req, Action(name), id)
+		if err != nil {
+			resp.WriteErrs(req, err)
+			return
+		}
+
+		if err := untrashed(item); err != nil {
+			resp.WriteErrs(req, err)
+			return
+		}
+
+		f(resp, req, item)
+	})
+}
+
+// Member adds a custom action on single items, routed at the item path
+// followed by the name. The item is found as it is for Show, trashed items
+// are rejected, and policies are asked to allow the action named after the
+// custom action.
+func (e *ExtendedResource) Member(method, name string, f MemberFunc) {
+	e.add(method, name, true, func(resp http.ResponseWriter, req *http.Request) {
+		item, ok := e.owned(resp, req)
+		if !ok {
+			return
+		}
+
+		if err := authorize(req, e.policy(req), Action(name), item); err != nil {
+			resp.WriteErrs(req, err)
+			return
+		}
+
+		if err := untrashed(item); err != nil {
+			resp.WriteErrs(req, err)
+			return
+		}
+
+		f(resp, req, item)
+	})
+}
+
+// Member adds a custom action on single items, routed at the item path
+// followed by the name. The item is found as it is for Show, trashed items
+// are rejected, and policies are asked to allow the action named after the
+// custom action.
+func (t *Typed[T]) Member(method, name string, f func(resp http.ResponseWriter, req *http.Request, item T)) {
+	t.add(method, name, true, func(resp http.ResponseWriter, req *http.Request) {
+		if item, ok := t.find(resp, req, Action(name), false); ok {
+			f(resp, req, item)
+		}
+	})
+}
@@ -0,0 +1,374 @@
+package resource
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/blueprint/blueprint"
+	http "github.com/blueprint/blueprint/transport"
+)
+
+// DefaultBulkLimit is the most items a bulk request may hold, unless a Bulk
+// resource controller sets its own limit.
+var DefaultBulkLimit = 1000
+
+var (
+	// errBulkAborted is reported for valid items of an atomic bulk request
+	// that was not applied because other items failed.
+	errBulkAborted = errors.New("bulk request aborted")
+
+	// errBulkUnsupported is returned for atomic bulk requests on models that
+	// cannot save many items at once.
+	errBulkUnsupported = errors.New("resource: atomic bulk requests require a blueprint.BulkSaver model")
+)
+
+// BulkResourcer is implemented by resource controllers with bulk actions.
+// Mux.Resource routes BulkApply and BulkDestroy to the collection path, while
+// Store decides if a POST request holds one or many items.
+type BulkResourcer interface {
+	BulkStore(resp http.ResponseWriter, req *http.Request)
+	BulkApply(resp http.ResponseWriter, req *http.Request)
+	BulkDestroy(resp http.ResponseWriter, req *http.Request)
+}
+
+// Bulk describes a resource controller that also creates, updates and deletes
+// many items per request. Store takes a single item or a list of items,
+// BulkApply takes a list of partial items holding their ids, and BulkDestroy
+// takes a list of ids in the body or the ?ids= query parameter.
+//
+// Bulk requests are answered with 207 Multi-Status and a result for every item
+// in the order they were sent.
+type Bulk struct {
+	*Resource
+
+	// Atomic applies bulk requests completely or not at all. Items are only
+	// written if all of them are valid, through a single call to the model's
+	// blueprint.BulkSaver methods, which atomic bulk requests require.
+	Atomic bool
+
+	// Limit is the most items a bulk request may hold.
+	Limit int
+}
+
+// NewBulk takes a resource controller and returns it with bulk actions.
+func NewBulk(r *Resource) *Bulk {
+	return &Bulk{Resource: r, Limit: DefaultBulkLimit}
+}
+
+// bulkResult reports the outcome of a bulk request for a single item.
+type bulkResult struct {
+	Index  int         `json:"index" xml:"index" yaml:"index"`
+	ID     string      `json:"id,omitempty" xml:"id,omitempty" yaml:"id,omitempty"`
+	Status int         `json:"status" xml:"status" yaml:"status"`
+	Errs   []string    `json:"errors,omitempty" xml:"errors>error,omitempty" yaml:"errors,omitempty"`
+	Item   interface{} `json:"item,omitempty" xml:"item,omitempty" yaml:"item,omitempty"`
+}
+
+// bulkItem is an item of a bulk request along with its result. Items that
+// failed have no model.
+type bulkItem struct {
+	model  blueprint.Model
+	result bulkResult
+}
+
+// fail records the error an item failed with.
+func (i *bulkItem) fail(err error) {
+	code, ok := http.StatusCode(err)
+	if !ok {
+		code = http.StatusInternalServerError
+	}
+	i.model = nil
+	i.result.Status = code
+	i.result.Errs = []string{err.Error()}
+}
+
+// Store is a POST request for creating a new item, or many items if the body
+// holds a list.
+func (b *Bulk) Store(resp http.ResponseWriter, req *http.Request) {
+	body, err := req.Bytes()
+	if err != nil {
+		resp.WriteErrs(req, err)
+		return
+	}
+
+	if body = bytes.TrimSpace(body); len(body) > 0 && body[0] == '[' {
+		b.BulkStore(resp, req)
+		return
+	}
+	b.Resource.Store(resp, req)
+}
+
+// BulkStore is a POST request for creating many items.
+func (b *Bulk) BulkStore(resp http.ResponseWriter, req *http.Request) {
+	var raws []json.RawMessage
+	if err := b.unmarshal(req, &raws); err != nil {
+		resp.WriteErrs(req, err)
+		return
+	}
+
+	items := make([]*bulkItem, len(raws))
+	for i, raw := range raws {
+		items[i] = &bulkItem{result: bulkResult{Index: i}}
+
+		m := b.model.New()
+		if err := http.DefaultDecoder.Decode(raw, m); err != nil {
+			items[i].fail(err)
+			continue
+		}
+		if err := b.reserved(m); err != nil {
+			items[i].fail(err)
+			continue
+		}
+		if err := authorize(req, b.policy(req), StoreAction, m); err != nil {
+			items[i].fail(err)
+			continue
+		}
+		if err := trigger(req, beforeCreate, m); err != nil {
+			items[i].fail(err)
+			continue
+		}
+		if err := m.Validate(); err != nil {
+			items[i].fail(err)
+			continue
+		}
+		items[i].model = m
+	}
+
+	b.commit(resp, req, items, http.StatusCreated, afterCreate)
+}
+
+// BulkApply is a PATCH request for updating many items. Every item must hold
+// its id.
+func (b *Bulk) BulkApply(resp http.ResponseWriter, req *http.Request) {
+	var raws []json.RawMessage
+	if err := b.unmarshal(req, &raws); err != nil {
+		resp.WriteErrs(req, err)
+		return
+	}
+
+	items := make([]*bulkItem, len(raws))
+	for i, raw := range raws {
+		items[i] = &bulkItem{result: bulkResult{Index: i}}
+
+		id, err := rawID(raw)
+		if err != nil {
+			items[i].fail(err)
+			continue
+		}
+		items[i].result.ID = id
+
+		m := b.model.New()
+		if err := findByID(req.Context(), m, id); err != nil {
+			items[i].fail(err)
+			continue
+		}
+		if err := trigger(req, afterFind, m); err != nil {
+			items[i].fail(err)
+			continue
+		}
+
+		if err := authorize(req, b.policy(req), ApplyAction, m); err != nil {
+			items[i].fail(err)
+			continue
+		}
+
+		// set the values specified in the item
+		if err := http.DefaultDecoder.Decode(raw, m); err != nil {
+			items[i].fail(err)
+			continue
+		}
+		if err := trigger(req, beforeUpdate, m); err != nil {
+			items[i].fail(err)
+			continue
+		}
+		if err := m.Validate(); err != nil {
+			items[i].fail(err)
+			continue
+		}
+		items[i].model = m
+	}
+
+	b.commit(resp, req, items, http.StatusOK, afterUpdate)
+}
+
+// BulkDestroy is a DELETE request for deleting many items.
+func (b *Bulk) BulkDestroy(resp http.ResponseWriter, req *http.Request) {
+	var ids []string
+	if values, ok := req.Query("ids"); ok {
+		for _, value := range values {
+			for _, id := range strings.Split(value, ",") {
+				if id = strings.TrimSpace(id); len(id) > 0 {
+					ids = append(ids, id)
+				}
+			}
+		}
+	} else {
+		var raws []json.RawMessage
+		if err := b.unmarshal(req, &raws); err != nil {
+			resp.WriteErrs(req, err)
+			return
+		}
+		for _, raw := range raws {
+			id, _ := jsonID(raw)
+			ids = append(ids, id)
+		}
+	}
+
+	if len(ids) > b.Limit {
+		resp.WriteErrs(req, fmt.Errorf("%w: bulk requests are limited to %d items", http.ErrInvalidInput, b.Limit))
+		return
+	}
+
+	items := make([]*bulkItem, len(ids))
+	for i, id := range ids {
+		items[i] = &bulkItem{result: bulkResult{Index: i, ID: id}}
+		if len(id) == 0 {
+			items[i].fail(http.ErrInvalidID)
+			continue
+		}
+
+		// items are loaded, so trashing them saves what is stored
+		m, err := b.load(req, DestroyAction, id)
+		if err != nil {
+			items[i].fail(err)
+			continue
+		}
+		if err := trigger(req, beforeDelete, m); err != nil {
+			items[i].fail(err)
+			continue
+		}
+		items[i].model = m
+	}
+
+	b.commit(resp, req, items, http.StatusNoContent, afterDelete)
+}
+
+// unmarshal reads the list of a bulk request body.
+func (b *Bulk) unmarshal(req *http.Request, raws *[]json.RawMessage) error {
+	if err := req.Unmarshal(raws); err != nil {
+		return err
+	}
+	if len(*raws) > b.Limit {
+		return fmt.Errorf("%w: bulk requests are limited to %d items", http.ErrInvalidInput, b.Limit)
+	}
+	return nil
+}
+
+// commit saves or deletes the items of a bulk request that are still pending,
+// calls the hook for the after event on those written, and writes the result
+// of every item.
+func (b *Bulk) commit(resp http.ResponseWriter, req *http.Request, items []*bulkItem, status int, after event) {
+	deleting := after == afterDelete
+
+	saver, batch := b.model.(blueprint.BulkSaver)
+	if b.Atomic && !batch {
+		resp.WriteErrs(req, errBulkUnsupported)
+		return
+	}
+
+	var pending []*bulkItem
+	for _, item := range items {
+		if item.model != nil {
+			pending = append(pending, item)
+		}
+	}
+
+	switch {
+	case b.Atomic && len(pending) < len(items):
+		for _, item := range pending {
+			item.model = nil
+			item.result.Status = http.StatusFailedDependency
+			item.result.Errs = []string{errBulkAborted.Error()}
+		}
+
+	case batch && len(pending) > 0:
+		models := make([]blueprint.Model, len(pending))
+		for i, item := range pending {
+			models[i] = item.model
+		}
+
+		var err error
+		if deleting {
+			err = saver.DeleteAll(models)
+		} else {
+			err = saver.SaveAll(models)
+		}
+		if err != nil {
+			for _, item := range pending {
+				item.fail(err)
+			}
+		}
+
+	default:
+		for _, item := range pending {
+			var err error
+			if deleting {
+				err = remove(req.Context(), item.model)
+			} else {
+				err = persist(req.Context(), item.model)
+			}
+			if err != nil {
+				item.fail(err)
+			}
+		}
+	}
+
+	results := make([]bulkResult, len(items))
+	for i, item := range items {
+		// an after hook error fails an item that was written
+		if item.model != nil {
+			if err := trigger(req, after, item.model); err != nil {
+				item.fail(err)
+			}
+		}
+		if item.model != nil {
+			item.result.Status = status
+			if !deleting {
+				item.result.Item = item.model
+				if _, id, err := identify(item.model); err == nil && len(id) > 0 {
+					item.result.ID = id
+				}
+			}
+		}
+		results[i] = item.result
+	}
+
+	resp.Status(http.StatusMultiStatus)
+	resp.WriteFormat(req, struct {
+		Results []bulkResult `json:"results" xml:"result" yaml:"results"`
+	}{results})
+}
+
+// rawID finds the id of a JSON object, in the same properties resource
+// controllers look for when building links.
+func rawID(raw json.RawMessage) (string, error) {
+	var props map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &props); err != nil {
+		return "", fmt.Errorf("%w: %s", http.ErrInvalidJSON, err)
+	}
+
+	for _, key := range []string{"id", "_id", "ID"} {
+		if v, ok := props[key]; ok {
+			if id, err := jsonID(v); err == nil {
+				return id, nil
+			}
+		}
+	}
+	return "", http.ErrInvalidID
+}
+
+// jsonID decodes an id held by a JSON string or number.
+func jsonID(raw json.RawMessage) (string, error) {
+	var id string
+	if err := json.Unmarshal(raw, &id); err == nil && len(id) > 0 {
+		return id, nil
+	}
+	var n json.Number
+	if err := json.Unmarshal(raw, &n); err == nil && len(n) > 0 {
+		return n.String(), nil
+	}
+	return "", http.ErrInvalidID
+}
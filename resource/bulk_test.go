@@ -0,0 +1,241 @@
+package resource
+
+import (
+	"encoding/json"
+	stdhttp "net/http"
+	"reflect"
+	"testing"
+
+	"github.com/blueprint/blueprint"
+	"github.com/blueprint/blueprint/mock"
+	http "github.com/blueprint/blueprint/transport"
+)
+
+// ledger is a model that records what was saved and deleted. Items without an
+// id are invalid and the id "missing" is never found.
+type ledger struct {
+	*mock.Model `json:"-" xml:"-" yaml:"-"`
+	ID          string `json:"id" xml:"id" yaml:"id"`
+	Name        string `json:"name,omitempty" xml:"name,omitempty" yaml:"name,omitempty"`
+
+	log *[]string
+}
+
+func newLedger() *ledger {
+	return &ledger{Model: mock.NewModel(), log: &[]string{}}
+}
+
+func (l *ledger) New() blueprint.Model {
+	return &ledger{Model: l.Model, log: l.log}
+}
+
+func (l *ledger) SetID(id string) error {
+	l.ID = id
+	return nil
+}
+
+func (l *ledger) FindByID(id string) error {
+	if id == "missing" {
+		return http.ErrInvalidID
+	}
+	l.ID = id
+	return nil
+}
+
+func (l *ledger) Validate() error {
+	if len(l.ID) == 0 {
+		return http.ErrInvalidInput
+	}
+	return nil
+}
+
+func (l *ledger) Save() error {
+	*l.log = append(*l.log, "save "+l.ID)
+	return nil
+}
+
+func (l *ledger) Delete() error {
+	*l.log = append(*l.log, "delete "+l.ID)
+	return nil
+}
+
+// batchLedger saves and deletes many items in one call.
+type batchLedger struct {
+	*ledger
+}
+
+func (b batchLedger) SaveAll(items []blueprint.Model) error {
+	*b.log = append(*b.log, "save all "+ids(items))
+	return nil
+}
+
+func (b batchLedger) DeleteAll(items []blueprint.Model) error {
+	*b.log = append(*b.log, "delete all "+ids(items))
+	return nil
+}
+
+func ids(items []blueprint.Model) string {
+	var s string
+	for _, item := range items {
+		s += item.(*ledger).ID
+	}
+	return s
+}
+
+// bulkRequest executes a bulk action and returns the response status, the
+// status of each item, and the response body.
+func bulkRequest(t *testing.T, method, uri, body string, action http.HandlerFunc) (int, []int, string) {
+	rec := hypermediaRequest(method, uri, "", "", body, nil, action)
+
+	var doc struct {
+		Results []bulkResult `json:"results"`
+	}
+	if rec.Code == stdhttp.StatusMultiStatus {
+		if err := json.Unmarshal(rec.Body.Bytes(), &doc); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	statuses := make([]int, len(doc.Results))
+	for i, result := range doc.Results {
+		if result.Index != i {
+			t.Errorf("expected result %d to have index %d got %d", i, i, result.Index)
+		}
+		statuses[i] = result.Status
+	}
+	return rec.Code, statuses, rec.Body.String()
+}
+
+func TestBulkStore(t *testing.T) {
+	m := newLedger()
+	bulk := NewBulk(New(m))
+
+	code, statuses, body := bulkRequest(t, "POST", "/ledgers", `[{"id": "a"}, {"name": "no id"}, "b", {"id": "c"}]`, bulk.Store)
+	if code != stdhttp.StatusMultiStatus {
+		t.Fatalf("expected status %d got %d: %s", stdhttp.StatusMultiStatus, code, body)
+	}
+	if expected := []int{201, 400, 400, 201}; !reflect.DeepEqual(statuses, expected) {
+		t.Errorf("expected item statuses %v got %v", expected, statuses)
+	}
+	if expected := []string{"save a", "save c"}; !reflect.DeepEqual(*m.log, expected) {
+		t.Errorf("expected %v got %v", expected, *m.log)
+	}
+
+	// a single item is stored as usual
+	code, _, _ = bulkRequest(t, "POST", "/ledgers", `{"id": "d"}`, bulk.Store)
+	if code != stdhttp.StatusCreated {
+		t.Errorf("expected status %d got %d", stdhttp.StatusCreated, code)
+	}
+}
+
+func TestBulkApply(t *testing.T) {
+	m := newLedger()
+	bulk := NewBulk(New(m))
+
+	_, statuses, _ := bulkRequest(t, "PATCH", "/ledgers", `[{"id": "a", "name": "A"}, {"name": "B"}, {"id": "missing"}]`, bulk.BulkApply)
+	if expected := []int{200, 400, 400}; !reflect.DeepEqual(statuses, expected) {
+		t.Errorf("expected item statuses %v got %v", expected, statuses)
+	}
+	if expected := []string{"save a"}; !reflect.DeepEqual(*m.log, expected) {
+		t.Errorf("expected %v got %v", expected, *m.log)
+	}
+}
+
+func TestBulkDestroy(t *testing.T) {
+	m := newLedger()
+	bulk := NewBulk(New(m))
+
+	_, statuses, _ := bulkRequest(t, "DELETE", "/ledgers?ids=a,b", "", bulk.BulkDestroy)
+	if expected := []int{204, 204}; !reflect.DeepEqual(statuses, expected) {
+		t.Errorf("expected item statuses %v got %v", expected, statuses)
+	}
+
+	_, statuses, _ = bulkRequest(t, "DELETE", "/ledgers", `["c", 4, "e\u0301", "missing", null]`, bulk.BulkDestroy)
+	if expected := []int{204, 204, 204, 400, 400}; !reflect.DeepEqual(statuses, expected) {
+		t.Errorf("expected item statuses %v got %v", expected, statuses)
+	}
+	if expected := []string{"delete a", "delete b", "delete c", "delete 4", "delete e\u0301"}; !reflect.DeepEqual(*m.log, expected) {
+		t.Errorf("expected %v got %v", expected, *m.log)
+	}
+}
+
+func TestBulkDestroyTrash(t *testing.T) {
+	m := newBin()
+	bulk := NewBulk(New(m))
+
+	// items are loaded before they are trashed
+	_, statuses, _ := bulkRequest(t, "DELETE", "/bins?ids=a,x", "", bulk.BulkDestroy)
+	if expected := []int{204, 400}; !reflect.DeepEqual(statuses, expected) {
+		t.Errorf("expected item statuses %v got %v", expected, statuses)
+	}
+	if !m.store["a"] {
+		t.Error("expected item 'a' to be trashed")
+	}
+}
+
+func TestBulkSaver(t *testing.T) {
+	m := batchLedger{newLedger()}
+	bulk := NewBulk(New(m))
+
+	_, statuses, _ := bulkRequest(t, "POST", "/ledgers", `[{"id": "a"}, {}, {"id": "b"}]`, bulk.Store)
+	if expected := []int{201, 400, 201}; !reflect.DeepEqual(statuses, expected) {
+		t.Errorf("expected item statuses %v got %v", expected, statuses)
+	}
+
+	bulkRequest(t, "DELETE", "/ledgers?ids=c,d", "", bulk.BulkDestroy)
+	if expected := []string{"save all ab", "delete all cd"}; !reflect.DeepEqual(*m.log, expected) {
+		t.Errorf("expected %v got %v", expected, *m.log)
+	}
+}
+
+func TestBulkAtomic(t *testing.T) {
+	m := batchLedger{newLedger()}
+	bulk := NewBulk(New(m))
+	bulk.Atomic = true
+
+	_, statuses, _ := bulkRequest(t, "POST", "/ledgers", `[{"id": "a"}, {}]`, bulk.Store)
+	if expected := []int{424, 400}; !reflect.DeepEqual(statuses, expected) {
+		t.Errorf("expected item statuses %v got %v", expected, statuses)
+	}
+	if len(*m.log) > 0 {
+		t.Errorf("expected nothing to be saved got %v", *m.log)
+	}
+
+	_, statuses, _ = bulkRequest(t, "POST", "/ledgers", `[{"id": "a"}, {"id": "b"}]`, bulk.Store)
+	if expected := []int{201, 201}; !reflect.DeepEqual(statuses, expected) {
+		t.Errorf("expected item statuses %v got %v", expected, statuses)
+	}
+	if expected := []string{"save all ab"}; !reflect.DeepEqual(*m.log, expected) {
+		t.Errorf("expected %v got %v", expected, *m.log)
+	}
+
+	// atomic requests cannot be applied one item at a time
+	bulk = NewBulk(New(newLedger()))
+	bulk.Atomic = true
+	code, _, _ := bulkRequest(t, "POST", "/ledgers", `[{"id": "a"}]`, bulk.Store)
+	if code != stdhttp.StatusInternalServerError {
+		t.Errorf("expected status %d got %d", stdhttp.StatusInternalServerError, code)
+	}
+}
+
+func TestBulkLimit(t *testing.T) {
+	bulk := NewBulk(New(newLedger()))
+	bulk.Limit = 2
+
+	tests := []struct {
+		method string
+		uri    string
+		body   string
+		action http.HandlerFunc
+	}{{"POST", "/ledgers", `[{"id": "a"}, {"id": "b"}, {"id": "c"}]`, bulk.Store},
+		{"PATCH", "/ledgers", `[{"id": "a"}, {"id": "b"}, {"id": "c"}]`, bulk.BulkApply},
+		{"DELETE", "/ledgers?ids=a,b,c", "", bulk.BulkDestroy},
+		{"PATCH", "/ledgers", `{"id": "a"}`, bulk.BulkApply}}
+
+	for _, test := range tests {
+		code, _, _ := bulkRequest(t, test.method, test.uri, test.body, test.action)
+		if code != stdhttp.StatusBadRequest {
+			t.Errorf("%s %s: expected status %d got %d", test.method, test.uri, stdhttp.StatusBadRequest, code)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package resource
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/blueprint/blueprint"
+	"github.com/blueprint/blueprint/trace"
+)
+
+// The functions below call the context methods of a blueprint.ContextModel,
+// or the plain Model methods otherwise. Either way nothing is called once
+// the context is done, such as after a request timed out, and each call is
+// wrapped in a span, a child of the span of the request if it is traced.
+
+// findAll finds all the items of a model.
+func findAll(ctx context.Context, m blueprint.Model) (items []blueprint.Model, err error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	ctx, span := start(ctx, "FindAll", m)
+	defer func() { end(span, err) }()
+
+	if cm, ok := m.(blueprint.ContextModel); ok {
+		return cm.FindAllContext(ctx)
+	}
+	return m.FindAll()
+}
+
+// findByID loads an item by its id.
+func findByID(ctx context.Context, item blueprint.Model, id string) (err error) {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	ctx, span := start(ctx, "FindByID", item)
+	span.SetAttribute("model.id", id)
+	defer func() { end(span, err) }()
+
+	if cm, ok := item.(blueprint.ContextModel); ok {
+		return cm.FindByIDContext(ctx, id)
+	}
+	return item.FindByID(id)
+}
+
+// findAllByOwner finds the items of a model that belong to an owner.
+func findAllByOwner(ctx context.Context, m, owner blueprint.Model) (items []blueprint.Model, err error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	ctx, span := start(ctx, "FindAllByOwner", m)
+	span.SetAttribute("model.owner", fmt.Sprintf("%T", owner))
+	defer func() { end(span, err) }()
+
+	if cm, ok := m.(blueprint.ContextModel); ok {
+		return cm.FindAllByOwnerContext(ctx, owner)
+	}
+	return m.FindAllByOwner(owner)
+}
+
+// persist saves an item.
+func persist(ctx context.Context, item blueprint.Model) (err error) {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	ctx, span := start(ctx, "Save", item)
+	defer func() { end(span, err) }()
+
+	if cm, ok := item.(blueprint.ContextModel); ok {
+		return cm.SaveContext(ctx)
+	}
+	return item.Save()
+}
+
+// erase deletes an item for good.
+func erase(ctx context.Context, item blueprint.Model) (err error) {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	ctx, span := start(ctx, "Delete", item)
+	defer func() { end(span, err) }()
+
+	if cm, ok := item.(blueprint.ContextModel); ok {
+		return cm.DeleteContext(ctx)
+	}
+	return item.Delete()
+}
+
+// start starts the span of a Model call, named after the model type and the
+// method called, such as "*models.User.FindByID".
+func start(ctx context.Context, method string, m blueprint.Model) (context.Context, *trace.Span) {
+	model := fmt.Sprintf("%T", m)
+	ctx, span := trace.Start(ctx, model+"."+method)
+	span.SetAttribute("model.type", model)
+	span.SetAttribute("model.method", method)
+	return ctx, span
+}
+
+// end ends the span of a Model call with the error it returned.
+func end(span *trace.Span, err error) {
+	span.SetError(err)
+	span.End()
+}
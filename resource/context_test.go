@@ -0,0 +1,208 @@
+package resource
+
+import (
+	"context"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/blueprint/blueprint"
+	"github.com/blueprint/blueprint/mock"
+	"github.com/blueprint/blueprint/trace"
+	http "github.com/blueprint/blueprint/transport"
+	"github.com/julienschmidt/httprouter"
+)
+
+// contextual is a model whose queries take a context, and log whether the
+// context had a deadline.
+type contextual struct {
+	*mock.Model `json:"-" xml:"-" yaml:"-"`
+	ID          int `json:"id" xml:"id" yaml:"id"`
+
+	log *[]string
+}
+
+func (c *contextual) New() blueprint.Model {
+	return &contextual{Model: mock.NewModel(), log: c.log}
+}
+
+func (c *contextual) call(ctx context.Context, name string) {
+	if _, ok := ctx.Deadline(); ok {
+		name += " with deadline"
+	}
+	*c.log = append(*c.log, name)
+}
+
+func (c *contextual) FindAllContext(ctx context.Context) ([]blueprint.Model, error) {
+	c.call(ctx, "FindAllContext")
+	return []blueprint.Model{c.New()}, nil
+}
+
+func (c *contextual) FindByIDContext(ctx context.Context, id string) error {
+	c.call(ctx, "FindByIDContext")
+	return nil
+}
+
+func (c *contextual) FindAllByOwnerContext(ctx context.Context, owner blueprint.Model) ([]blueprint.Model, error) {
+	c.call(ctx, "FindAllByOwnerContext")
+	return nil, nil
+}
+
+func (c *contextual) SaveContext(ctx context.Context) error {
+	c.call(ctx, "SaveContext")
+	return nil
+}
+
+func (c *contextual) DeleteContext(ctx context.Context) error {
+	c.call(ctx, "DeleteContext")
+	return nil
+}
+
+func TestContextModel(t *testing.T) {
+	tests := []struct {
+		action string
+		method string
+		body   string
+		log    []string
+	}{{"Index", "GET", "", []string{"FindAllContext with deadline"}},
+		{"Apply", "PATCH", `{"id": 1}`, []string{"FindByIDContext with deadline", "SaveContext with deadline"}},
+		{"Destroy", "DELETE", "", []string{"FindByIDContext with deadline", "DeleteContext with deadline"}}}
+
+	for _, test := range tests {
+		m := &contextual{log: &[]string{}}
+		r := New(m)
+		r.Mount("/contextual", "contextual")
+
+		req := httptest.NewRequest(test.method, "/contextual/1", strings.NewReader(test.body))
+		ctx, cancel := context.WithTimeout(req.Context(), time.Minute)
+		rec := httptest.NewRecorder()
+		action := reflect.ValueOf(r).MethodByName(test.action).Interface().(func(http.ResponseWriter, *http.Request))
+		action(http.NewResponse(rec, "json"), http.NewRequest(req.WithContext(ctx), httprouter.Params{{Key: "contextual", Value: "1"}}))
+		cancel()
+
+		if rec.Code >= 400 {
+			t.Errorf("%s: unexpected status %d: %s", test.action, rec.Code, rec.Body.String())
+		}
+		if !reflect.DeepEqual(*m.log, test.log) {
+			t.Errorf("%s: expected %v got %v", test.action, test.log, *m.log)
+		}
+	}
+}
+
+func TestContextDone(t *testing.T) {
+	m := &contextual{log: &[]string{}}
+	r := New(m)
+	r.Mount("/contextual", "contextual")
+
+	req := httptest.NewRequest("GET", "/contextual", nil)
+	ctx, cancel := context.WithDeadline(req.Context(), time.Now().Add(-time.Second))
+	defer cancel()
+
+	rec := httptest.NewRecorder()
+	r.Index(http.NewResponse(rec, "json"), http.NewRequest(req.WithContext(ctx), nil))
+	if rec.Code != http.StatusGatewayTimeout {
+		t.Errorf("expected status %d got %d", http.StatusGatewayTimeout, rec.Code)
+	}
+	if len(*m.log) > 0 {
+		t.Errorf("expected no model calls after the deadline got %v", *m.log)
+	}
+}
+
+// spans keeps the spans it exports.
+type spans []*trace.Span
+
+func (s *spans) Export(span *trace.Span) error {
+	*s = append(*s, span)
+	return nil
+}
+
+func TestModelSpans(t *testing.T) {
+	exported := &spans{}
+	defer func(exporter trace.Exporter) { trace.DefaultTracer.Exporter = exporter }(trace.DefaultTracer.Exporter)
+	trace.DefaultTracer.Exporter = exported
+
+	m := &contextual{log: &[]string{}}
+	r := New(m)
+	r.Mount("/contextual", "contextual")
+
+	req := httptest.NewRequest("PATCH", "/contextual/1", strings.NewReader(`{"id": 1}`))
+	ctx, root := trace.Start(req.Context(), "PATCH /contextual/:contextual")
+	rec := httptest.NewRecorder()
+	r.Apply(http.NewResponse(rec, "json"), http.NewRequest(req.WithContext(ctx), httprouter.Params{{Key: "contextual", Value: "1"}}))
+	root.End()
+
+	var names []string
+	for _, span := range *exported {
+		names = append(names, span.Name)
+		if span != root && span.Parent != root.Context.SpanID {
+			t.Errorf("expected %s to nest in the request span", span.Name)
+		}
+	}
+	want := []string{"*resource.contextual.FindByID", "*resource.contextual.Save", "PATCH /contextual/:contextual"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("expected spans %v got %v", want, names)
+	}
+	if (*exported)[0].Attributes["model.id"] != "1" {
+		t.Errorf("expected the id attribute got %v", (*exported)[0].Attributes)
+	}
+}
+
+// canceling is a contextual model that cancels the context of the request it
+// is called for, as a client that disconnects does.
+type canceling struct {
+	*contextual
+	cancel *context.CancelFunc
+}
+
+func (c *canceling) New() blueprint.Model {
+	return &canceling{contextual: c.contextual.New().(*contextual), cancel: c.cancel}
+}
+
+func (c *canceling) FindByIDContext(ctx context.Context, id string) error {
+	(*c.cancel)()
+	return c.contextual.FindByIDContext(ctx, id)
+}
+
+func (c *canceling) Validate() error {
+	(*c.cancel)()
+	return nil
+}
+
+func TestContextCanceled(t *testing.T) {
+	var cancel context.CancelFunc
+	log := &[]string{}
+	owners := New(&canceling{contextual: &contextual{log: log}, cancel: &cancel})
+	owners.Mount("/owners", "owner")
+	items := owners.Extend(&canceling{contextual: &contextual{log: log}, cancel: &cancel})
+	items.Mount("/owners/:owner/items", "item")
+
+	tests := []struct {
+		name   string
+		method string
+		action http.HandlerFunc
+	}{{"Resource.Store", "POST", owners.Store},
+		{"ExtendedResource.Store", "POST", items.Store},
+		{"ExtendedResource.Index", "GET", items.Index}}
+
+	for _, test := range tests {
+		*log = nil
+		req := httptest.NewRequest(test.method, "/owners/1/items", strings.NewReader(`{"id": 2}`))
+		var ctx context.Context
+		ctx, cancel = context.WithCancel(req.Context())
+
+		rec := httptest.NewRecorder()
+		test.action(http.NewResponse(rec, "json"), http.NewRequest(req.WithContext(ctx), httprouter.Params{{Key: "owner", Value: "1"}}))
+		cancel()
+
+		if rec.Code < 400 {
+			t.Errorf("%s: expected an error status got %d: %s", test.name, rec.Code, rec.Body.String())
+		}
+		for _, call := range *log {
+			if call == "SaveContext" || call == "FindAllByOwnerContext" {
+				t.Errorf("%s: expected no %s after the request was canceled", test.name, call)
+			}
+		}
+	}
+}
@@ -1,18 +1,37 @@
 package resource
 
 import (
-	"errors"
-	"log"
 	"net/http"
 
 	"github.com/blueprint/blueprint"
 )
 
-// ExtendedResource represents a resource controller with CRUD operations.
+// ExtendedResource represents a resource controller with CRUD operations on
+// items owned by the items of another resource controller. Extended resources
+// can be extended again to nest items at any depth.
 type ExtendedResource struct {
-	model    blueprint.Model
-	resource *Resource
-	ID       func(req *http.Request) (string, error)
+	model blueprint.Model
+	owner owner
+	ID    func(req *http.Request) (string, error)
+
+	// Hypermedia enables JSON:API and HAL documents for requests that ask for
+	// them through the Accept header.
+	Hypermedia bool
+
+	// IncludeLimits bounds the owned items clients may include through
+	// ?include=, DefaultIncludeLimits are used if unset.
+	IncludeLimits IncludeLimits
+
+	// Policy authorizes every action, falling back to a policy registered for
+	// the route through router.Authorize. Without either, all actions are
+	// allowed.
+	Policy Policy
+
+	// Actions chooses the actions Mux.Resource routes and holds custom ones.
+	Actions
+
+	mount    mount
+	children []*ExtendedResource
 }
 
 // Extend extends a base resource.
@@ -20,69 +39,98 @@ type ExtendedResource struct {
 // Optional way to get an ID from request.
 // This is useful for '/user' routes based on session identification.
 func (r *Resource) Extend(m blueprint.Model, optID ...func(req *http.Request) (string, error)) *ExtendedResource {
-	e := &ExtendedResource{
-		model: m,
-		ID: func(req *http.Request) (string, error) {
-			return PathID(req, m.PathID())
-		},
-		resource: r,
+	e := &ExtendedResource{model: m, owner: r}
+	e.ID = func(req *http.Request) (string, error) {
+		return PathID(req, e.param())
 	}
 
 	if len(optID) > 0 {
 		e.ID = optID[0]
 	}
 
+	r.children = append(r.children, e)
 	return e
 }
 
+// Mount satisfies the Mounter interface. Mux.Resource calls Mount with the
+// path and id parameter the resource is routed under.
+func (e *ExtendedResource) Mount(path, id string) {
+	e.mount = newMount(path, id)
+}
+
+// param returns the URL path parameter holding an item id.
+func (e *ExtendedResource) param() string {
+	if len(e.mount.param) > 0 {
+		return e.mount.param
+	}
+	return e.model.PathID()
+}
+
 // Index is a GET request for returning a list of items.
 func (e *ExtendedResource) Index(resp http.ResponseWriter, req *http.Request) {
-	baseID, err := e.resource.ID(req)
+	base, err := e.base(req)
 	if err != nil {
-		resp.WriteErrs(req, err, errors.New("unable to identify resource id"))
+		resp.WriteErrs(req, err)
 		return
 	}
 
-	base := e.resource.model.New()
-	if err := base.FindByID(baseID); err != nil {
+	items, err := findAllByOwner(req.Context(), e.model, base)
+	if err != nil {
 		resp.WriteErrs(req, err)
 		return
 	}
 
-	items, err := e.model.FindAllByOwner(base)
-	if err != nil {
-		log.Fatal(err)
+	if err := triggerAll(req, afterFind, items); err != nil {
+		resp.WriteErrs(req, err)
+		return
 	}
 
-	resp.WriteFormatList(req, items)
+	writeList(resp, req, e, e.Hypermedia, allowed(req, e.policy(req), visible(req, items)))
 }
 
 // Show is a GET request for showing an item.
 func (e *ExtendedResource) Show(resp http.ResponseWriter, req *http.Request) {
-	baseID, err := e.resource.ID(req)
+	base, err := e.base(req)
 	if err != nil {
 		resp.WriteErrs(req, err)
 		return
 	}
 
-	base := e.resource.model.New()
-	if err := base.FindByID(baseID); err != nil {
+	id, err := e.ID(req)
+	if err != nil {
 		resp.WriteErrs(req, err)
 		return
 	}
 
-	id, err := e.ID(req)
-	if err != nil {
+	item := e.model.New()
+	if err := findByID(req.Context(), item, id); err != nil {
 		resp.WriteErrs(req, err)
 		return
 	}
 
-	item := e.model.New()
-	if err := item.FindByID(id); err != nil {
+	if err := trigger(req, afterFind, item); err != nil {
+		resp.WriteErrs(req, err)
+		return
+	}
+
+	if err := e.owns(req, item); err != nil {
 		resp.WriteErrs(req, err)
 		return
 	}
 
+	if err := authorize(req, e.policy(req), ShowAction, item); err != nil {
+		resp.WriteErrs(req, err)
+		return
+	}
+
+	// trashed items are only shown if asked for, as Index lists them
+	if !req.QueryBool("trashed") {
+		if err := untrashed(item); err != nil {
+			resp.WriteErrs(req, err)
+			return
+		}
+	}
+
 	// items might not store its relationships in a database, so BelongsTo() will
 	// ensure an item and its relationships are show in the reponse
 	if err := item.BelongsTo(base); err != nil {
@@ -90,25 +138,33 @@ func (e *ExtendedResource) Show(resp http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	resp.WriteFormat(req, item)
+	writeItem(resp, req, e, e.Hypermedia, item)
 }
 
 // Store is a POST request for creating a new item.
 func (e *ExtendedResource) Store(resp http.ResponseWriter, req *http.Request) {
-	baseID, err := e.resource.ID(req)
+	base, err := e.base(req)
 	if err != nil {
 		resp.WriteErrs(req, err)
 		return
 	}
 
-	base := e.resource.model.New()
-	if err := base.FindByID(baseID); err != nil {
+	item := e.model.New()
+	id, err := decode(req, e, e.Hypermedia, item)
+	if err != nil {
 		resp.WriteErrs(req, err)
 		return
 	}
 
-	item := e.model.New()
-	if err := req.Unmarshal(item); err != nil {
+	// client generated ids are allowed by JSON:API documents
+	if len(id) > 0 {
+		if err := item.SetID(id); err != nil {
+			resp.WriteErrs(req, err)
+			return
+		}
+	}
+
+	if err := e.reserved(item); err != nil {
 		resp.WriteErrs(req, err)
 		return
 	}
@@ -118,47 +174,77 @@ func (e *ExtendedResource) Store(resp http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	if err := authorize(req, e.policy(req), StoreAction, item); err != nil {
+		resp.WriteErrs(req, err)
+		return
+	}
+
+	if err := trigger(req, beforeCreate, item); err != nil {
+		resp.WriteErrs(req, err)
+		return
+	}
+
 	if err := item.Validate(); err != nil {
 		resp.WriteErrs(req, err)
 		return
 	}
 
-	if err := item.Save(); err != nil {
-		log.Fatal(err)
+	if err := persist(req.Context(), item); err != nil {
+		resp.WriteErrs(req, err)
+		return
+	}
+
+	if err := trigger(req, afterCreate, item); err != nil {
+		resp.WriteErrs(req, err)
+		return
 	}
 
 	resp.Status(http.StatusCreated)
-	resp.WriteFormat(req, item)
+	writeItem(resp, req, e, e.Hypermedia, item)
 }
 
 // Apply is a PATCH request for updating a single item.
 func (e *ExtendedResource) Apply(resp http.ResponseWriter, req *http.Request) {
-	baseID, err := e.resource.ID(req)
+	base, err := e.base(req)
 	if err != nil {
 		resp.WriteErrs(req, err)
 		return
 	}
 
-	base := e.resource.model.New()
-	if err := base.FindByID(baseID); err != nil {
+	id, err := e.ID(req)
+	if err != nil {
 		resp.WriteErrs(req, err)
 		return
 	}
 
-	id, err := e.ID(req)
-	if err != nil {
+	item := e.model.New()
+	if err := findByID(req.Context(), item, id); err != nil {
 		resp.WriteErrs(req, err)
 		return
 	}
 
-	item := e.model.New()
-	if err := item.FindByID(id); err != nil {
+	if err := trigger(req, afterFind, item); err != nil {
+		resp.WriteErrs(req, err)
+		return
+	}
+
+	if err := e.owns(req, item); err != nil {
+		resp.WriteErrs(req, err)
+		return
+	}
+
+	if err := authorize(req, e.policy(req), ApplyAction, item); err != nil {
+		resp.WriteErrs(req, err)
+		return
+	}
+
+	if err := untrashed(item); err != nil {
 		resp.WriteErrs(req, err)
 		return
 	}
 
 	// set the values specified in the PATCH body
-	if err := req.Unmarshal(item); err != nil {
+	if _, err := decode(req, e, e.Hypermedia, item); err != nil {
 		resp.WriteErrs(req, err)
 		return
 	}
@@ -169,33 +255,37 @@ func (e *ExtendedResource) Apply(resp http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	if err := trigger(req, beforeUpdate, item); err != nil {
+		resp.WriteErrs(req, err)
+		return
+	}
+
 	if err := item.Validate(); err != nil {
 		resp.WriteErrs(req, err)
 		return
 	}
 
-	if err := item.Save(); err != nil {
+	if err := persist(req.Context(), item); err != nil {
 		resp.WriteErrs(req, err)
 		return
 	}
 
-	resp.WriteFormat(req, item)
+	if err := trigger(req, afterUpdate, item); err != nil {
+		resp.WriteErrs(req, err)
+		return
+	}
+
+	writeItem(resp, req, e, e.Hypermedia, item)
 }
 
 // Update is a PUT request for replacing a single item.
 func (e *ExtendedResource) Update(resp http.ResponseWriter, req *http.Request) {
-	baseID, err := e.resource.ID(req)
+	base, err := e.base(req)
 	if err != nil {
 		resp.WriteErrs(req, err)
 		return
 	}
 
-	base := e.resource.model.New()
-	if err := base.FindByID(baseID); err != nil {
-		resp.WriteErrs(req, err)
-		return
-	}
-
 	// get ID in the URL path
 	id, err := e.ID(req)
 	if err != nil {
@@ -204,12 +294,32 @@ func (e *ExtendedResource) Update(resp http.ResponseWriter, req *http.Request) {
 	}
 
 	item := e.model.New()
-	if err := item.FindByID(id); err != nil {
+	if err := findByID(req.Context(), item, id); err != nil {
 		resp.WriteErrs(req, err)
 		return
 	}
 
-	if err := req.Unmarshal(item); err != nil {
+	if err := trigger(req, afterFind, item); err != nil {
+		resp.WriteErrs(req, err)
+		return
+	}
+
+	if err := e.owns(req, item); err != nil {
+		resp.WriteErrs(req, err)
+		return
+	}
+
+	if err := authorize(req, e.policy(req), UpdateAction, item); err != nil {
+		resp.WriteErrs(req, err)
+		return
+	}
+
+	if err := untrashed(item); err != nil {
+		resp.WriteErrs(req, err)
+		return
+	}
+
+	if _, err := decode(req, e, e.Hypermedia, item); err != nil {
 		resp.WriteErrs(req, err)
 		return
 	}
@@ -226,33 +336,37 @@ func (e *ExtendedResource) Update(resp http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	if err := trigger(req, beforeUpdate, item); err != nil {
+		resp.WriteErrs(req, err)
+		return
+	}
+
 	if err := item.Validate(); err != nil {
 		resp.WriteErrs(req, err)
 		return
 	}
 
-	if err := item.Save(); err != nil {
+	if err := persist(req.Context(), item); err != nil {
+		resp.WriteErrs(req, err)
+		return
+	}
+
+	if err := trigger(req, afterUpdate, item); err != nil {
 		resp.WriteErrs(req, err)
 		return
 	}
 
-	resp.WriteFormat(req, item)
+	writeItem(resp, req, e, e.Hypermedia, item)
 }
 
 // Destroy is a DELETE request for deleting a single item.
 func (e *ExtendedResource) Destroy(resp http.ResponseWriter, req *http.Request) {
-	baseID, err := e.resource.ID(req)
+	base, err := e.base(req)
 	if err != nil {
 		resp.WriteErrs(req, err)
 		return
 	}
 
-	base := e.resource.model.New()
-	if err := base.FindByID(baseID); err != nil {
-		resp.WriteErrs(req, err)
-		return
-	}
-
 	// get ID in URL path
 	id, err := e.ID(req)
 	if err != nil {
@@ -261,7 +375,27 @@ func (e *ExtendedResource) Destroy(resp http.ResponseWriter, req *http.Request)
 	}
 
 	item := e.model.New()
-	if err := item.FindByID(id); err != nil {
+	if err := findByID(req.Context(), item, id); err != nil {
+		resp.WriteErrs(req, err)
+		return
+	}
+
+	if err := trigger(req, afterFind, item); err != nil {
+		resp.WriteErrs(req, err)
+		return
+	}
+
+	if err := e.owns(req, item); err != nil {
+		resp.WriteErrs(req, err)
+		return
+	}
+
+	if err := authorize(req, e.policy(req), DestroyAction, item); err != nil {
+		resp.WriteErrs(req, err)
+		return
+	}
+
+	if err := untrashed(item); err != nil {
 		resp.WriteErrs(req, err)
 		return
 	}
@@ -272,7 +406,18 @@ func (e *ExtendedResource) Destroy(resp http.ResponseWriter, req *http.Request)
 		return
 	}
 
-	if err := item.Delete(); err != nil {
+	if err := trigger(req, beforeDelete, item); err != nil {
+		resp.WriteErrs(req, err)
+		return
+	}
+
+	// items are trashed if their model supports it
+	if err := remove(req.Context(), item); err != nil {
+		resp.WriteErrs(req, err)
+		return
+	}
+
+	if err := trigger(req, afterDelete, item); err != nil {
 		resp.WriteErrs(req, err)
 		return
 	}
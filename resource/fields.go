@@ -0,0 +1,370 @@
+package resource
+
+import (
+	"encoding/xml"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/blueprint/blueprint"
+	http "github.com/blueprint/blueprint/transport"
+)
+
+// fieldTree is a parsed sparse fieldset. A nil subtree selects a whole field.
+type fieldTree map[string]fieldTree
+
+// requestedFields parses the ?fields=a,b,c.d query parameter. A nil tree is
+// returned if no fields are requested.
+func requestedFields(req *http.Request) fieldTree {
+	var tree fieldTree
+	for _, path := range queryPaths(req, "fields") {
+		if tree == nil {
+			tree = fieldTree{}
+		}
+		tree.add(path)
+	}
+	return tree
+}
+
+// queryPaths parses a query parameter holding a comma separated list of dot
+// separated paths.
+func queryPaths(req *http.Request, key string) [][]string {
+	values, _ := req.Query(key)
+
+	var paths [][]string
+	for _, value := range values {
+		for _, path := range strings.Split(value, ",") {
+			path = strings.TrimSpace(path)
+			if len(path) > 0 {
+				paths = append(paths, strings.Split(path, "."))
+			}
+		}
+	}
+	return paths
+}
+
+// add inserts a field path into the tree. Selecting a whole field wins over
+// selecting some of its nested fields.
+func (t fieldTree) add(path []string) {
+	sub, exists := t[path[0]]
+	if len(path) == 1 {
+		t[path[0]] = nil
+		return
+	}
+	if exists && sub == nil {
+		return
+	}
+	if sub == nil {
+		sub = fieldTree{}
+		t[path[0]] = sub
+	}
+	sub.add(path[1:])
+}
+
+// sparse returns a copy of an item holding only the fields requested. The
+// copy keeps the json, xml, and yaml struct tags of the original fields, so it
+// serializes the same way in every format. Field names are matched against the
+// struct tags of the format being written.
+func sparse(item interface{}, fields fieldTree, format string) (interface{}, error) {
+	if fields == nil {
+		return item, nil
+	}
+
+	var allowed []string
+	if s, ok := item.(blueprint.FieldSelector); ok {
+		allowed = s.SelectableFields()
+		if allowed == nil {
+			allowed = []string{}
+		}
+	}
+
+	s := &selector{format: format, allowed: allowed}
+	v, err := s.prune(reflect.ValueOf(item), fields, "", "")
+	if err != nil {
+		return nil, err
+	}
+	return v.Interface(), nil
+}
+
+// sparseList applies sparse to every item in a list.
+func sparseList(items []blueprint.Model, fields fieldTree, format string) (interface{}, error) {
+	if fields == nil {
+		return items, nil
+	}
+
+	list := make([]interface{}, len(items))
+	for i, item := range items {
+		v, err := sparse(item, fields, format)
+		if err != nil {
+			return nil, err
+		}
+		list[i] = v
+	}
+	return list, nil
+}
+
+// selector prunes values for one output format.
+type selector struct {
+	format string
+
+	// allowed lists the Go field paths that may be requested, nil allows all
+	allowed []string
+}
+
+// xmlName is the type of XMLName struct fields.
+var xmlName = reflect.TypeOf(xml.Name{})
+
+// prune returns a value holding only the fields of tree. The path arguments
+// track the requested name and Go name of the value for error messages and
+// selectable field checks.
+func (s *selector) prune(v reflect.Value, tree fieldTree, path, goPath string) (reflect.Value, error) {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return reflect.Zero(reflect.TypeOf((*interface{})(nil)).Elem()), nil
+		}
+		return s.prune(v.Elem(), tree, path, goPath)
+
+	case reflect.Slice, reflect.Array:
+		list := make([]interface{}, v.Len())
+		for i := range list {
+			elem, err := s.prune(v.Index(i), tree, path, goPath)
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			list[i] = elem.Interface()
+		}
+		return reflect.ValueOf(list), nil
+
+	case reflect.Struct:
+		return s.pruneStruct(v, tree, path, goPath)
+	}
+	return reflect.Value{}, fmt.Errorf("%w: field %s has no fields", http.ErrInvalidParameter, path)
+}
+
+// pruneStruct builds a struct holding only the fields of tree.
+func (s *selector) pruneStruct(v reflect.Value, tree fieldTree, path, goPath string) (reflect.Value, error) {
+	t := v.Type()
+
+	// the output keeps the XML name of the original type, nested structs are
+	// otherwise named by their field
+	var fields []reflect.StructField
+	var values []reflect.Value
+	if f, ok := t.FieldByName("XMLName"); ok && f.Type == xmlName {
+		fields = append(fields, reflect.StructField{Name: f.Name, Type: f.Type, Tag: f.Tag})
+		values = append(values, v.FieldByIndex(f.Index))
+	} else if s.format == "xml" && len(path) == 0 {
+		name := t.Name()
+		if len(name) == 0 {
+			name = "item"
+		}
+		fields = append(fields, reflect.StructField{
+			Name: "XMLName",
+			Type: xmlName,
+			Tag:  reflect.StructTag(`xml:"` + name + `" json:"-" yaml:"-"`),
+		})
+		values = append(values, reflect.ValueOf(xml.Name{}))
+	}
+
+	// resolve the requested fields, then keep them in declaration order
+	type selected struct {
+		name string
+		reflect.StructField
+	}
+	var selection []selected
+	for name := range tree {
+		f, ok := s.field(t, name)
+		if !ok || !s.selectable(join(goPath, f.Name), tree[name] == nil) {
+			return reflect.Value{}, fmt.Errorf("%w: unknown field %s", http.ErrInvalidParameter, join(path, name))
+		}
+		selection = append(selection, selected{name, f})
+	}
+	sort.Slice(selection, func(i, j int) bool {
+		a, b := selection[i].Index, selection[j].Index
+		for k := 0; k < len(a) && k < len(b); k++ {
+			if a[k] != b[k] {
+				return a[k] < b[k]
+			}
+		}
+		return len(a) < len(b)
+	})
+
+	used := map[string]bool{}
+	for _, f := range fields {
+		used[f.Name] = true
+	}
+	for _, f := range selection {
+		value, err := v.FieldByIndexErr(f.Index)
+		if err != nil {
+			// a nil embedded pointer leaves the field empty
+			value = reflect.Zero(f.Type)
+		}
+
+		// embedded structs may promote fields of the same Go name under
+		// different tags, which StructOf rejects, so later ones are renamed
+		// and tagged with the name they are serialized under
+		field := reflect.StructField{Name: f.Name, Type: f.Type, Tag: f.Tag}
+		for i := len(fields); used[field.Name]; i++ {
+			field.Name = fmt.Sprintf("Field%d", i)
+			field.Tag = s.named(f.StructField, f.name)
+		}
+		used[field.Name] = true
+		if tree[f.name] != nil {
+			value, err = s.prune(value, tree[f.name], join(path, f.name), join(goPath, f.Name))
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			field.Type = value.Type()
+		}
+		fields = append(fields, field)
+		values = append(values, value)
+	}
+
+	// StructOf returns the same type for the same fields, so equal selections
+	// share a type
+	typ := reflect.StructOf(fields)
+	out := reflect.New(typ).Elem()
+	for i, value := range values {
+		if value.IsValid() {
+			out.Field(i).Set(value)
+		}
+	}
+	return out, nil
+}
+
+// field finds the exported struct field serialized under name, including
+// fields promoted from embedded structs.
+func (s *selector) field(t reflect.Type, name string) (reflect.StructField, bool) {
+	var found reflect.StructField
+	ok := !s.each(t, func(tag string, f reflect.StructField) bool {
+		if tag == name {
+			found = f
+			return false
+		}
+		return true
+	})
+	return found, ok
+}
+
+// names returns the names of every exported struct field in the order they
+// are declared, including fields promoted from embedded structs.
+func (s *selector) names(t reflect.Type) []string {
+	var names []string
+	seen := map[string]bool{}
+	s.each(t, func(tag string, f reflect.StructField) bool {
+		if !seen[tag] {
+			seen[tag] = true
+			names = append(names, tag)
+		}
+		return true
+	})
+	return names
+}
+
+// each calls fn with the exported struct fields of a type and the names they
+// are serialized under, until fn returns false. Each returns false if it was
+// stopped.
+func (s *selector) each(t reflect.Type, fn func(name string, f reflect.StructField) bool) bool {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag, inline := s.tag(f)
+		if tag == "-" {
+			continue
+		}
+
+		// fields promoted through unexported embedded structs are read only,
+		// so only exported embedded structs are searched
+		if f.Anonymous && f.PkgPath == "" && (tag == "" || inline) {
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct && (s.format != "yml" || inline) {
+				index := i
+				more := s.each(ft, func(name string, inner reflect.StructField) bool {
+					inner.Index = append([]int{index}, inner.Index...)
+					return fn(name, inner)
+				})
+				if !more {
+					return false
+				}
+				continue
+			}
+		}
+
+		if f.PkgPath != "" || f.Name == "XMLName" {
+			continue
+		}
+		if tag == "" {
+			tag = f.Name
+			if s.format == "yml" {
+				tag = strings.ToLower(f.Name)
+			}
+		}
+		if !fn(tag, f) {
+			return false
+		}
+	}
+	return true
+}
+
+// key returns the struct tag key of the selector's format.
+func (s *selector) key() string {
+	switch s.format {
+	case "xml":
+		return "xml"
+	case "yml":
+		return "yaml"
+	}
+	return "json"
+}
+
+// tag returns the name a field is serialized under in the selector's format,
+// and whether a yaml field is inlined.
+func (s *selector) tag(f reflect.StructField) (string, bool) {
+	tag := f.Tag.Get(s.key())
+	name := tag
+	if i := strings.Index(tag, ","); i > -1 {
+		name = tag[:i]
+	}
+	return name, strings.Contains(tag, ",inline")
+}
+
+// named returns a tag serializing a field under name in the selector's
+// format, keeping the options of its tag.
+func (s *selector) named(f reflect.StructField, name string) reflect.StructTag {
+	var options string
+	if tag := f.Tag.Get(s.key()); strings.Contains(tag, ",") {
+		options = tag[strings.Index(tag, ","):]
+	}
+	return reflect.StructTag(fmt.Sprintf(`%s:"%s%s"`, s.key(), name, options))
+}
+
+// selectable checks a Go field path against the model's selectable fields. A
+// field is selectable if it or one of its parents is listed. A field that is
+// not requested whole, but only for some of its nested fields, is also
+// selectable if any of its nested fields are listed, so the selection can
+// descend to them. Requesting it whole takes listing it or a parent.
+func (s *selector) selectable(goPath string, whole bool) bool {
+	if s.allowed == nil {
+		return true
+	}
+	for _, allowed := range s.allowed {
+		if allowed == goPath || strings.HasPrefix(goPath, allowed+".") {
+			return true
+		}
+		if !whole && strings.HasPrefix(allowed, goPath+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// join joins two field path segments with a dot.
+func join(path, name string) string {
+	if len(path) == 0 {
+		return name
+	}
+	return path + "." + name
+}
@@ -0,0 +1,203 @@
+package resource
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	stdhttp "net/http"
+	"strings"
+	"testing"
+
+	"github.com/blueprint/blueprint"
+	"github.com/blueprint/blueprint/mock"
+	http "github.com/blueprint/blueprint/transport"
+	"github.com/julienschmidt/httprouter"
+	"gopkg.in/yaml.v2"
+)
+
+type author struct {
+	Name  string `json:"name" xml:"name" yaml:"name"`
+	Email string `json:"email" xml:"email" yaml:"email"`
+}
+
+type article struct {
+	*mock.Model `json:"-" xml:"-" yaml:"-"`
+	Title       string   `json:"title" xml:"title" yaml:"title"`
+	Body        string   `json:"body" xml:"content" yaml:"text"`
+	Author      *author  `json:"author" xml:"author" yaml:"author"`
+	Editors     []author `json:"editors" xml:"editor" yaml:"editors"`
+}
+
+type restrictedArticle article
+
+func (a *restrictedArticle) SelectableFields() []string {
+	return []string{"Title", "Author.Name"}
+}
+
+func newArticle() *article {
+	return &article{
+		Model:   mock.NewModel(),
+		Title:   "hello",
+		Body:    "world",
+		Author:  &author{Name: "gopher", Email: "gopher@golang.org"},
+		Editors: []author{{Name: "ed", Email: "ed@golang.org"}},
+	}
+}
+
+func fieldsOf(query string) fieldTree {
+	tree := fieldTree{}
+	for _, field := range strings.Split(query, ",") {
+		tree.add(strings.Split(field, "."))
+	}
+	return tree
+}
+
+func TestSparseJSON(t *testing.T) {
+	tests := []struct {
+		fields string
+		result string
+	}{{"title", `{"title":"hello"}`},
+		{"body,title", `{"title":"hello","body":"world"}`},
+		{"author.name", `{"author":{"name":"gopher"}}`},
+		{"author.name,author", `{"author":{"name":"gopher","email":"gopher@golang.org"}}`},
+		{"editors.email", `{"editors":[{"email":"ed@golang.org"}]}`}}
+
+	for _, test := range tests {
+		v, err := sparse(newArticle(), fieldsOf(test.fields), "json")
+		if err != nil {
+			t.Errorf("%s: %s", test.fields, err)
+			continue
+		}
+		b, err := json.Marshal(v)
+		if err != nil {
+			t.Error(err)
+		}
+		if string(b) != test.result {
+			t.Errorf("%s: expected %s got %s", test.fields, test.result, string(b))
+		}
+	}
+}
+
+func TestSparseXML(t *testing.T) {
+	v, err := sparse(newArticle(), fieldsOf("content,author.name"), "xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := xml.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "<article><content>world</content><author><name>gopher</name></author></article>"
+	if string(b) != expected {
+		t.Errorf("expected %s got %s", expected, string(b))
+	}
+
+	// names follow the struct tags of the format being written
+	if _, err := sparse(newArticle(), fieldsOf("body"), "xml"); !errors.Is(err, http.ErrInvalidParameter) {
+		t.Errorf("expected %s got %v", http.ErrInvalidParameter, err)
+	}
+}
+
+func TestSparseYAML(t *testing.T) {
+	v, err := sparse(newArticle(), fieldsOf("text"), "yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := yaml.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "text: world\n" {
+		t.Errorf("expected 'text: world' got '%s'", string(b))
+	}
+}
+
+// Byline and Revision are exported, as fields are only promoted from exported
+// embedded structs.
+type Byline struct {
+	ID string `json:"owner_id" xml:"owner" yaml:"owner_id"`
+}
+
+type Revision struct {
+	ID string `json:"revision_id,omitempty" xml:"revision,attr" yaml:"revision_id"`
+}
+
+type revisedArticle struct {
+	Byline   `yaml:",inline"`
+	Revision `yaml:",inline"`
+	ID       string
+}
+
+func TestSparseSameNames(t *testing.T) {
+	item := &revisedArticle{Byline{"1"}, Revision{"2"}, "3"}
+	tests := []struct {
+		format, fields, result string
+		marshal                func(interface{}) ([]byte, error)
+	}{{"json", "ID,owner_id,revision_id", `{"owner_id":"1","revision_id":"2","ID":"3"}`, json.Marshal},
+		{"xml", "owner,revision", `<revisedArticle revision="2"><owner>1</owner></revisedArticle>`, xml.Marshal},
+		{"yml", "owner_id,revision_id", "owner_id: \"1\"\nrevision_id: \"2\"\n", yaml.Marshal}}
+
+	for _, test := range tests {
+		v, err := sparse(item, fieldsOf(test.fields), test.format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := test.marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != test.result {
+			t.Errorf("%s %s: expected %s got %s", test.format, test.fields, test.result, string(b))
+		}
+	}
+}
+
+func TestSparseInvalid(t *testing.T) {
+	for _, fields := range []string{"nope", "title.length", "author.nope", "Model"} {
+		if _, err := sparse(newArticle(), fieldsOf(fields), "json"); !errors.Is(err, http.ErrInvalidParameter) {
+			t.Errorf("%s: expected %s got %v", fields, http.ErrInvalidParameter, err)
+		}
+	}
+}
+
+func TestSparseSelectableFields(t *testing.T) {
+	var _ blueprint.FieldSelector = &restrictedArticle{}
+
+	tests := []struct {
+		fields string
+		ok     bool
+	}{{"title", true},
+		{"author.name", true},
+		{"body", false},
+		{"author.email", false},
+		{"author", false}}
+
+	for _, test := range tests {
+		_, err := sparse((*restrictedArticle)(newArticle()), fieldsOf(test.fields), "json")
+		if test.ok && err != nil {
+			t.Errorf("%s: expected no error got %s", test.fields, err)
+		}
+		if !test.ok && !errors.Is(err, http.ErrInvalidParameter) {
+			t.Errorf("%s: expected %s got %v", test.fields, http.ErrInvalidParameter, err)
+		}
+	}
+}
+
+func TestResourceFields(t *testing.T) {
+	resource := New(mock.NewModel())
+	ps := httprouter.Params{{Key: "model_id", Value: "42"}}
+
+	rec := hypermediaRequest("GET", "/model/42?fields=ID", "", "", "", ps, resource.Show)
+	if rec.Code != stdhttp.StatusOK {
+		t.Errorf("expected status %d got %d", stdhttp.StatusOK, rec.Code)
+	}
+	if body := strings.TrimSpace(rec.Body.String()); body != `{"ID":4037200794235010051}` {
+		t.Errorf(`expected {"ID":4037200794235010051} got %s`, body)
+	}
+
+	rec = hypermediaRequest("GET", "/model?fields=nope", "", "", "", nil, resource.Index)
+	if rec.Code != stdhttp.StatusBadRequest {
+		t.Errorf("expected status %d got %d", stdhttp.StatusBadRequest, rec.Code)
+	}
+}
@@ -0,0 +1,67 @@
+package resource
+
+import (
+	"github.com/blueprint/blueprint"
+	http "github.com/blueprint/blueprint/transport"
+)
+
+// event is a point in the lifecycle of an item where models may hook in.
+type event int
+
+const (
+	beforeCreate event = iota
+	afterCreate
+	beforeUpdate
+	afterUpdate
+	beforeDelete
+	afterDelete
+	afterFind
+)
+
+// trigger calls the hook a model implements for an event, if any, with the
+// request context.
+func trigger(req *http.Request, e event, item interface{}) error {
+	ctx := req.Context()
+
+	switch e {
+	case beforeCreate:
+		if h, ok := item.(blueprint.BeforeCreator); ok {
+			return h.BeforeCreate(ctx)
+		}
+	case afterCreate:
+		if h, ok := item.(blueprint.AfterCreator); ok {
+			return h.AfterCreate(ctx)
+		}
+	case beforeUpdate:
+		if h, ok := item.(blueprint.BeforeUpdater); ok {
+			return h.BeforeUpdate(ctx)
+		}
+	case afterUpdate:
+		if h, ok := item.(blueprint.AfterUpdater); ok {
+			return h.AfterUpdate(ctx)
+		}
+	case beforeDelete:
+		if h, ok := item.(blueprint.BeforeDeleter); ok {
+			return h.BeforeDelete(ctx)
+		}
+	case afterDelete:
+		if h, ok := item.(blueprint.AfterDeleter); ok {
+			return h.AfterDelete(ctx)
+		}
+	case afterFind:
+		if h, ok := item.(blueprint.AfterFinder); ok {
+			return h.AfterFind(ctx)
+		}
+	}
+	return nil
+}
+
+// triggerAll calls the hook for an event on every item in a list.
+func triggerAll(req *http.Request, e event, items []blueprint.Model) error {
+	for _, item := range items {
+		if err := trigger(req, e, item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
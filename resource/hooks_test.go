@@ -0,0 +1,194 @@
+package resource
+
+import (
+	"context"
+	"errors"
+	stdhttp "net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/blueprint/blueprint"
+	"github.com/blueprint/blueprint/mock"
+	http "github.com/blueprint/blueprint/transport"
+	"github.com/julienschmidt/httprouter"
+)
+
+type hookContextKey struct{}
+
+// hooked is a model that logs every lifecycle hook and model call, and fails
+// the hook named by fail.
+type hooked struct {
+	*mock.Model `json:"-" xml:"-" yaml:"-"`
+	ID          int `json:"id" xml:"id" yaml:"id"`
+
+	log  *[]string
+	fail string
+}
+
+func (h *hooked) New() blueprint.Model {
+	return &hooked{Model: mock.NewModel(), log: h.log, fail: h.fail}
+}
+
+func (h *hooked) FindAll() ([]blueprint.Model, error) {
+	*h.log = append(*h.log, "FindAll")
+	return []blueprint.Model{h.New(), h.New()}, nil
+}
+
+func (h *hooked) FindByID(id string) error {
+	*h.log = append(*h.log, "FindByID")
+	return nil
+}
+
+func (h *hooked) Validate() error {
+	*h.log = append(*h.log, "Validate")
+	return nil
+}
+
+func (h *hooked) Save() error {
+	*h.log = append(*h.log, "Save")
+	return nil
+}
+
+func (h *hooked) Delete() error {
+	*h.log = append(*h.log, "Delete")
+	return nil
+}
+
+func (h *hooked) hook(ctx context.Context, name string) error {
+	if ctx.Value(hookContextKey{}) != "request" {
+		return errors.New("missing request context")
+	}
+	*h.log = append(*h.log, name)
+	if name == h.fail {
+		return http.ErrInvalidInput
+	}
+	return nil
+}
+
+func (h *hooked) BeforeCreate(ctx context.Context) error { return h.hook(ctx, "BeforeCreate") }
+func (h *hooked) AfterCreate(ctx context.Context) error  { return h.hook(ctx, "AfterCreate") }
+func (h *hooked) BeforeUpdate(ctx context.Context) error { return h.hook(ctx, "BeforeUpdate") }
+func (h *hooked) AfterUpdate(ctx context.Context) error  { return h.hook(ctx, "AfterUpdate") }
+func (h *hooked) BeforeDelete(ctx context.Context) error { return h.hook(ctx, "BeforeDelete") }
+func (h *hooked) AfterDelete(ctx context.Context) error  { return h.hook(ctx, "AfterDelete") }
+func (h *hooked) AfterFind(ctx context.Context) error    { return h.hook(ctx, "AfterFind") }
+
+// hookRequest executes an action with a value in the request context.
+func hookRequest(method, body string, action http.HandlerFunc) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, "/hooked", strings.NewReader(body))
+	r = r.WithContext(context.WithValue(r.Context(), hookContextKey{}, "request"))
+	rec := httptest.NewRecorder()
+	ps := httprouter.Params{{Key: "model_id", Value: "1"}, {Key: "hooked", Value: "1"}}
+	action.ServeHTTP(http.NewResponse(rec, "json"), http.NewRequest(r, ps))
+	return rec
+}
+
+func TestHooks(t *testing.T) {
+	tests := []struct {
+		action string
+		method string
+		body   string
+		log    []string
+	}{{"Index", "GET", "", []string{"FindAll", "AfterFind", "AfterFind"}},
+		{"Show", "GET", "", []string{"FindByID", "AfterFind"}},
+		{"Store", "POST", `{"id": 1}`, []string{"BeforeCreate", "Validate", "Save", "AfterCreate"}},
+		{"Update", "PUT", `{"id": 1}`, []string{"FindByID", "AfterFind", "BeforeUpdate", "Validate", "Save", "AfterUpdate"}},
+		{"Apply", "PATCH", `{"id": 1}`, []string{"FindByID", "AfterFind", "BeforeUpdate", "Validate", "Save", "AfterUpdate"}},
+		{"Destroy", "DELETE", "", []string{"FindByID", "AfterFind", "BeforeDelete", "Delete", "AfterDelete"}}}
+
+	for _, test := range tests {
+		m := &hooked{log: &[]string{}}
+		r := New(m)
+		r.Mount("/hooked", "hooked")
+
+		action := reflect.ValueOf(r).MethodByName(test.action).Interface().(func(http.ResponseWriter, *http.Request))
+		rec := hookRequest(test.method, test.body, action)
+		if rec.Code >= 400 {
+			t.Errorf("%s: unexpected status %d: %s", test.action, rec.Code, rec.Body.String())
+		}
+		if !reflect.DeepEqual(*m.log, test.log) {
+			t.Errorf("%s: expected %v got %v", test.action, test.log, *m.log)
+		}
+	}
+}
+
+func TestHookErrors(t *testing.T) {
+	tests := []struct {
+		fail   string
+		action string
+		method string
+		log    []string
+	}{{"BeforeCreate", "Store", "POST", []string{"BeforeCreate"}},
+		{"BeforeUpdate", "Apply", "PATCH", []string{"FindByID", "AfterFind", "BeforeUpdate"}},
+		{"BeforeDelete", "Destroy", "DELETE", []string{"FindByID", "AfterFind", "BeforeDelete"}},
+		{"AfterFind", "Show", "GET", []string{"FindByID", "AfterFind"}}}
+
+	for _, test := range tests {
+		m := &hooked{log: &[]string{}, fail: test.fail}
+		r := New(m)
+		r.Mount("/hooked", "hooked")
+
+		action := reflect.ValueOf(r).MethodByName(test.action).Interface().(func(http.ResponseWriter, *http.Request))
+		rec := hookRequest(test.method, `{"id": 1}`, action)
+		if rec.Code != stdhttp.StatusBadRequest {
+			t.Errorf("%s: expected status %d got %d", test.fail, stdhttp.StatusBadRequest, rec.Code)
+		}
+		if !reflect.DeepEqual(*m.log, test.log) {
+			t.Errorf("%s: expected %v got %v", test.fail, test.log, *m.log)
+		}
+	}
+}
+
+func TestHooksExtended(t *testing.T) {
+	m := &hooked{log: &[]string{}}
+	e := New(mock.NewModel()).Extend(m)
+	e.Mount("/owners/:model_id/hooked", "hooked")
+
+	hookRequest("DELETE", "", e.Destroy)
+	expected := []string{"FindByID", "AfterFind", "BeforeDelete", "Delete", "AfterDelete"}
+	if !reflect.DeepEqual(*m.log, expected) {
+		t.Errorf("expected %v got %v", expected, *m.log)
+	}
+}
+
+// stored is a ledger whose items hold a name once found, and whose delete
+// hooks log the name of the item they guard.
+type stored struct {
+	*ledger
+}
+
+func (s stored) New() blueprint.Model {
+	return stored{&ledger{Model: s.Model, log: s.log}}
+}
+
+func (s stored) FindByID(id string) error {
+	s.ID, s.Name = id, "stored "+id
+	return nil
+}
+
+func (s stored) BeforeDelete(ctx context.Context) error {
+	*s.log = append(*s.log, "BeforeDelete "+s.Name)
+	return nil
+}
+
+func (s stored) AfterDelete(ctx context.Context) error {
+	*s.log = append(*s.log, "AfterDelete "+s.Name)
+	return nil
+}
+
+func TestDeleteHooksLoaded(t *testing.T) {
+	m := stored{newLedger()}
+	r := New(m)
+	r.Mount("/stored", "stored")
+
+	hypermediaRequest("DELETE", "/stored/a", "", "", "", httprouter.Params{{Key: "stored", Value: "a"}}, r.Destroy)
+	hypermediaRequest("DELETE", "/stored?ids=b", "", "", "", nil, NewBulk(r).BulkDestroy)
+
+	expected := []string{"BeforeDelete stored a", "delete a", "AfterDelete stored a",
+		"BeforeDelete stored b", "delete b", "AfterDelete stored b"}
+	if !reflect.DeepEqual(*m.log, expected) {
+		t.Errorf("expected %v got %v", expected, *m.log)
+	}
+}
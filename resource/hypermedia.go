@@ -0,0 +1,572 @@
+package resource
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/blueprint/blueprint"
+	http "github.com/blueprint/blueprint/transport"
+)
+
+// Mounter is implemented by resource controllers that need to know the path
+// and id parameter they are routed under, such as for building links.
+type Mounter interface {
+	Mount(path, id string)
+}
+
+// mount describes where a resource controller is routed.
+type mount struct {
+	path  string // "/users/:user/posts"
+	param string // "post"
+	name  string // "posts"
+}
+
+// newMount takes a route path and id parameter and returns a mount named by
+// the last static path segment.
+func newMount(path, param string) mount {
+	m := mount{path: strings.TrimRight(path, "/"), param: param}
+	segments := strings.Split(m.path, "/")
+	for i := len(segments) - 1; i >= 0; i-- {
+		if len(segments[i]) > 0 && segments[i][0] != ':' {
+			m.name = segments[i]
+			break
+		}
+	}
+	return m
+}
+
+// href fills the path parameters of the mount path using the values provided,
+// falling back to the values in the request URL.
+func (m mount) href(req *http.Request, values map[string]string) string {
+	segments := strings.Split(m.path, "/")
+	for i, segment := range segments {
+		if len(segment) > 1 && segment[0] == ':' {
+			name := segment[1:]
+			if v, ok := values[name]; ok {
+				segments[i] = v
+			} else {
+				segments[i] = req.Param(name)
+			}
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// describer is implemented by resource controllers that can describe their
+// items as hypermedia. Links are built from the path parameters in values,
+// falling back to those in the request URL.
+type describer interface {
+	kind() string
+	param() string
+	self(req *http.Request, values map[string]string, id string) string
+	collection(req *http.Request, values map[string]string) string
+	relations(req *http.Request, values map[string]string, id string) []relation
+
+	// child returns the owned resource included under a name, or nil
+	child(name string) *ExtendedResource
+	includeLimits() IncludeLimits
+}
+
+// relation describes a link from an item to related items. A relation to a
+// single owner also carries the owner's type and id.
+type relation struct {
+	name string
+	kind string
+	id   string
+	href string
+}
+
+// negotiate returns the hypermedia type a request asks for, or an empty
+// string if the response should be written in its plain format.
+func negotiate(resp http.ResponseWriter, req *http.Request, enabled bool) string {
+	if !enabled || (resp.Format() != "json" && resp.Format() != "") {
+		return ""
+	}
+	if req.Accepts(http.MediaJSONAPI) {
+		return http.MediaJSONAPI
+	}
+	if req.Accepts(http.MediaHAL) {
+		return http.MediaHAL
+	}
+	return ""
+}
+
+// writeItem writes a single item as a JSON:API or HAL document if requested,
+// otherwise in the format of the URL type extension. Sparse fieldsets
+// requested through ?fields= and owned items requested through ?include=
+// apply to every format.
+func writeItem(resp http.ResponseWriter, req *http.Request, d describer, enabled bool, item blueprint.Model) {
+	fields := requestedFields(req)
+	nodes, err := includes(req, d, []blueprint.Model{item})
+	if err != nil {
+		resp.WriteErrs(req, err)
+		return
+	}
+
+	var doc interface{}
+
+	switch negotiate(resp, req, enabled) {
+	case http.MediaJSONAPI:
+		included := &jsonapiIncluded{}
+		var data jsonapiResource
+		if data, err = jsonapiItem(req, d, nodes[0], fields, included); err == nil {
+			doc = jsonapiDocument{Data: data, Included: included.resources}
+		}
+	case http.MediaHAL:
+		doc, err = halItem(req, d, nodes[0], fields)
+	default:
+		v, err := sparse(item, fields, resp.Format())
+		if err == nil {
+			v, err = embed(v, nodes[0], resp.Format(), true)
+		}
+		if err != nil {
+			resp.WriteErrs(req, err)
+			return
+		}
+		resp.WriteFormat(req, v)
+		return
+	}
+	writeDocument(resp, req, doc, err)
+}
+
+// writeList writes a list of items as a JSON:API or HAL document if
+// requested, otherwise in the format of the URL type extension. Sparse
+// fieldsets requested through ?fields= and owned items requested through
+// ?include= apply to every format.
+func writeList(resp http.ResponseWriter, req *http.Request, d describer, enabled bool, items []blueprint.Model) {
+	fields := requestedFields(req)
+	nodes, err := includes(req, d, items)
+	if err != nil {
+		resp.WriteErrs(req, err)
+		return
+	}
+
+	var doc interface{}
+
+	switch negotiate(resp, req, enabled) {
+	case http.MediaJSONAPI:
+		included := &jsonapiIncluded{}
+		data := make([]jsonapiResource, len(nodes))
+		for i, n := range nodes {
+			if data[i], err = jsonapiItem(req, d, n, fields, included); err != nil {
+				break
+			}
+		}
+		doc = jsonapiDocument{
+			Data:     data,
+			Included: included.resources,
+			Links:    map[string]string{"self": d.collection(req, nil)},
+		}
+	case http.MediaHAL:
+		embedded := make([]map[string]interface{}, len(nodes))
+		for i, n := range nodes {
+			if embedded[i], err = halItem(req, d, n, fields); err != nil {
+				break
+			}
+		}
+		doc = map[string]interface{}{
+			"_links":    map[string]halLink{"self": {Href: d.collection(req, nil)}},
+			"_embedded": map[string]interface{}{d.kind(): embedded},
+			"count":     len(items),
+		}
+	default:
+		if requestedIncludes(req).depth() == 0 {
+			v, err := sparseList(items, fields, resp.Format())
+			if err != nil {
+				resp.WriteErrs(req, err)
+				return
+			}
+			resp.WriteFormatList(req, v)
+			return
+		}
+
+		list := make([]interface{}, len(nodes))
+		for i, n := range nodes {
+			v, err := sparse(n.item, fields, resp.Format())
+			if err == nil {
+				list[i], err = embed(v, n, resp.Format(), true)
+			}
+			if err != nil {
+				resp.WriteErrs(req, err)
+				return
+			}
+		}
+		resp.WriteFormatList(req, list)
+		return
+	}
+	writeDocument(resp, req, doc, err)
+}
+
+// writeDocument writes a hypermedia document in the media type requested.
+func writeDocument(resp http.ResponseWriter, req *http.Request, doc interface{}, err error) {
+	if err != nil {
+		resp.WriteErrs(req, err)
+		return
+	}
+	if req.Accepts(http.MediaJSONAPI) {
+		resp.WriteJSONAPI(req.QueryBool("prettyprint"), doc)
+		return
+	}
+	resp.WriteHAL(req.QueryBool("prettyprint"), doc)
+}
+
+// jsonapiDocument represents a top level JSON:API document.
+type jsonapiDocument struct {
+	Data     interface{}       `json:"data"`
+	Included []jsonapiResource `json:"included,omitempty"`
+	Links    map[string]string `json:"links,omitempty"`
+}
+
+// jsonapiResource represents a JSON:API resource object.
+type jsonapiResource struct {
+	Type          string                         `json:"type"`
+	ID            string                         `json:"id,omitempty"`
+	Attributes    map[string]interface{}         `json:"attributes,omitempty"`
+	Relationships map[string]jsonapiRelationship `json:"relationships,omitempty"`
+	Links         map[string]string              `json:"links,omitempty"`
+}
+
+// jsonapiRelationship represents a JSON:API relationship object. Data holds
+// an identifier for an owner, or a list of identifiers for included items.
+type jsonapiRelationship struct {
+	Links map[string]string `json:"links,omitempty"`
+	Data  interface{}       `json:"data,omitempty"`
+}
+
+// jsonapiIdentifier represents a JSON:API resource identifier object.
+type jsonapiIdentifier struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+}
+
+// jsonapiIncluded collects the resource objects of included items, once each.
+type jsonapiIncluded struct {
+	resources []jsonapiResource
+	seen      map[jsonapiIdentifier]bool
+}
+
+// add appends a resource object unless it was already included.
+func (i *jsonapiIncluded) add(res jsonapiResource) {
+	key := jsonapiIdentifier{Type: res.Type, ID: res.ID}
+	if i.seen[key] {
+		return
+	}
+	if i.seen == nil {
+		i.seen = map[jsonapiIdentifier]bool{}
+	}
+	i.seen[key] = true
+	i.resources = append(i.resources, res)
+}
+
+// jsonapiItem wraps an item in a JSON:API resource object. Items included
+// for it are linked as relationship data and collected in included.
+func jsonapiItem(req *http.Request, d describer, n *node, fields fieldTree, included *jsonapiIncluded) (jsonapiResource, error) {
+	props, key, id, err := properties(n.item, fields)
+	if err != nil {
+		return jsonapiResource{}, err
+	}
+	delete(props, key)
+
+	res := jsonapiResource{
+		Type:       d.kind(),
+		ID:         id,
+		Attributes: props,
+		Links:      map[string]string{"self": d.self(req, n.values, id)},
+	}
+
+	for _, rel := range d.relations(req, n.values, id) {
+		if res.Relationships == nil {
+			res.Relationships = map[string]jsonapiRelationship{}
+		}
+		r := jsonapiRelationship{Links: map[string]string{"related": rel.href}}
+		if rel.id != "" {
+			r.Data = &jsonapiIdentifier{Type: rel.kind, ID: rel.id}
+		}
+		res.Relationships[rel.name] = r
+	}
+
+	for _, e := range n.edges {
+		data := make([]jsonapiIdentifier, len(e.nodes))
+		for i, child := range e.nodes {
+			inc, err := jsonapiItem(req, e.d, child, nil, included)
+			if err != nil {
+				return jsonapiResource{}, err
+			}
+			included.add(inc)
+			data[i] = jsonapiIdentifier{Type: inc.Type, ID: inc.ID}
+		}
+
+		if res.Relationships == nil {
+			res.Relationships = map[string]jsonapiRelationship{}
+		}
+		r := res.Relationships[e.name]
+		r.Data = data
+		res.Relationships[e.name] = r
+	}
+	return res, nil
+}
+
+// halLink represents a HAL link object.
+type halLink struct {
+	Href string `json:"href"`
+}
+
+// halItem adds HAL links to the properties of an item, and embeds the items
+// included for it.
+func halItem(req *http.Request, d describer, n *node, fields fieldTree) (map[string]interface{}, error) {
+	props, _, id, err := properties(n.item, fields)
+	if err != nil {
+		return nil, err
+	}
+
+	links := map[string]halLink{"self": {Href: d.self(req, n.values, id)}}
+	for _, rel := range d.relations(req, n.values, id) {
+		links[rel.name] = halLink{Href: rel.href}
+	}
+	props["_links"] = links
+
+	if len(n.edges) > 0 {
+		embedded := map[string]interface{}{}
+		for _, e := range n.edges {
+			list := make([]map[string]interface{}, len(e.nodes))
+			for i, child := range e.nodes {
+				if list[i], err = halItem(req, e.d, child, nil); err != nil {
+					return nil, err
+				}
+			}
+			embedded[e.name] = list
+		}
+		props["_embedded"] = embedded
+	}
+	return props, nil
+}
+
+// properties returns the JSON properties of an item, along with the property
+// key holding its id and the id itself. If fields are requested only those
+// properties are returned, the id is always found.
+func properties(item blueprint.Model, fields fieldTree) (map[string]interface{}, string, string, error) {
+	props, err := jsonProperties(item)
+	if err != nil {
+		return nil, "", "", err
+	}
+	key, id := propertyID(item, props)
+
+	if fields != nil {
+		v, err := sparse(item, fields, "json")
+		if err != nil {
+			return nil, "", "", err
+		}
+		if props, err = jsonProperties(v); err != nil {
+			return nil, "", "", err
+		}
+	}
+	return props, key, id, nil
+}
+
+// identify returns the property key holding the id of an item and the id.
+func identify(item blueprint.Model) (string, string, error) {
+	if i, ok := item.(blueprint.Identifier); ok {
+		return "", i.GetID(), nil
+	}
+	props, err := jsonProperties(item)
+	if err != nil {
+		return "", "", err
+	}
+	key, id := propertyID(item, props)
+	return key, id, nil
+}
+
+// propertyID finds the id of an item in its JSON properties. Models that
+// implement blueprint.Identifier report their own id and no key.
+func propertyID(item blueprint.Model, props map[string]interface{}) (string, string) {
+	if i, ok := item.(blueprint.Identifier); ok {
+		return "", i.GetID()
+	}
+	for _, k := range []string{"id", "_id", "ID"} {
+		if v, ok := props[k]; ok {
+			return k, fmt.Sprint(v)
+		}
+	}
+	return "", ""
+}
+
+// jsonProperties marshals a value and unmarshals it as a JSON object, keeping
+// numbers as they were written.
+func jsonProperties(v interface{}) (map[string]interface{}, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	props := map[string]interface{}{}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	if err := dec.Decode(&props); err != nil {
+		return nil, err
+	}
+	return props, nil
+}
+
+// decode unmarshals a request body into an item. When hypermedia is enabled
+// and the body is a JSON:API document its attributes are unmarshaled instead,
+// and the document's id is returned.
+func decode(req *http.Request, d describer, enabled bool, item blueprint.Model) (string, error) {
+	if !enabled || req.ContentType() != http.MediaJSONAPI {
+		return "", req.Unmarshal(item)
+	}
+
+	var doc struct {
+		Data *struct {
+			Type       string          `json:"type"`
+			ID         string          `json:"id"`
+			Attributes json.RawMessage `json:"attributes"`
+		} `json:"data"`
+	}
+	body, err := req.Bytes()
+	if err != nil {
+		return "", err
+	}
+
+	// documents may hold members such as relationships and meta, so only
+	// their attributes are decoded strictly
+	envelope := *http.DefaultDecoder
+	envelope.AllowUnknownFields = true
+	if err := envelope.Decode(body, &doc); err != nil {
+		return "", err
+	}
+
+	if doc.Data == nil || (doc.Data.Type != "" && doc.Data.Type != d.kind()) {
+		return "", http.ErrInvalidInput
+	}
+
+	if len(doc.Data.Attributes) > 0 {
+		if err := http.DefaultDecoder.Decode(doc.Data.Attributes, item); err != nil {
+			return "", err
+		}
+	}
+	return doc.Data.ID, nil
+}
+
+// typeName returns a lower case plural name for a model's type, used when a
+// resource controller has not been mounted.
+func typeName(m blueprint.Model) string {
+	t := reflect.TypeOf(m)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return strings.ToLower(t.Name()) + "s"
+}
+
+// kind satisfies the describer interface.
+func (r *Resource) kind() string {
+	if len(r.mount.name) > 0 {
+		return r.mount.name
+	}
+	return typeName(r.model)
+}
+
+// self satisfies the describer interface.
+func (r *Resource) self(req *http.Request, values map[string]string, id string) string {
+	return r.collection(req, values) + "/" + id
+}
+
+// collection satisfies the describer interface.
+func (r *Resource) collection(req *http.Request, values map[string]string) string {
+	if len(r.mount.path) == 0 {
+		return "/" + r.kind()
+	}
+	return r.mount.href(req, values)
+}
+
+// relations satisfies the describer interface. Each mounted extended resource
+// is a relation to the items an item owns.
+func (r *Resource) relations(req *http.Request, values map[string]string, id string) []relation {
+	var rels []relation
+	for _, child := range r.children {
+		if len(child.mount.path) == 0 {
+			continue
+		}
+
+		v := map[string]string{r.param(): id}
+		for key, value := range values {
+			v[key] = value
+		}
+		rels = append(rels, relation{
+			name: child.kind(),
+			href: child.mount.href(req, v),
+		})
+	}
+	return rels
+}
+
+// child satisfies the describer interface.
+func (r *Resource) child(name string) *ExtendedResource {
+	for _, child := range r.children {
+		if child.kind() == name {
+			return child
+		}
+	}
+	return nil
+}
+
+// includeLimits satisfies the describer interface.
+func (r *Resource) includeLimits() IncludeLimits {
+	return r.IncludeLimits
+}
+
+// kind satisfies the describer interface.
+func (e *ExtendedResource) kind() string {
+	if len(e.mount.name) > 0 {
+		return e.mount.name
+	}
+	return typeName(e.model)
+}
+
+// self satisfies the describer interface.
+func (e *ExtendedResource) self(req *http.Request, values map[string]string, id string) string {
+	return e.collection(req, values) + "/" + id
+}
+
+// collection satisfies the describer interface.
+func (e *ExtendedResource) collection(req *http.Request, values map[string]string) string {
+	if len(e.mount.path) == 0 {
+		return "/" + e.kind()
+	}
+	return e.mount.href(req, values)
+}
+
+// relations satisfies the describer interface. The owner of an item is its
+// only relation.
+func (e *ExtendedResource) relations(req *http.Request, values map[string]string, id string) []relation {
+	ownerID, ok := values[e.owner.param()]
+	if !ok {
+		var err error
+		if ownerID, err = e.owner.id(req); err != nil {
+			return nil
+		}
+	}
+	return []relation{{
+		name: e.owner.param(),
+		kind: e.owner.kind(),
+		id:   ownerID,
+		href: e.owner.self(req, values, ownerID),
+	}}
+}
+
+// child satisfies the describer interface.
+func (e *ExtendedResource) child(name string) *ExtendedResource {
+	for _, child := range e.children {
+		if child.kind() == name {
+			return child
+		}
+	}
+	return nil
+}
+
+// includeLimits satisfies the describer interface.
+func (e *ExtendedResource) includeLimits() IncludeLimits {
+	return e.IncludeLimits
+}
@@ -0,0 +1,166 @@
+package resource
+
+import (
+	"encoding/json"
+	stdhttp "net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/blueprint/blueprint/mock"
+	http "github.com/blueprint/blueprint/transport"
+	"github.com/julienschmidt/httprouter"
+)
+
+// hypermediaRequest executes an action with an Accept header and returns the
+// response recorder.
+func hypermediaRequest(method, uri, accept, contentType, body string, ps httprouter.Params, action http.HandlerFunc) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, uri, strings.NewReader(body))
+	r.Header.Set("Accept", accept)
+	if contentType != "" {
+		r.Header.Set("Content-Type", contentType)
+	}
+	rec := httptest.NewRecorder()
+	action.ServeHTTP(http.NewResponse(rec, "json"), http.NewRequest(r, ps))
+	return rec
+}
+
+// mountedResources returns a users resource with mounted posts.
+func mountedResources(hypermedia bool) (*Resource, *ExtendedResource) {
+	users := New(mock.NewBaseModel())
+	users.Hypermedia = hypermedia
+	users.Mount("/users", "user")
+
+	posts := users.Extend(mock.NewModel())
+	posts.Hypermedia = hypermedia
+	posts.Mount("/users/:user/posts", "post")
+	return users, posts
+}
+
+func TestHypermediaJSONAPIShow(t *testing.T) {
+	users, _ := mountedResources(true)
+	ps := httprouter.Params{{Key: "user", Value: "42"}}
+
+	rec := hypermediaRequest("GET", "/users/42", http.MediaJSONAPI, "", "", ps, users.Show)
+
+	if ct := rec.Header().Get("Content-Type"); ct != http.MediaJSONAPI {
+		t.Fatalf("expected Content-Type %s got %s", http.MediaJSONAPI, ct)
+	}
+
+	var doc struct {
+		Data jsonapiResource `json:"data"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	if doc.Data.Type != "users" {
+		t.Errorf("expected type 'users' got '%s'", doc.Data.Type)
+	}
+	if doc.Data.ID != "42" {
+		t.Errorf("expected id '42' got '%s'", doc.Data.ID)
+	}
+	if _, ok := doc.Data.Attributes["ID"]; ok {
+		t.Error("expected the id to be removed from the attributes")
+	}
+	if doc.Data.Links["self"] != "/users/42" {
+		t.Errorf("expected self link '/users/42' got '%s'", doc.Data.Links["self"])
+	}
+	if rel := doc.Data.Relationships["posts"]; rel.Links["related"] != "/users/42/posts" {
+		t.Errorf("expected related link '/users/42/posts' got '%s'", rel.Links["related"])
+	}
+}
+
+func TestHypermediaJSONAPIOwner(t *testing.T) {
+	_, posts := mountedResources(true)
+	ps := httprouter.Params{{Key: "user", Value: "42"}, {Key: "post", Value: "7"}}
+
+	rec := hypermediaRequest("GET", "/users/42/posts/7", http.MediaJSONAPI, "", "", ps, posts.Show)
+
+	var doc struct {
+		Data struct {
+			Relationships map[string]struct {
+				Links map[string]string  `json:"links"`
+				Data  *jsonapiIdentifier `json:"data"`
+			} `json:"relationships"`
+			Links map[string]string `json:"links"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	owner, ok := doc.Data.Relationships["user"]
+	if !ok {
+		t.Fatal("expected a 'user' relationship")
+	}
+	if owner.Data == nil || owner.Data.Type != "users" || owner.Data.ID != "42" {
+		t.Errorf("expected owner identifier users/42 got %+v", owner.Data)
+	}
+	if owner.Links["related"] != "/users/42" {
+		t.Errorf("expected related link '/users/42' got '%s'", owner.Links["related"])
+	}
+	if !strings.HasPrefix(doc.Data.Links["self"], "/users/42/posts/") {
+		t.Errorf("expected self link under '/users/42/posts/' got '%s'", doc.Data.Links["self"])
+	}
+}
+
+func TestHypermediaHALIndex(t *testing.T) {
+	users, _ := mountedResources(true)
+
+	rec := hypermediaRequest("GET", "/users", http.MediaHAL, "", "", nil, users.Index)
+
+	if ct := rec.Header().Get("Content-Type"); ct != http.MediaHAL {
+		t.Fatalf("expected Content-Type %s got %s", http.MediaHAL, ct)
+	}
+
+	var doc struct {
+		Links    map[string]halLink                  `json:"_links"`
+		Embedded map[string][]map[string]interface{} `json:"_embedded"`
+		Count    int                                 `json:"count"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	if doc.Links["self"].Href != "/users" {
+		t.Errorf("expected self link '/users' got '%s'", doc.Links["self"].Href)
+	}
+	if doc.Count != 3 || len(doc.Embedded["users"]) != 3 {
+		t.Errorf("expected 3 embedded users got %d", len(doc.Embedded["users"]))
+	}
+	for _, user := range doc.Embedded["users"] {
+		if _, ok := user["_links"]; !ok {
+			t.Error("expected embedded users to have links")
+		}
+	}
+}
+
+func TestHypermediaDisabled(t *testing.T) {
+	users, _ := mountedResources(false)
+	ps := httprouter.Params{{Key: "user", Value: "42"}}
+
+	rec := hypermediaRequest("GET", "/users/42", http.MediaJSONAPI, "", "", ps, users.Show)
+
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json; charset=UTF-8" {
+		t.Errorf("expected plain JSON got %s", ct)
+	}
+}
+
+func TestHypermediaJSONAPIStore(t *testing.T) {
+	users, _ := mountedResources(true)
+
+	tests := []struct {
+		body   string
+		status int
+	}{{`{"data": {"type": "users", "attributes": {}}}`, stdhttp.StatusCreated},
+		{`{"data": {"type": "posts", "attributes": {}}}`, stdhttp.StatusBadRequest},
+		{`{"meta": {}}`, stdhttp.StatusBadRequest}}
+
+	for _, test := range tests {
+		rec := hypermediaRequest("POST", "/users", http.MediaJSONAPI, http.MediaJSONAPI, test.body, nil, users.Store)
+		if rec.Code != test.status {
+			t.Errorf("%s: expected status %d got %d", test.body, test.status, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,288 @@
+package resource
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/blueprint/blueprint"
+	http "github.com/blueprint/blueprint/transport"
+)
+
+// IncludeLimits bounds the compound documents clients may request through
+// ?include=. Zero values fall back to DefaultIncludeLimits.
+type IncludeLimits struct {
+	Depth int // longest include path, such as 2 for "posts.comments"
+	Count int // most items included in a single response
+	Batch int // most owners passed to a single FindAllByOwners call
+}
+
+// DefaultIncludeLimits are used by resource controllers without limits set.
+var DefaultIncludeLimits = IncludeLimits{Depth: 3, Count: 1000, Batch: 100}
+
+// orDefault fills unset limits with their defaults.
+func (l IncludeLimits) orDefault() IncludeLimits {
+	if l.Depth <= 0 {
+		l.Depth = DefaultIncludeLimits.Depth
+	}
+	if l.Count <= 0 {
+		l.Count = DefaultIncludeLimits.Count
+	}
+	if l.Batch <= 0 {
+		l.Batch = DefaultIncludeLimits.Batch
+	}
+	return l
+}
+
+// includeTree is a parsed list of include paths. Unlike sparse fieldsets,
+// including an owned resource never hides the resources nested in it.
+type includeTree map[string]includeTree
+
+// requestedIncludes parses the ?include=a,b,b.c query parameter.
+func requestedIncludes(req *http.Request) includeTree {
+	tree := includeTree{}
+	for _, path := range queryPaths(req, "include") {
+		t := tree
+		for _, name := range path {
+			if t[name] == nil {
+				t[name] = includeTree{}
+			}
+			t = t[name]
+		}
+	}
+	return tree
+}
+
+// depth returns the length of the longest path in the tree.
+func (t includeTree) depth() int {
+	max := 0
+	for _, sub := range t {
+		if d := sub.depth() + 1; d > max {
+			max = d
+		}
+	}
+	return max
+}
+
+// node is an item along with the items included for it.
+type node struct {
+	item blueprint.Model
+
+	// values holds the path parameters of the item's owners, so links can be
+	// built for items that are not in the request URL
+	values map[string]string
+
+	edges []edge
+}
+
+// edge holds the items included for an owner from one owned resource.
+type edge struct {
+	name  string
+	d     describer
+	nodes []*node
+}
+
+// includes resolves the ?include= paths of a request for a list of items.
+// Each item is returned as a node, with no edges if nothing is included.
+func includes(req *http.Request, d describer, items []blueprint.Model) ([]*node, error) {
+	tree := requestedIncludes(req)
+
+	limits := d.includeLimits().orDefault()
+	if tree.depth() > limits.Depth {
+		return nil, fmt.Errorf("%w: include paths are limited to a depth of %d", http.ErrInvalidParameter, limits.Depth)
+	}
+
+	i := &inclusion{req: req, limits: limits}
+	return i.resolve(d, items, make([]map[string]string, len(items)), tree, "")
+}
+
+// inclusion resolves include paths while counting the included items.
+type inclusion struct {
+	req    *http.Request
+	limits IncludeLimits
+	count  int
+}
+
+// resolve finds the owned items of every item for each path in the tree. The
+// items of all owners are found together, level by level, so the number of
+// queries does not grow with the number of owners.
+func (i *inclusion) resolve(d describer, items []blueprint.Model, values []map[string]string, tree includeTree, path string) ([]*node, error) {
+	nodes := make([]*node, len(items))
+	for k, item := range items {
+		nodes[k] = &node{item: item, values: values[k]}
+	}
+
+	names := make([]string, 0, len(tree))
+	for name := range tree {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		child := d.child(name)
+		if child == nil {
+			return nil, fmt.Errorf("%w: unknown include %s", http.ErrInvalidParameter, join(path, name))
+		}
+
+		groups, err := i.find(child.model, items)
+		if err != nil {
+			return nil, err
+		}
+
+		var owned []blueprint.Model
+		var ownedValues []map[string]string
+		for k, group := range groups {
+			_, id, err := identify(items[k])
+			if err != nil {
+				return nil, err
+			}
+
+			v := map[string]string{d.param(): id}
+			for key, value := range nodes[k].values {
+				v[key] = value
+			}
+
+			for _, item := range group {
+				// items might not store their relationships in a database, so
+				// BelongsTo() ensures they are shown in the response
+				if err := item.BelongsTo(items[k]); err != nil {
+					return nil, err
+				}
+				if err := trigger(i.req, afterFind, item); err != nil {
+					return nil, err
+				}
+			}
+
+			// owned items are filtered as the owned resource's Index filters
+			// them, leaving out trashed items and those the principal may not
+			// see
+			kept := allowed(i.req, child.policy(i.req), visible(i.req, group))
+			for _, item := range kept {
+				owned = append(owned, item)
+				ownedValues = append(ownedValues, v)
+			}
+			groups[k] = kept
+		}
+
+		i.count += len(owned)
+		if i.count > i.limits.Count {
+			return nil, fmt.Errorf("%w: include of %s exceeds %d items", http.ErrInvalidParameter, join(path, name), i.limits.Count)
+		}
+
+		sub, err := i.resolve(child, owned, ownedValues, tree[name], join(path, name))
+		if err != nil {
+			return nil, err
+		}
+
+		for k, group := range groups {
+			nodes[k].edges = append(nodes[k].edges, edge{name: name, d: child, nodes: sub[:len(group)]})
+			sub = sub[len(group):]
+		}
+	}
+	return nodes, nil
+}
+
+// find returns the items owned by each owner, in batches when the model
+// implements blueprint.OwnerBatchFinder.
+func (i *inclusion) find(m blueprint.Model, owners []blueprint.Model) ([][]blueprint.Model, error) {
+	batcher, ok := m.(blueprint.OwnerBatchFinder)
+
+	groups := make([][]blueprint.Model, 0, len(owners))
+	for start := 0; start < len(owners); start += i.limits.Batch {
+		end := start + i.limits.Batch
+		if end > len(owners) {
+			end = len(owners)
+		}
+		batch := owners[start:end]
+
+		if ok {
+			found, err := batcher.FindAllByOwners(batch)
+			if err != nil {
+				return nil, err
+			}
+			if len(found) != len(batch) {
+				return nil, fmt.Errorf("resource: FindAllByOwners returned %d groups for %d owners", len(found), len(batch))
+			}
+			groups = append(groups, found...)
+			continue
+		}
+
+		for _, owner := range batch {
+			items, err := findAllByOwner(i.req.Context(), m, owner)
+			if err != nil {
+				return nil, err
+			}
+			groups = append(groups, items)
+		}
+	}
+	return groups, nil
+}
+
+// embed returns a copy of a value with the items included for it added as
+// fields named after their include, for writing in a plain format.
+func embed(v interface{}, n *node, format string, top bool) (interface{}, error) {
+	if len(n.edges) == 0 && top {
+		return v, nil
+	}
+
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("resource: unable to include items in a %s", rv.Kind())
+	}
+
+	// copy every field, except those replaced by included items
+	s := &selector{format: format}
+	tree := fieldTree{}
+	for _, name := range s.names(rv.Type()) {
+		tree[name] = nil
+	}
+	for _, e := range n.edges {
+		delete(tree, e.name)
+	}
+
+	path := ""
+	if !top {
+		path = "item"
+	}
+	base, err := s.pruneStruct(rv, tree, path, "")
+	if err != nil {
+		return nil, err
+	}
+
+	var fields []reflect.StructField
+	var values []reflect.Value
+	for k := 0; k < base.NumField(); k++ {
+		f := base.Type().Field(k)
+
+		// a nested XML name would conflict with the name of the include
+		if !top && f.Name == "XMLName" {
+			continue
+		}
+		fields = append(fields, f)
+		values = append(values, base.Field(k))
+	}
+
+	for k, e := range n.edges {
+		list := make([]interface{}, len(e.nodes))
+		for j, child := range e.nodes {
+			if list[j], err = embed(child.item, child, format, false); err != nil {
+				return nil, err
+			}
+		}
+		fields = append(fields, reflect.StructField{
+			Name: fmt.Sprintf("Include%d", k),
+			Type: reflect.TypeOf(list),
+			Tag:  reflect.StructTag(fmt.Sprintf(`json:"%s" xml:"%s" yaml:"%s"`, e.name, e.name, e.name)),
+		})
+		values = append(values, reflect.ValueOf(list))
+	}
+
+	out := reflect.New(reflect.StructOf(fields)).Elem()
+	for k, value := range values {
+		out.Field(k).Set(value)
+	}
+	return out.Interface(), nil
+}
@@ -0,0 +1,310 @@
+package resource
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	stdhttp "net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/blueprint/blueprint"
+	"github.com/blueprint/blueprint/mock"
+	http "github.com/blueprint/blueprint/transport"
+	"github.com/julienschmidt/httprouter"
+)
+
+// record is a model that owns two records for each owner.
+type record struct {
+	*mock.Model `json:"-" xml:"-" yaml:"-"`
+	XMLName     xml.Name `json:"-" xml:"record" yaml:"-"`
+	ID          string   `json:"id" xml:"id" yaml:"id"`
+	Owner       string   `json:"owner,omitempty" xml:"owner,omitempty" yaml:"owner,omitempty"`
+
+	calls *int
+}
+
+func newRecord() *record {
+	return &record{Model: mock.NewModel(), calls: new(int)}
+}
+
+func (r *record) New() blueprint.Model {
+	return &record{Model: r.Model, calls: r.calls}
+}
+
+func (r *record) FindAll() ([]blueprint.Model, error) {
+	return []blueprint.Model{&record{ID: "1"}, &record{ID: "2"}}, nil
+}
+
+func (r *record) FindByID(id string) error {
+	r.ID = id
+	return nil
+}
+
+func (r *record) FindAllByOwner(owner blueprint.Model) ([]blueprint.Model, error) {
+	*r.calls++
+	id := owner.(*record).ID
+	return []blueprint.Model{&record{ID: id + "-1"}, &record{ID: id + "-2"}}, nil
+}
+
+func (r *record) BelongsTo(owner blueprint.Model) error {
+	r.Owner = owner.(*record).ID
+	return nil
+}
+
+// batchRecord finds the records of many owners in one call.
+type batchRecord struct {
+	*record
+}
+
+func (b batchRecord) FindAllByOwners(owners []blueprint.Model) ([][]blueprint.Model, error) {
+	*b.calls++
+	groups := make([][]blueprint.Model, len(owners))
+	for i, owner := range owners {
+		id := owner.(*record).ID
+		groups[i] = []blueprint.Model{&record{ID: id + "-1"}, &record{ID: id + "-2"}}
+	}
+	return groups, nil
+}
+
+// includeResources returns users owning posts owning comments.
+func includeResources(posts blueprint.Model) (*Resource, *ExtendedResource) {
+	users := New(newRecord())
+	users.Hypermedia = true
+	users.Mount("/users", "user")
+
+	userPosts := users.Extend(posts)
+	userPosts.Hypermedia = true
+	userPosts.Mount("/users/:user/posts", "post")
+
+	postResource := New(newRecord())
+	postResource.Mount("/posts", "post")
+	comments := postResource.Extend(newRecord())
+	comments.Mount("/posts/:post/comments", "comment")
+	userPosts.children = append(userPosts.children, comments)
+
+	return users, userPosts
+}
+
+func includeRequest(uri, accept string, ps httprouter.Params, action http.HandlerFunc) *httptest.ResponseRecorder {
+	r := httptest.NewRequest("GET", uri, nil)
+	r.Header.Set("Accept", accept)
+	format := "json"
+	if strings.Contains(uri, ".xml") {
+		format = "xml"
+	}
+	rec := httptest.NewRecorder()
+	action.ServeHTTP(http.NewResponse(rec, format), http.NewRequest(r, ps))
+	return rec
+}
+
+func TestIncludeJSON(t *testing.T) {
+	users, _ := includeResources(newRecord())
+	ps := httprouter.Params{{Key: "user", Value: "1"}}
+
+	rec := includeRequest("/users/1?include=posts.comments", "", ps, users.Show)
+	if rec.Code != stdhttp.StatusOK {
+		t.Fatalf("expected status %d got %d: %s", stdhttp.StatusOK, rec.Code, rec.Body.String())
+	}
+
+	var user struct {
+		ID    string `json:"id"`
+		Posts []struct {
+			ID       string `json:"id"`
+			Owner    string `json:"owner"`
+			Comments []struct {
+				ID    string `json:"id"`
+				Owner string `json:"owner"`
+			} `json:"comments"`
+		} `json:"posts"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &user); err != nil {
+		t.Fatal(err)
+	}
+
+	if user.ID != "1" || len(user.Posts) != 2 {
+		t.Fatalf("expected user 1 with 2 posts got %+v", user)
+	}
+	post := user.Posts[1]
+	if post.ID != "1-2" || post.Owner != "1" {
+		t.Errorf("expected post 1-2 owned by 1 got %+v", post)
+	}
+	if len(post.Comments) != 2 || post.Comments[0].ID != "1-2-1" || post.Comments[0].Owner != "1-2" {
+		t.Errorf("expected comments of post 1-2 got %+v", post.Comments)
+	}
+}
+
+func TestIncludeXML(t *testing.T) {
+	users, _ := includeResources(newRecord())
+	ps := httprouter.Params{{Key: "user", Value: "1.xml"}}
+
+	rec := includeRequest("/users/1.xml?include=posts", "", ps, users.Show)
+	body := rec.Body.String()
+	if !strings.Contains(body, "<record><id>1</id><posts><id>1-1</id><owner>1</owner></posts>") {
+		t.Errorf("expected a record with embedded posts got %s", body)
+	}
+}
+
+func TestIncludeBatch(t *testing.T) {
+	posts := newRecord()
+	users, _ := includeResources(posts)
+
+	includeRequest("/users?include=posts", "", nil, users.Index)
+	if *posts.calls != 2 {
+		t.Errorf("expected FindAllByOwner to be called for 2 owners got %d calls", *posts.calls)
+	}
+
+	batch := batchRecord{newRecord()}
+	users, _ = includeResources(batch)
+
+	rec := includeRequest("/users?include=posts", "", nil, users.Index)
+	if *batch.calls != 1 {
+		t.Errorf("expected 1 FindAllByOwners call got %d", *batch.calls)
+	}
+	var list []struct {
+		Posts []json.RawMessage `json:"posts"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || len(list[0].Posts) != 2 || len(list[1].Posts) != 2 {
+		t.Errorf("expected 2 users with 2 posts each got %s", rec.Body.String())
+	}
+
+	*batch.calls = 0
+	users.IncludeLimits = IncludeLimits{Batch: 1}
+	includeRequest("/users?include=posts", "", nil, users.Index)
+	if *batch.calls != 2 {
+		t.Errorf("expected 2 FindAllByOwners calls got %d", *batch.calls)
+	}
+}
+
+func TestIncludeLimits(t *testing.T) {
+	tests := []struct {
+		uri    string
+		limits IncludeLimits
+		status int
+	}{{"/users?include=posts.comments", IncludeLimits{}, stdhttp.StatusOK},
+		{"/users?include=posts.comments", IncludeLimits{Depth: 1}, stdhttp.StatusBadRequest},
+		{"/users?include=posts", IncludeLimits{Count: 4}, stdhttp.StatusOK},
+		{"/users?include=posts", IncludeLimits{Count: 3}, stdhttp.StatusBadRequest},
+		{"/users?include=posts.comments", IncludeLimits{Count: 8}, stdhttp.StatusBadRequest},
+		{"/users?include=friends", IncludeLimits{}, stdhttp.StatusBadRequest},
+		{"/users?include=posts.likes", IncludeLimits{}, stdhttp.StatusBadRequest}}
+
+	for _, test := range tests {
+		users, _ := includeResources(newRecord())
+		users.IncludeLimits = test.limits
+
+		rec := includeRequest(test.uri, "", nil, users.Index)
+		if rec.Code != test.status {
+			t.Errorf("%s %+v: expected status %d got %d", test.uri, test.limits, test.status, rec.Code)
+		}
+	}
+}
+
+func TestIncludeJSONAPI(t *testing.T) {
+	users, _ := includeResources(newRecord())
+	ps := httprouter.Params{{Key: "user", Value: "1"}}
+
+	rec := includeRequest("/users/1?include=posts.comments", http.MediaJSONAPI, ps, users.Show)
+
+	var doc struct {
+		Data struct {
+			Relationships map[string]struct {
+				Data []jsonapiIdentifier `json:"data"`
+			} `json:"relationships"`
+		} `json:"data"`
+		Included []jsonapiResource `json:"included"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	data := doc.Data.Relationships["posts"].Data
+	if len(data) != 2 || data[0] != (jsonapiIdentifier{Type: "posts", ID: "1-1"}) {
+		t.Errorf("expected posts relationship data got %+v", data)
+	}
+	if len(doc.Included) != 6 {
+		t.Fatalf("expected 2 posts and 4 comments included got %d", len(doc.Included))
+	}
+
+	var comment jsonapiResource
+	for _, res := range doc.Included {
+		if res.Type == "comments" && res.ID == "1-1-1" {
+			comment = res
+		}
+	}
+	if comment.Links["self"] != "/posts/1-1/comments/1-1-1" {
+		t.Errorf("expected self link '/posts/1-1/comments/1-1-1' got '%s'", comment.Links["self"])
+	}
+}
+
+func TestIncludeHAL(t *testing.T) {
+	users, _ := includeResources(newRecord())
+
+	rec := includeRequest("/users?include=posts", http.MediaHAL, nil, users.Index)
+
+	var doc struct {
+		Embedded map[string][]struct {
+			Embedded map[string][]struct {
+				ID    string             `json:"id"`
+				Links map[string]halLink `json:"_links"`
+			} `json:"_embedded"`
+		} `json:"_embedded"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	embedded := doc.Embedded["users"]
+	if len(embedded) != 2 || len(embedded[1].Embedded["posts"]) != 2 {
+		t.Fatalf("expected 2 users with 2 embedded posts got %s", rec.Body.String())
+	}
+	post := embedded[1].Embedded["posts"][0]
+	if post.Links["self"].Href != "/users/2/posts/2-1" {
+		t.Errorf("expected self link '/users/2/posts/2-1' got '%s'", post.Links["self"].Href)
+	}
+	if post.Links["user"].Href != "/users/2" {
+		t.Errorf("expected owner link '/users/2' got '%s'", post.Links["user"].Href)
+	}
+}
+
+func TestIncludeFiltered(t *testing.T) {
+	users := New(newRecord())
+	users.Mount("/users", "user")
+	bins := users.Extend(newBin())
+	bins.Mount("/users/:user/bins", "bin")
+
+	// the policy of the route hides bin b, trashed bin c is hidden unless asked for
+	hide := PolicyFunc(func(ctx context.Context, principal interface{}, action Action, item blueprint.Model) bool {
+		b, ok := item.(*bin)
+		return !ok || b.ID != "b"
+	})
+	show := func(resp http.ResponseWriter, req *http.Request) {
+		req.SetContext(WithPolicy(req.Context(), hide))
+		users.Show(resp, req)
+	}
+
+	ps := httprouter.Params{{Key: "user", Value: "1"}}
+	for uri, expected := range map[string]string{
+		"/users/1?include=bins":              "a",
+		"/users/1?include=bins&trashed=true": "c",
+	} {
+		rec := includeRequest(uri, "", ps, show)
+		var doc struct {
+			Bins []bin `json:"bins"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &doc); err != nil {
+			t.Fatal(err)
+		}
+		var ids []string
+		for _, item := range doc.Bins {
+			ids = append(ids, item.ID)
+		}
+		if got := strings.Join(ids, ","); got != expected {
+			t.Errorf("%s: expected bins %q included got %q", uri, expected, got)
+		}
+	}
+}
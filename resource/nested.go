@@ -0,0 +1,160 @@
+package resource
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/blueprint/blueprint"
+	http "github.com/blueprint/blueprint/transport"
+)
+
+// ErrNotOwned is returned for nested items that belong to another owner
+// than the one in the request path, and answered with 404 Not Found.
+var ErrNotOwned = errors.New("item of another owner")
+
+func init() {
+	http.ApplyErrorCode(ErrNotOwned, http.StatusNotFound)
+}
+
+// Nester is implemented by resource controllers owned by another resource
+// controller. Mux.Resource routes them under the item path of their owner,
+// so the owner must be routed first. OwnerPath returns an empty string until
+// it is.
+type Nester interface {
+	OwnerPath() string
+}
+
+// owner is implemented by resource controllers that extended resources are
+// nested under, at any depth.
+type owner interface {
+	describer
+
+	// id returns the id of the owner's item in the request
+	id(req *http.Request) (string, error)
+
+	// find loads the owner's item in the request, after verifying that each
+	// of its own ancestors exists and owns the next
+	find(req *http.Request) (blueprint.Model, error)
+
+	// itemPath returns the route path of a single item, or an empty string if
+	// the owner has not been mounted
+	itemPath() string
+}
+
+// Extend extends an extended resource, nesting another level of items under
+// the items it holds, such as comments under /users/:user/posts/:post.
+//
+// Optional way to get an ID from request.
+func (e *ExtendedResource) Extend(m blueprint.Model, optID ...func(req *http.Request) (string, error)) *ExtendedResource {
+	child := &ExtendedResource{model: m, owner: e}
+	child.ID = func(req *http.Request) (string, error) {
+		return PathID(req, child.param())
+	}
+
+	if len(optID) > 0 {
+		child.ID = optID[0]
+	}
+
+	e.children = append(e.children, child)
+	return child
+}
+
+// OwnerPath satisfies the Nester interface.
+func (e *ExtendedResource) OwnerPath() string {
+	return e.owner.itemPath()
+}
+
+// base finds the item that owns the items of a request.
+func (e *ExtendedResource) base(req *http.Request) (blueprint.Model, error) {
+	return e.owner.find(req)
+}
+
+// missing reports an ancestor of a request that could not be found.
+func missing(d describer, id string, err error) error {
+	return fmt.Errorf("%w: %s %s not found", err, d.param(), id)
+}
+
+// id satisfies the owner interface.
+func (r *Resource) id(req *http.Request) (string, error) {
+	return r.ID(req)
+}
+
+// find satisfies the owner interface.
+func (r *Resource) find(req *http.Request) (blueprint.Model, error) {
+	id, err := r.ID(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to identify %s id", err, r.param())
+	}
+
+	item := r.model.New()
+	if err := findByID(req.Context(), item, id); err != nil {
+		return nil, missing(r, id, err)
+	}
+	return item, nil
+}
+
+// itemPath satisfies the owner interface.
+func (r *Resource) itemPath() string {
+	if len(r.mount.path) == 0 {
+		return ""
+	}
+	return r.mount.path + "/:" + r.param()
+}
+
+// id satisfies the owner interface.
+func (e *ExtendedResource) id(req *http.Request) (string, error) {
+	return e.ID(req)
+}
+
+// find satisfies the owner interface.
+func (e *ExtendedResource) find(req *http.Request) (blueprint.Model, error) {
+	base, err := e.base(req)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := e.ID(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to identify %s id", err, e.param())
+	}
+
+	item := e.model.New()
+	if err := findByID(req.Context(), item, id); err != nil {
+		return nil, missing(e, id, err)
+	}
+
+	if err := e.owns(req, item); err != nil {
+		return nil, err
+	}
+
+	if err := item.BelongsTo(base); err != nil {
+		return nil, fmt.Errorf("%w: %s %s does not belong to its %s", err, e.param(), id, e.owner.param())
+	}
+	return item, nil
+}
+
+// owns checks that an item that satisfies blueprint.Owned belongs to the
+// owner in the request path.
+func (e *ExtendedResource) owns(req *http.Request, item blueprint.Model) error {
+	o, ok := item.(blueprint.Owned)
+	if !ok {
+		return nil
+	}
+
+	id, err := e.owner.id(req)
+	if err != nil {
+		return fmt.Errorf("%w: unable to identify %s id", err, e.owner.param())
+	}
+	if o.OwnerID() != id {
+		return fmt.Errorf("%w: %s does not belong to %s %s", ErrNotOwned, e.param(), e.owner.param(), id)
+	}
+	return nil
+}
+
+// itemPath satisfies the owner interface.
+func (e *ExtendedResource) itemPath() string {
+	if len(e.mount.path) == 0 {
+		return ""
+	}
+	return e.mount.path + "/:" + e.param()
+}
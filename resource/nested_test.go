@@ -0,0 +1,151 @@
+package resource
+
+import (
+	stdhttp "net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/blueprint/blueprint"
+	"github.com/blueprint/blueprint/mock"
+	http "github.com/blueprint/blueprint/transport"
+	"github.com/julienschmidt/httprouter"
+)
+
+// branch is a model nested to any depth. The id "missing" is never found,
+// items with the id "orphan" belong to no owner, and other items belong to the
+// item whose id is one less.
+type branch struct {
+	*mock.Model `json:"-" xml:"-" yaml:"-"`
+	ID          string `json:"id" xml:"id" yaml:"id"`
+	Owner       string `json:"owner,omitempty" xml:"owner,omitempty" yaml:"owner,omitempty"`
+}
+
+func (b *branch) New() blueprint.Model {
+	return &branch{Model: mock.NewModel()}
+}
+
+func (b *branch) FindByID(id string) error {
+	if id == "missing" {
+		return http.ErrInvalidID
+	}
+	b.ID = id
+	if n, err := strconv.Atoi(id); err == nil {
+		b.Owner = strconv.Itoa(n - 1)
+	}
+	return nil
+}
+
+func (b *branch) OwnerID() string {
+	return b.Owner
+}
+
+func (b *branch) BelongsTo(owner blueprint.Model) error {
+	if b.ID == "orphan" {
+		return http.ErrInvalidInput
+	}
+	b.Owner = owner.(*branch).ID
+	return nil
+}
+
+// tree returns comments nested under posts nested under users.
+func tree() (users *Resource, posts, comments *ExtendedResource) {
+	users = New(&branch{})
+	users.Mount("/users", "user")
+	posts = users.Extend(&branch{})
+	posts.Mount("/users/:user/posts", "post")
+	comments = posts.Extend(&branch{})
+	comments.Mount("/users/:user/posts/:post/comments", "comment")
+	return users, posts, comments
+}
+
+func nestedRequest(user, post, comment string, action http.HandlerFunc) *httptest.ResponseRecorder {
+	ps := httprouter.Params{{Key: "user", Value: user}, {Key: "post", Value: post}, {Key: "comment", Value: comment}}
+	r := httptest.NewRequest("GET", "/users/"+user+"/posts/"+post+"/comments/"+comment, nil)
+	rec := httptest.NewRecorder()
+	action.ServeHTTP(http.NewResponse(rec, "json"), http.NewRequest(r, ps))
+	return rec
+}
+
+func TestNestedShow(t *testing.T) {
+	_, _, comments := tree()
+
+	rec := nestedRequest("1", "2", "3", comments.Show)
+	if rec.Code != stdhttp.StatusOK {
+		t.Fatalf("expected status %d got %d: %s", stdhttp.StatusOK, rec.Code, rec.Body.String())
+	}
+	if body := rec.Body.String(); !strings.Contains(body, `"id":"3"`) || !strings.Contains(body, `"owner":"2"`) {
+		t.Errorf("expected comment 3 of post 2 got %s", body)
+	}
+}
+
+func TestNestedAncestors(t *testing.T) {
+	_, _, comments := tree()
+
+	tests := []struct {
+		user, post, comment string
+		status              int
+		message             string
+	}{{"missing", "2", "3", stdhttp.StatusBadRequest, "user missing not found"},
+		{"1", "missing", "3", stdhttp.StatusBadRequest, "post missing not found"},
+		{"1", "orphan", "3", stdhttp.StatusNotFound, "post does not belong to user 1"},
+		{"1", "2", "missing", stdhttp.StatusBadRequest, ""}}
+
+	for _, test := range tests {
+		rec := nestedRequest(test.user, test.post, test.comment, comments.Show)
+		if rec.Code != test.status {
+			t.Errorf("%s/%s/%s: expected status %d got %d", test.user, test.post, test.comment, test.status, rec.Code)
+		}
+		if !strings.Contains(rec.Body.String(), test.message) {
+			t.Errorf("%s/%s/%s: expected error %q got %s", test.user, test.post, test.comment, test.message, rec.Body.String())
+		}
+	}
+}
+
+func TestNestedPaths(t *testing.T) {
+	users, posts, comments := tree()
+
+	if path := posts.OwnerPath(); path != "/users/:user" {
+		t.Errorf("expected owner path /users/:user got %s", path)
+	}
+	if path := comments.OwnerPath(); path != "/users/:user/posts/:post" {
+		t.Errorf("expected owner path /users/:user/posts/:post got %s", path)
+	}
+	if users.child("posts") != posts || posts.child("comments") != comments {
+		t.Error("expected nested resources to be included by name")
+	}
+}
+
+func TestNestedOtherOwner(t *testing.T) {
+	_, posts, comments := tree()
+	comments.Member("POST", "flag", func(resp http.ResponseWriter, req *http.Request, item blueprint.Model) {
+		resp.WriteFormat(req, item)
+	})
+	flag := comments.Routes()[0].Handler
+
+	// post 5 belongs to user 4, and comment 7 to post 6
+	tests := []struct {
+		user, post, comment string
+		action              http.HandlerFunc
+	}{{"1", "5", "", posts.Show},
+		{"1", "5", "", posts.Apply},
+		{"1", "5", "", posts.Update},
+		{"1", "5", "", posts.Destroy},
+		{"1", "2", "7", comments.Show},
+		{"1", "2", "7", comments.Apply},
+		{"1", "2", "7", comments.Update},
+		{"1", "2", "7", comments.Destroy},
+		{"1", "2", "7", flag},
+		{"1", "5", "6", comments.Show}}
+
+	for _, test := range tests {
+		ps := httprouter.Params{{Key: "user", Value: test.user}, {Key: "post", Value: test.post}, {Key: "comment", Value: test.comment}}
+		r := httptest.NewRequest("PATCH", "/users/"+test.user+"/posts/"+test.post, strings.NewReader(`{"id": "`+test.post+`"}`))
+		rec := httptest.NewRecorder()
+		test.action(http.NewResponse(rec, "json"), http.NewRequest(r, ps))
+		if rec.Code != stdhttp.StatusNotFound {
+			t.Errorf("%s/%s/%s: expected status %d got %d: %s", test.user, test.post, test.comment, stdhttp.StatusNotFound, rec.Code, rec.Body.String())
+		}
+	}
+}
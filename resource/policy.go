@@ -0,0 +1,121 @@
+package resource
+
+import (
+	"context"
+
+	"github.com/blueprint/blueprint"
+	http "github.com/blueprint/blueprint/transport"
+)
+
+// Action names a resource controller action.
+type Action string
+
+// Resource controller actions that policies are consulted for.
+const (
+	IndexAction   Action = "index"
+	ShowAction    Action = "show"
+	StoreAction   Action = "store"
+	UpdateAction  Action = "update"
+	ApplyAction   Action = "apply"
+	DestroyAction Action = "destroy"
+	RestoreAction Action = "restore"
+	PurgeAction   Action = "purge"
+)
+
+// Policy decides which actions a principal may perform on items. Allow is
+// called with the item an action is performed on, as loaded or decoded, and
+// with each item of a listing so that Index only shows items the principal
+// may see. The principal is the one http.PrincipalFrom finds in the request
+// context.
+type Policy interface {
+	Allow(ctx context.Context, principal interface{}, action Action, item blueprint.Model) bool
+}
+
+// PolicyFunc is an adapter to allow the use of ordinary functions as policies.
+type PolicyFunc func(ctx context.Context, principal interface{}, action Action, item blueprint.Model) bool
+
+// Allow satisfies the Policy interface.
+func (f PolicyFunc) Allow(ctx context.Context, principal interface{}, action Action, item blueprint.Model) bool {
+	return f(ctx, principal, action, item)
+}
+
+// policyContextKey is the key a policy is stored under in a request context.
+type policyContextKey struct{}
+
+// WithPolicy returns a copy of a context holding a policy. Resource controllers
+// without a policy of their own consult the policy in the request context,
+// which is how router.Authorize registers policies next to Mux.Resource.
+func WithPolicy(ctx context.Context, p Policy) context.Context {
+	return context.WithValue(ctx, policyContextKey{}, p)
+}
+
+// policyFrom returns the policy held by a context, if any.
+func policyFrom(ctx context.Context) Policy {
+	p, _ := ctx.Value(policyContextKey{}).(Policy)
+	return p
+}
+
+// authorize checks a policy allows the principal of a request an action on
+// an item. No policy allows every action.
+func authorize(req *http.Request, p Policy, action Action, item blueprint.Model) error {
+	if p == nil {
+		return nil
+	}
+
+	ctx := req.Context()
+	if !p.Allow(ctx, http.PrincipalFrom(ctx), action, item) {
+		return http.ErrInvalidPermission
+	}
+	return nil
+}
+
+// allowed returns the items of a listing a policy allows the principal of a
+// request to see.
+func allowed(req *http.Request, p Policy, items []blueprint.Model) []blueprint.Model {
+	if p == nil {
+		return items
+	}
+
+	list := make([]blueprint.Model, 0, len(items))
+	for _, item := range items {
+		if authorize(req, p, IndexAction, item) == nil {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// policy returns the policy of the resource controller, or the one registered
+// for the route of a request.
+func (r *Resource) policy(req *http.Request) Policy {
+	if r.Policy != nil {
+		return r.Policy
+	}
+	return policyFrom(req.Context())
+}
+
+// policy returns the policy of the resource controller, or the one registered
+// for the route of a request.
+func (e *ExtendedResource) policy(req *http.Request) Policy {
+	if e.Policy != nil {
+		return e.Policy
+	}
+	return policyFrom(req.Context())
+}
+
+// load finds the item with an id and checks a policy allows an action on it.
+func (r *Resource) load(req *http.Request, action Action, id string) (blueprint.Model, error) {
+	item := r.model.New()
+	if err := findByID(req.Context(), item, id); err != nil {
+		return nil, err
+	}
+
+	if err := trigger(req, afterFind, item); err != nil {
+		return nil, err
+	}
+
+	if err := authorize(req, r.policy(req), action, item); err != nil {
+		return nil, err
+	}
+	return item, nil
+}
@@ -0,0 +1,99 @@
+package resource
+
+import (
+	"context"
+	stdhttp "net/http"
+	"reflect"
+	"testing"
+
+	"github.com/blueprint/blueprint"
+	http "github.com/blueprint/blueprint/transport"
+	"github.com/julienschmidt/httprouter"
+)
+
+// owners allows privileged API keys every action, and other keys only actions
+// on the bin named after them.
+var owners = PolicyFunc(func(ctx context.Context, principal interface{}, action Action, item blueprint.Model) bool {
+	key, _ := principal.(http.APIKey)
+	return key.Privileged || key.Key == item.(*bin).ID
+})
+
+func TestPolicyIndex(t *testing.T) {
+	r := New(newBin())
+	r.Policy = owners
+
+	if ids := listed(t, trashRequest("GET", "/bins", &http.APIKey{Key: "a"}, nil, r.Index)); ids != "a" {
+		t.Errorf("expected items 'a' got '%s'", ids)
+	}
+	if ids := listed(t, trashRequest("GET", "/bins", &http.APIKey{Key: "admin", Privileged: true}, nil, r.Index)); ids != "a,b" {
+		t.Errorf("expected items 'a,b' got '%s'", ids)
+	}
+}
+
+func TestPolicyActions(t *testing.T) {
+	m := newBin()
+	r := New(m)
+	r.Mount("/bins", "bin")
+	r.Policy = owners
+	ps := httprouter.Params{{Key: "bin", Value: "b"}}
+
+	tests := []struct {
+		method string
+		key    string
+		action http.HandlerFunc
+		status int
+	}{{"GET", "a", r.Show, stdhttp.StatusForbidden},
+		{"GET", "b", r.Show, stdhttp.StatusOK},
+		{"DELETE", "a", r.Destroy, stdhttp.StatusForbidden},
+		{"DELETE", "b", r.Destroy, stdhttp.StatusNoContent}}
+
+	for _, test := range tests {
+		rec := trashRequest(test.method, "/bins/b", &http.APIKey{Key: test.key}, ps, test.action)
+		if rec.Code != test.status {
+			t.Errorf("%s %s: expected status %d got %d", test.method, test.key, test.status, rec.Code)
+		}
+		if test.status == stdhttp.StatusForbidden && m.store["b"] {
+			t.Errorf("%s %s: expected item 'b' not to be trashed", test.method, test.key)
+		}
+	}
+}
+
+func TestPolicyContext(t *testing.T) {
+	r := New(newBin())
+	r.Mount("/bins", "bin")
+	ps := httprouter.Params{{Key: "bin", Value: "a"}}
+
+	deny := PolicyFunc(func(ctx context.Context, principal interface{}, action Action, item blueprint.Model) bool {
+		return false
+	})
+	show := func(resp http.ResponseWriter, req *http.Request) {
+		req.SetContext(WithPolicy(req.Context(), deny))
+		r.Show(resp, req)
+	}
+
+	if rec := trashRequest("GET", "/bins/a", nil, ps, show); rec.Code != stdhttp.StatusForbidden {
+		t.Errorf("expected status %d got %d", stdhttp.StatusForbidden, rec.Code)
+	}
+
+	// the policy of a resource controller is preferred
+	r.Policy = owners
+	if rec := trashRequest("GET", "/bins/a", &http.APIKey{Key: "a"}, ps, show); rec.Code != stdhttp.StatusOK {
+		t.Errorf("expected status %d got %d", stdhttp.StatusOK, rec.Code)
+	}
+}
+
+func TestPolicyBulk(t *testing.T) {
+	m := newLedger()
+	bulk := NewBulk(New(m))
+	bulk.Policy = PolicyFunc(func(ctx context.Context, principal interface{}, action Action, item blueprint.Model) bool {
+		return item.(*ledger).ID != "b"
+	})
+
+	_, statuses, _ := bulkRequest(t, "DELETE", "/ledgers?ids=a,b", "", bulk.BulkDestroy)
+	if expected := []int{204, 403}; !reflect.DeepEqual(statuses, expected) {
+		t.Errorf("expected item statuses %v got %v", expected, statuses)
+	}
+	if expected := []string{"delete a"}; !reflect.DeepEqual(*m.log, expected) {
+		t.Errorf("expected %v got %v", expected, *m.log)
+	}
+}
@@ -3,7 +3,6 @@ package resource
 
 import (
 	"errors"
-	"log"
 	"net/http"
 
 	"github.com/blueprint/blueprint/model"
@@ -23,6 +22,25 @@ type Resourcer interface {
 type Resource struct {
 	model model.Model
 	ID    func(req *http.Request) (string, error)
+
+	// Hypermedia enables JSON:API and HAL documents for requests that ask for
+	// them through the Accept header.
+	Hypermedia bool
+
+	// IncludeLimits bounds the owned items clients may include through
+	// ?include=, DefaultIncludeLimits are used if unset.
+	IncludeLimits IncludeLimits
+
+	// Policy authorizes every action, falling back to a policy registered for
+	// the route through router.Authorize. Without either, all actions are
+	// allowed.
+	Policy Policy
+
+	// Actions chooses the actions Mux.Resource routes and holds custom ones.
+	Actions
+
+	mount    mount
+	children []*ExtendedResource
 }
 
 // New takes a model and returns a new Resource.
@@ -30,11 +48,9 @@ type Resource struct {
 // It also takes an optional func to get an ID from request.
 // This is useful for '/user' routes based on session identification.
 func New(m model.Model, optID ...func(req *http.Request) (string, error)) *Resource {
-	r := &Resource{
-		model: m,
-		ID: func(req *http.Request) (string, error) {
-			return PathID(req, m.PathID())
-		},
+	r := &Resource{model: m}
+	r.ID = func(req *http.Request) (string, error) {
+		return PathID(req, r.param())
 	}
 
 	if len(optID) > 0 {
@@ -44,15 +60,34 @@ func New(m model.Model, optID ...func(req *http.Request) (string, error)) *Resou
 	return r
 }
 
+// Mount satisfies the Mounter interface. Mux.Resource calls Mount with the
+// path and id parameter the resource is routed under.
+func (r *Resource) Mount(path, id string) {
+	r.mount = newMount(path, id)
+}
+
+// param returns the URL path parameter holding an item id.
+func (r *Resource) param() string {
+	if len(r.mount.param) > 0 {
+		return r.mount.param
+	}
+	return r.model.PathID()
+}
+
 // Index is a GET request for returning a list of items.
 func (r *Resource) Index(resp http.ResponseWriter, req *http.Request) {
-	items, err := r.model.FindAll()
+	items, err := findAll(req.Context(), r.model)
 	if err != nil {
 		resp.WriteErrs(req, err)
 		return
 	}
 
-	resp.WriteFormatList(req, items)
+	if err := triggerAll(req, afterFind, items); err != nil {
+		resp.WriteErrs(req, err)
+		return
+	}
+
+	writeList(resp, req, r, r.Hypermedia, allowed(req, r.policy(req), visible(req, items)))
 }
 
 // Show is a GET request for displaying a single item.
@@ -64,18 +99,60 @@ func (r *Resource) Show(resp http.ResponseWriter, req *http.Request) {
 	}
 
 	item := r.model.New()
-	if err := item.FindByID(id); err != nil {
+	if err := findByID(req.Context(), item, id); err != nil {
+		resp.WriteErrs(req, err)
+		return
+	}
+
+	if err := trigger(req, afterFind, item); err != nil {
+		resp.WriteErrs(req, err)
+		return
+	}
+
+	if err := authorize(req, r.policy(req), ShowAction, item); err != nil {
 		resp.WriteErrs(req, err)
 		return
 	}
 
-	resp.WriteFormat(req, item)
+	// trashed items are only shown if asked for, as Index lists them
+	if !req.QueryBool("trashed") {
+		if err := untrashed(item); err != nil {
+			resp.WriteErrs(req, err)
+			return
+		}
+	}
+
+	writeItem(resp, req, r, r.Hypermedia, item)
 }
 
 // Store is a POST request for creating a new item.
 func (r *Resource) Store(resp http.ResponseWriter, req *http.Request) {
 	item := r.model.New()
-	if err := req.Unmarshal(item); err != nil {
+	id, err := decode(req, r, r.Hypermedia, item)
+	if err != nil {
+		resp.WriteErrs(req, err)
+		return
+	}
+
+	// client generated ids are allowed by JSON:API documents
+	if len(id) > 0 {
+		if err := item.SetID(id); err != nil {
+			resp.WriteErrs(req, err)
+			return
+		}
+	}
+
+	if err := r.reserved(item); err != nil {
+		resp.WriteErrs(req, err)
+		return
+	}
+
+	if err := authorize(req, r.policy(req), StoreAction, item); err != nil {
+		resp.WriteErrs(req, err)
+		return
+	}
+
+	if err := trigger(req, beforeCreate, item); err != nil {
 		resp.WriteErrs(req, err)
 		return
 	}
@@ -85,12 +162,18 @@ func (r *Resource) Store(resp http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	if err := item.Save(); err != nil {
-		log.Fatal(err)
+	if err := persist(req.Context(), item); err != nil {
+		resp.WriteErrs(req, err)
+		return
+	}
+
+	if err := trigger(req, afterCreate, item); err != nil {
+		resp.WriteErrs(req, err)
+		return
 	}
 
 	resp.Status(http.StatusCreated)
-	resp.WriteFormat(req, item)
+	writeItem(resp, req, r, r.Hypermedia, item)
 }
 
 // Update is a PUT request for replacing a single item.
@@ -101,8 +184,20 @@ func (r *Resource) Update(resp http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	found, err := r.load(req, UpdateAction, id)
+	if err != nil {
+		resp.WriteErrs(req, err)
+		return
+	}
+
+	if err := untrashed(found); err != nil {
+		resp.WriteErrs(req, err)
+		return
+	}
+
+	// the item is replaced by the one in the body
 	item := r.model.New()
-	if err := req.Unmarshal(item); err != nil {
+	if _, err := decode(req, r, r.Hypermedia, item); err != nil {
 		resp.WriteErrs(req, err)
 		return
 	}
@@ -112,17 +207,27 @@ func (r *Resource) Update(resp http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	if err := trigger(req, beforeUpdate, item); err != nil {
+		resp.WriteErrs(req, err)
+		return
+	}
+
 	if err := item.Validate(); err != nil {
 		resp.WriteErrs(req, err)
 		return
 	}
 
-	if err := item.Save(); err != nil {
+	if err := persist(req.Context(), item); err != nil {
+		resp.WriteErrs(req, err)
+		return
+	}
+
+	if err := trigger(req, afterUpdate, item); err != nil {
 		resp.WriteErrs(req, err)
 		return
 	}
 
-	resp.WriteFormat(req, item)
+	writeItem(resp, req, r, r.Hypermedia, item)
 }
 
 // Apply is a PATCH request for updating a single item.
@@ -134,12 +239,32 @@ func (r *Resource) Apply(resp http.ResponseWriter, req *http.Request) {
 	}
 
 	item := r.model.New()
-	if err := item.FindByID(id); err != nil {
+	if err := findByID(req.Context(), item, id); err != nil {
+		resp.WriteErrs(req, err)
+		return
+	}
+
+	if err := trigger(req, afterFind, item); err != nil {
+		resp.WriteErrs(req, err)
+		return
+	}
+
+	if err := authorize(req, r.policy(req), ApplyAction, item); err != nil {
+		resp.WriteErrs(req, err)
+		return
+	}
+
+	if err := untrashed(item); err != nil {
+		resp.WriteErrs(req, err)
+		return
+	}
+
+	if _, err := decode(req, r, r.Hypermedia, item); err != nil {
 		resp.WriteErrs(req, err)
 		return
 	}
 
-	if err := req.Unmarshal(item); err != nil {
+	if err := trigger(req, beforeUpdate, item); err != nil {
 		resp.WriteErrs(req, err)
 		return
 	}
@@ -149,12 +274,17 @@ func (r *Resource) Apply(resp http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	if err := item.Save(); err != nil {
+	if err := persist(req.Context(), item); err != nil {
+		resp.WriteErrs(req, err)
+		return
+	}
+
+	if err := trigger(req, afterUpdate, item); err != nil {
 		resp.WriteErrs(req, err)
 		return
 	}
 
-	resp.WriteFormat(req, item)
+	writeItem(resp, req, r, r.Hypermedia, item)
 }
 
 // Destroy is a DELETE request for deleting a single item.
@@ -165,13 +295,30 @@ func (r *Resource) Destroy(resp http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	item := r.model.New()
-	if err := item.SetID(id); err != nil {
+	// items are loaded, so trashing them saves what is stored
+	item, err := r.load(req, DestroyAction, id)
+	if err != nil {
+		resp.WriteErrs(req, err)
+		return
+	}
+
+	if err := untrashed(item); err != nil {
+		resp.WriteErrs(req, err)
+		return
+	}
+
+	if err := trigger(req, beforeDelete, item); err != nil {
+		resp.WriteErrs(req, err)
+		return
+	}
+
+	// items are trashed if their model supports it
+	if err := remove(req.Context(), item); err != nil {
 		resp.WriteErrs(req, err)
 		return
 	}
 
-	if err := item.Delete(); err != nil {
+	if err := trigger(req, afterDelete, item); err != nil {
 		resp.WriteErrs(req, err)
 		return
 	}
@@ -0,0 +1,264 @@
+package resource
+
+import (
+	"context"
+	"errors"
+
+	http "github.com/blueprint/blueprint/transport"
+
+	"github.com/blueprint/blueprint"
+)
+
+// ErrTrashed is returned for actions on trashed items other than restoring
+// and purging them, and answered with 404 Not Found. Show still finds trashed
+// items with ?trashed=true, as Index lists them.
+var ErrTrashed = errors.New("trashed item")
+
+func init() {
+	http.ApplyErrorCode(ErrTrashed, http.StatusNotFound)
+}
+
+// Trasher is implemented by resource controllers that can restore and purge
+// trashed items. Mux.Resource routes them for models that satisfy
+// blueprint.SoftDeleter, as POST /things/:id/restore and DELETE
+// /things/:id/purge.
+type Trasher interface {
+	Trashable() bool
+	Restore(resp http.ResponseWriter, req *http.Request)
+	Purge(resp http.ResponseWriter, req *http.Request)
+}
+
+// remove trashes an item if its model supports it, otherwise it is deleted.
+func remove(ctx context.Context, item blueprint.Model) error {
+	if t, ok := item.(blueprint.SoftDeleter); ok {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		return t.Trash()
+	}
+	return erase(ctx, item)
+}
+
+// visible returns the items of a listing that are trashed if ?trashed=true,
+// or the items that are not otherwise.
+func visible(req *http.Request, items []blueprint.Model) []blueprint.Model {
+	trashed := req.QueryBool("trashed")
+
+	list := make([]blueprint.Model, 0, len(items))
+	for _, item := range items {
+		if t, ok := item.(blueprint.SoftDeleter); ok && t.Trashed() != trashed {
+			continue
+		}
+		list = append(list, item)
+	}
+	return list
+}
+
+// untrashed returns ErrTrashed if an item is trashed.
+func untrashed(item interface{}) error {
+	if t, ok := item.(blueprint.SoftDeleter); ok && t.Trashed() {
+		return ErrTrashed
+	}
+	return nil
+}
+
+// privileged checks that a request was authorized with a privileged API key.
+func privileged(req *http.Request) error {
+	if key, ok := http.APIKeyFrom(req.Context()); ok && key.Privileged {
+		return nil
+	}
+	return http.ErrInvalidPermission
+}
+
+// Trashable satisfies the Trasher interface.
+func (r *Resource) Trashable() bool {
+	_, ok := r.model.(blueprint.SoftDeleter)
+	return ok
+}
+
+// Restore is a POST request for restoring a trashed item.
+func (r *Resource) Restore(resp http.ResponseWriter, req *http.Request) {
+	id, err := r.ID(req)
+	if err != nil {
+		resp.WriteErrs(req, err)
+		return
+	}
+
+	item, err := r.load(req, RestoreAction, id)
+	if err != nil {
+		resp.WriteErrs(req, err)
+		return
+	}
+
+	if err := restore(item); err != nil {
+		resp.WriteErrs(req, err)
+		return
+	}
+
+	if err := findByID(req.Context(), item, id); err != nil {
+		resp.WriteErrs(req, err)
+		return
+	}
+
+	if err := trigger(req, afterFind, item); err != nil {
+		resp.WriteErrs(req, err)
+		return
+	}
+
+	writeItem(resp, req, r, r.Hypermedia, item)
+}
+
+// Purge is a DELETE request for deleting an item for good. Only requests
+// authorized with a privileged API key may purge items.
+func (r *Resource) Purge(resp http.ResponseWriter, req *http.Request) {
+	if err := privileged(req); err != nil {
+		resp.WriteErrs(req, err)
+		return
+	}
+
+	id, err := r.ID(req)
+	if err != nil {
+		resp.WriteErrs(req, err)
+		return
+	}
+
+	item, err := r.load(req, PurgeAction, id)
+	if err != nil {
+		resp.WriteErrs(req, err)
+		return
+	}
+
+	if err := trigger(req, beforeDelete, item); err != nil {
+		resp.WriteErrs(req, err)
+		return
+	}
+
+	if err := erase(req.Context(), item); err != nil {
+		resp.WriteErrs(req, err)
+		return
+	}
+
+	if err := trigger(req, afterDelete, item); err != nil {
+		resp.WriteErrs(req, err)
+		return
+	}
+
+	resp.Status(http.StatusNoContent)
+	resp.WriteFormat(req, "")
+}
+
+// Trashable satisfies the Trasher interface.
+func (e *ExtendedResource) Trashable() bool {
+	_, ok := e.model.(blueprint.SoftDeleter)
+	return ok
+}
+
+// Restore is a POST request for restoring a trashed item.
+func (e *ExtendedResource) Restore(resp http.ResponseWriter, req *http.Request) {
+	item, ok := e.owned(resp, req)
+	if !ok {
+		return
+	}
+
+	if err := authorize(req, e.policy(req), RestoreAction, item); err != nil {
+		resp.WriteErrs(req, err)
+		return
+	}
+
+	if err := restore(item); err != nil {
+		resp.WriteErrs(req, err)
+		return
+	}
+
+	// reload the item, as restoring may change more than the trash fields
+	item, ok = e.owned(resp, req)
+	if !ok {
+		return
+	}
+
+	writeItem(resp, req, e, e.Hypermedia, item)
+}
+
+// Purge is a DELETE request for deleting an item for good. Only requests
+// authorized with a privileged API key may purge items.
+func (e *ExtendedResource) Purge(resp http.ResponseWriter, req *http.Request) {
+	if err := privileged(req); err != nil {
+		resp.WriteErrs(req, err)
+		return
+	}
+
+	item, ok := e.owned(resp, req)
+	if !ok {
+		return
+	}
+
+	if err := authorize(req, e.policy(req), PurgeAction, item); err != nil {
+		resp.WriteErrs(req, err)
+		return
+	}
+
+	if err := trigger(req, beforeDelete, item); err != nil {
+		resp.WriteErrs(req, err)
+		return
+	}
+
+	if err := erase(req.Context(), item); err != nil {
+		resp.WriteErrs(req, err)
+		return
+	}
+
+	if err := trigger(req, afterDelete, item); err != nil {
+		resp.WriteErrs(req, err)
+		return
+	}
+
+	resp.Status(http.StatusNoContent)
+	resp.WriteFormat(req, "")
+}
+
+// owned finds the item of a request and makes sure it belongs to the base
+// item in the request. Errors are written to the response.
+func (e *ExtendedResource) owned(resp http.ResponseWriter, req *http.Request) (blueprint.Model, bool) {
+	base, err := e.base(req)
+	if err != nil {
+		resp.WriteErrs(req, err)
+		return nil, false
+	}
+
+	id, err := e.ID(req)
+	if err != nil {
+		resp.WriteErrs(req, err)
+		return nil, false
+	}
+
+	item := e.model.New()
+	if err := findByID(req.Context(), item, id); err != nil {
+		resp.WriteErrs(req, err)
+		return nil, false
+	}
+
+	if err := trigger(req, afterFind, item); err != nil {
+		resp.WriteErrs(req, err)
+		return nil, false
+	}
+
+	if err := e.owns(req, item); err != nil {
+		resp.WriteErrs(req, err)
+		return nil, false
+	}
+
+	if err := item.BelongsTo(base); err != nil {
+		resp.WriteErrs(req, err)
+		return nil, false
+	}
+	return item, true
+}
+
+// restore restores a trashed item.
+func restore(item blueprint.Model) error {
+	t, ok := item.(blueprint.SoftDeleter)
+	if !ok {
+		return http.ErrInvalidInput
+	}
+	return t.Restore()
+}
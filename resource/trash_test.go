@@ -0,0 +1,292 @@
+package resource
+
+import (
+	"context"
+	"encoding/json"
+	stdhttp "net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/blueprint/blueprint"
+	"github.com/blueprint/blueprint/mock"
+	http "github.com/blueprint/blueprint/transport"
+	"github.com/julienschmidt/httprouter"
+)
+
+// bin is a soft deleting model backed by a map of ids to trashed flags.
+type bin struct {
+	*mock.Model `json:"-" xml:"-" yaml:"-"`
+	ID          string `json:"id" xml:"id" yaml:"id"`
+	Deleted     bool   `json:"deleted" xml:"deleted" yaml:"deleted"`
+
+	store map[string]bool
+}
+
+func newBin() *bin {
+	return &bin{Model: mock.NewModel(), store: map[string]bool{"a": false, "b": false, "c": true}}
+}
+
+func (b *bin) New() blueprint.Model {
+	return &bin{Model: b.Model, store: b.store}
+}
+
+func (b *bin) SetID(id string) error {
+	b.ID = id
+	return nil
+}
+
+func (b *bin) FindAll() ([]blueprint.Model, error) {
+	var items []blueprint.Model
+	for _, id := range []string{"a", "b", "c"} {
+		if deleted, ok := b.store[id]; ok {
+			items = append(items, &bin{Model: b.Model, ID: id, Deleted: deleted, store: b.store})
+		}
+	}
+	return items, nil
+}
+
+func (b *bin) FindByID(id string) error {
+	deleted, ok := b.store[id]
+	if !ok {
+		return http.ErrInvalidID
+	}
+	b.ID, b.Deleted = id, deleted
+	return nil
+}
+
+func (b *bin) FindAllByOwner(owner blueprint.Model) ([]blueprint.Model, error) {
+	return b.FindAll()
+}
+
+func (b *bin) Delete() error {
+	delete(b.store, b.ID)
+	return nil
+}
+
+func (b *bin) Trash() error {
+	b.store[b.ID] = true
+	return nil
+}
+
+func (b *bin) Restore() error {
+	b.store[b.ID] = false
+	return nil
+}
+
+func (b *bin) Trashed() bool {
+	return b.Deleted
+}
+
+// trashRequest executes an action, authorized with an API key if one is given.
+func trashRequest(method, uri string, key *http.APIKey, ps httprouter.Params, action http.HandlerFunc) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, uri, nil)
+	if key != nil {
+		r = r.WithContext(http.WithAPIKey(context.Background(), *key))
+	}
+	rec := httptest.NewRecorder()
+	action.ServeHTTP(http.NewResponse(rec, "json"), http.NewRequest(r, ps))
+	return rec
+}
+
+// listed returns the ids of the items in an Index response.
+func listed(t *testing.T, rec *httptest.ResponseRecorder) string {
+	var items []bin
+	if err := json.Unmarshal(rec.Body.Bytes(), &items); err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, item := range items {
+		ids = append(ids, item.ID)
+	}
+	return strings.Join(ids, ",")
+}
+
+func TestTrashIndex(t *testing.T) {
+	r := New(newBin())
+
+	if ids := listed(t, trashRequest("GET", "/bins", nil, nil, r.Index)); ids != "a,b" {
+		t.Errorf("expected items 'a,b' got '%s'", ids)
+	}
+	if ids := listed(t, trashRequest("GET", "/bins?trashed=true", nil, nil, r.Index)); ids != "c" {
+		t.Errorf("expected trashed items 'c' got '%s'", ids)
+	}
+}
+
+func TestTrashDestroyRestore(t *testing.T) {
+	m := newBin()
+	r := New(m)
+	r.Mount("/bins", "bin")
+	ps := httprouter.Params{{Key: "bin", Value: "a"}}
+
+	rec := trashRequest("DELETE", "/bins/a", nil, ps, r.Destroy)
+	if rec.Code != stdhttp.StatusNoContent {
+		t.Errorf("expected status %d got %d", stdhttp.StatusNoContent, rec.Code)
+	}
+	if deleted, ok := m.store["a"]; !ok || !deleted {
+		t.Error("expected item 'a' to be trashed")
+	}
+
+	rec = trashRequest("POST", "/bins/a/restore", nil, ps, r.Restore)
+	if rec.Code != stdhttp.StatusOK {
+		t.Errorf("expected status %d got %d", stdhttp.StatusOK, rec.Code)
+	}
+	if m.store["a"] {
+		t.Error("expected item 'a' to be restored")
+	}
+}
+
+func TestTrashLoaded(t *testing.T) {
+	m := newBin()
+	r := New(m)
+	r.Mount("/bins", "bin")
+
+	// unknown items are not trashed into existence
+	rec := trashRequest("DELETE", "/bins/x", nil, httprouter.Params{{Key: "bin", Value: "x"}}, r.Destroy)
+	if rec.Code != stdhttp.StatusBadRequest {
+		t.Errorf("expected status %d got %d", stdhttp.StatusBadRequest, rec.Code)
+	}
+	if _, ok := m.store["x"]; ok {
+		t.Error("expected no item 'x' to be stored")
+	}
+
+	// trashed items are not found, unless shown with ?trashed=true
+	ps := httprouter.Params{{Key: "bin", Value: "c"}}
+	tests := []struct {
+		method string
+		uri    string
+		action http.HandlerFunc
+		status int
+	}{{"GET", "/bins/c", r.Show, stdhttp.StatusNotFound},
+		{"GET", "/bins/c?trashed=true", r.Show, stdhttp.StatusOK},
+		{"PUT", "/bins/c", r.Update, stdhttp.StatusNotFound},
+		{"PATCH", "/bins/c", r.Apply, stdhttp.StatusNotFound},
+		{"DELETE", "/bins/c", r.Destroy, stdhttp.StatusNotFound}}
+
+	for _, test := range tests {
+		if rec := trashRequest(test.method, test.uri, nil, ps, test.action); rec.Code != test.status {
+			t.Errorf("%s %s: expected status %d got %d", test.method, test.uri, test.status, rec.Code)
+		}
+	}
+}
+
+func TestTrashPurge(t *testing.T) {
+	m := newBin()
+	r := New(m)
+	r.Mount("/bins", "bin")
+	ps := httprouter.Params{{Key: "bin", Value: "c"}}
+
+	tests := []struct {
+		key    *http.APIKey
+		status int
+	}{{nil, stdhttp.StatusForbidden},
+		{&http.APIKey{Key: "user"}, stdhttp.StatusForbidden},
+		{&http.APIKey{Key: "admin", Privileged: true}, stdhttp.StatusNoContent}}
+
+	for _, test := range tests {
+		rec := trashRequest("DELETE", "/bins/c/purge", test.key, ps, r.Purge)
+		if rec.Code != test.status {
+			t.Errorf("%+v: expected status %d got %d", test.key, test.status, rec.Code)
+		}
+	}
+	if _, ok := m.store["c"]; ok {
+		t.Error("expected item 'c' to be purged")
+	}
+}
+
+func TestTrashExtended(t *testing.T) {
+	m := newBin()
+	users := New(mock.NewModel())
+	users.Mount("/users", "user")
+	bins := users.Extend(m)
+	bins.Mount("/users/:user/bins", "bin")
+	ps := httprouter.Params{{Key: "user", Value: "1"}, {Key: "bin", Value: "b"}}
+
+	trashRequest("DELETE", "/users/1/bins/b", nil, ps, bins.Destroy)
+	if ids := listed(t, trashRequest("GET", "/users/1/bins", nil, ps[:1], bins.Index)); ids != "a" {
+		t.Errorf("expected items 'a' got '%s'", ids)
+	}
+
+	rec := trashRequest("POST", "/users/1/bins/b/restore", nil, ps, bins.Restore)
+	if !strings.Contains(rec.Body.String(), `"deleted":false`) {
+		t.Errorf("expected the restored item to be reloaded got %s", rec.Body)
+	}
+	if ids := listed(t, trashRequest("GET", "/users/1/bins?trashed=true", nil, ps[:1], bins.Index)); ids != "c" {
+		t.Errorf("expected trashed items 'c' got '%s'", ids)
+	}
+
+	rec = trashRequest("DELETE", "/users/1/bins/b/purge", nil, ps, bins.Purge)
+	if rec.Code != stdhttp.StatusForbidden {
+		t.Errorf("expected status %d got %d", stdhttp.StatusForbidden, rec.Code)
+	}
+	if !bins.Trashable() || New(mock.NewModel()).Trashable() {
+		t.Error("expected only soft deleting models to be trashable")
+	}
+}
+
+func TestTrashMember(t *testing.T) {
+	r := New(newBin())
+	r.Mount("/bins", "bin")
+	users := New(mock.NewModel())
+	users.Mount("/users", "user")
+	bins := users.Extend(newBin())
+	bins.Mount("/users/:user/bins", "bin")
+
+	var called []string
+	empty := func(resp http.ResponseWriter, req *http.Request, item blueprint.Model) {
+		called = append(called, item.(*bin).ID)
+		resp.WriteFormat(req, item)
+	}
+	r.Member("POST", "empty", empty)
+	bins.Member("POST", "empty", empty)
+
+	tests := []struct {
+		id     string
+		status int
+		action http.HandlerFunc
+	}{{"a", stdhttp.StatusOK, r.Routes()[0].Handler},
+		{"c", stdhttp.StatusNotFound, r.Routes()[0].Handler},
+		{"a", stdhttp.StatusOK, bins.Routes()[0].Handler},
+		{"c", stdhttp.StatusNotFound, bins.Routes()[0].Handler}}
+
+	for _, test := range tests {
+		ps := httprouter.Params{{Key: "user", Value: "1"}, {Key: "bin", Value: test.id}}
+		rec := trashRequest("POST", "/bins/"+test.id+"/empty", nil, ps, test.action)
+		if rec.Code != test.status {
+			t.Errorf("%s: expected status %d got %d", test.id, test.status, rec.Code)
+		}
+	}
+	if strings.Join(called, ",") != "a,a" {
+		t.Errorf("expected the action to run on untrashed items got %v", called)
+	}
+}
+
+func TestReservedID(t *testing.T) {
+	r := New(newBin())
+	r.Mount("/bins", "bin")
+	r.Collection("GET", "export", func(resp http.ResponseWriter, req *http.Request) {})
+	bulk := NewBulk(r)
+
+	for _, body := range []string{`{"id":"export"}`, `{"id":"d"}`} {
+		req := httptest.NewRequest("POST", "/bins", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		r.Store(http.NewResponse(rec, "json"), http.NewRequest(req, nil))
+
+		status := stdhttp.StatusCreated
+		if strings.Contains(body, "export") {
+			status = stdhttp.StatusBadRequest
+		}
+		if rec.Code != status {
+			t.Errorf("%s: expected status %d got %d", body, status, rec.Code)
+		}
+	}
+
+	req := httptest.NewRequest("POST", "/bins", strings.NewReader(`[{"id":"export"},{"id":"d"}]`))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	bulk.BulkStore(http.NewResponse(rec, "json"), http.NewRequest(req, nil))
+	if !strings.Contains(rec.Body.String(), `"status":400`) || !strings.Contains(rec.Body.String(), `"status":201`) {
+		t.Errorf("expected only the item with a reserved id to fail got %s", rec.Body)
+	}
+}
@@ -0,0 +1,420 @@
+package resource
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/blueprint/blueprint"
+	http "github.com/blueprint/blueprint/transport"
+)
+
+// Store keeps the items of a Typed resource controller. Stores return the
+// errors of the transport package, such as http.ErrInvalidID for items that
+// are not found, so they are written with the right status.
+type Store[T any] interface {
+	Find(ctx context.Context, id string) (T, error)
+	List(ctx context.Context) ([]T, error)
+
+	// Save creates an item if id is empty and replaces the item with the id
+	// otherwise. It returns the item as stored, such as with a generated id.
+	Save(ctx context.Context, id string, item T) (T, error)
+	Delete(ctx context.Context, id string) error
+}
+
+// validator is implemented by items that validate themselves before they are
+// saved.
+type validator interface {
+	Validate() error
+}
+
+// Typed describes a resource controller for items of a single type T, kept in
+// a Store. Unlike Resource, it needs no New method or type assertions. Items
+// may implement Validate() error and the hooks of blueprint.Model, which are
+// called the same way.
+//
+// Typed resource controllers satisfy Resourcer, so Mux.Resource routes them
+// like any other.
+type Typed[T any] struct {
+	store Store[T]
+	ID    func(req *http.Request) (string, error)
+
+	// Policy decides which actions principals may perform on items, as it
+	// does for Resource. Policies judge items that satisfy blueprint.Model,
+	// so they deny every action on items of other types. Without a Policy,
+	// the one router.Authorize registers for the route is consulted.
+	Policy Policy
+
+	// Actions chooses the actions Mux.Resource routes and holds custom ones.
+	Actions
+
+	mount mount
+}
+
+// NewTyped takes a store and returns a new Typed resource controller.
+//
+// It also takes an optional func to get an ID from request.
+func NewTyped[T any](s Store[T], optID ...func(req *http.Request) (string, error)) *Typed[T] {
+	t := &Typed[T]{store: s}
+	t.ID = func(req *http.Request) (string, error) {
+		return PathID(req, t.param())
+	}
+
+	if len(optID) > 0 {
+		t.ID = optID[0]
+	}
+
+	return t
+}
+
+// Mount satisfies the Mounter interface.
+func (t *Typed[T]) Mount(path, id string) {
+	t.mount = newMount(path, id)
+}
+
+// param returns the URL path parameter holding an item id.
+func (t *Typed[T]) param() string {
+	if len(t.mount.param) > 0 {
+		return t.mount.param
+	}
+	return "id"
+}
+
+// Index is a GET request for returning a list of items. Items that satisfy
+// blueprint.SoftDeleter are listed as Resource lists them, only if trashed
+// with ?trashed=true and only if not otherwise.
+func (t *Typed[T]) Index(resp http.ResponseWriter, req *http.Request) {
+	items, err := t.store.List(req.Context())
+	if err != nil {
+		resp.WriteErrs(req, err)
+		return
+	}
+
+	trashed := req.QueryBool("trashed")
+	fields := requestedFields(req)
+	list := make([]interface{}, 0, len(items))
+	for _, item := range items {
+		if err := trigger(req, afterFind, item); err != nil {
+			resp.WriteErrs(req, err)
+			return
+		}
+		if d, ok := interface{}(item).(blueprint.SoftDeleter); ok && d.Trashed() != trashed {
+			continue
+		}
+		if t.authorize(req, IndexAction, item) != nil {
+			continue
+		}
+		value, err := sparse(item, fields, resp.Format())
+		if err != nil {
+			resp.WriteErrs(req, err)
+			return
+		}
+		list = append(list, value)
+	}
+
+	resp.WriteFormatList(req, list)
+}
+
+// Show is a GET request for displaying a single item. Trashed items are only
+// shown with ?trashed=true, as Index lists them.
+func (t *Typed[T]) Show(resp http.ResponseWriter, req *http.Request) {
+	item, ok := t.find(resp, req, ShowAction, req.QueryBool("trashed"))
+	if !ok {
+		return
+	}
+
+	t.write(resp, req, item)
+}
+
+// Store is a POST request for creating a new item.
+func (t *Typed[T]) Store(resp http.ResponseWriter, req *http.Request) {
+	item := t.new()
+	if err := req.Unmarshal(&item); err != nil {
+		resp.WriteErrs(req, err)
+		return
+	}
+
+	if m, ok := interface{}(item).(blueprint.Model); ok {
+		if err := t.reserved(m); err != nil {
+			resp.WriteErrs(req, err)
+			return
+		}
+	}
+
+	if err := t.authorize(req, StoreAction, item); err != nil {
+		resp.WriteErrs(req, err)
+		return
+	}
+
+	item, ok := t.save(resp, req, "", item, beforeCreate, afterCreate)
+	if !ok {
+		return
+	}
+
+	resp.Status(http.StatusCreated)
+	t.write(resp, req, item)
+}
+
+// Update is a PUT request for replacing a single item.
+func (t *Typed[T]) Update(resp http.ResponseWriter, req *http.Request) {
+	id, err := t.ID(req)
+	if err != nil {
+		resp.WriteErrs(req, err)
+		return
+	}
+
+	// the item is replaced by the one in the body
+	if _, ok := t.find(resp, req, UpdateAction, false); !ok {
+		return
+	}
+
+	item := t.new()
+	if err := req.Unmarshal(&item); err != nil {
+		resp.WriteErrs(req, err)
+		return
+	}
+
+	if item, ok := t.save(resp, req, id, item, beforeUpdate, afterUpdate); ok {
+		t.write(resp, req, item)
+	}
+}
+
+// Apply is a PATCH request for updating a single item.
+func (t *Typed[T]) Apply(resp http.ResponseWriter, req *http.Request) {
+	id, err := t.ID(req)
+	if err != nil {
+		resp.WriteErrs(req, err)
+		return
+	}
+
+	item, ok := t.find(resp, req, ApplyAction, false)
+	if !ok {
+		return
+	}
+
+	// set the values specified in the PATCH body
+	if err := req.Unmarshal(&item); err != nil {
+		resp.WriteErrs(req, err)
+		return
+	}
+
+	if item, ok := t.save(resp, req, id, item, beforeUpdate, afterUpdate); ok {
+		t.write(resp, req, item)
+	}
+}
+
+// Destroy is a DELETE request for deleting a single item.
+func (t *Typed[T]) Destroy(resp http.ResponseWriter, req *http.Request) {
+	id, err := t.ID(req)
+	if err != nil {
+		resp.WriteErrs(req, err)
+		return
+	}
+
+	item, ok := t.find(resp, req, DestroyAction, false)
+	if !ok {
+		return
+	}
+
+	if err := trigger(req, beforeDelete, item); err != nil {
+		resp.WriteErrs(req, err)
+		return
+	}
+
+	if err := t.store.Delete(req.Context(), id); err != nil {
+		resp.WriteErrs(req, err)
+		return
+	}
+
+	if err := trigger(req, afterDelete, item); err != nil {
+		resp.WriteErrs(req, err)
+		return
+	}
+
+	resp.Status(http.StatusNoContent)
+	resp.WriteFormat(req, "")
+}
+
+// new returns an empty item to decode a request body into. Stores that hold
+// their own empty items, such as ModelStore, are asked for one.
+func (t *Typed[T]) new() T {
+	if s, ok := t.store.(interface{ New() T }); ok {
+		return s.New()
+	}
+	var item T
+	return item
+}
+
+// find loads the item of a request, and checks the policy allows an action on
+// it. Trashed items are rejected unless trashed is set. Errors are written to
+// the response.
+func (t *Typed[T]) find(resp http.ResponseWriter, req *http.Request, action Action, trashed bool) (T, bool) {
+	var zero T
+
+	id, err := t.ID(req)
+	if err != nil {
+		resp.WriteErrs(req, err)
+		return zero, false
+	}
+
+	item, err := t.store.Find(req.Context(), id)
+	if err != nil {
+		resp.WriteErrs(req, err)
+		return zero, false
+	}
+
+	if err := trigger(req, afterFind, item); err != nil {
+		resp.WriteErrs(req, err)
+		return zero, false
+	}
+
+	if err := t.authorize(req, action, item); err != nil {
+		resp.WriteErrs(req, err)
+		return zero, false
+	}
+
+	if !trashed {
+		if err := untrashed(item); err != nil {
+			resp.WriteErrs(req, err)
+			return zero, false
+		}
+	}
+	return item, true
+}
+
+// policy returns the policy of the resource controller, or the one registered
+// for the route of a request.
+func (t *Typed[T]) policy(req *http.Request) Policy {
+	if t.Policy != nil {
+		return t.Policy
+	}
+	return policyFrom(req.Context())
+}
+
+// authorize checks the policy allows the principal of a request an action on
+// an item. Items that are not a blueprint.Model are denied by any policy.
+func (t *Typed[T]) authorize(req *http.Request, action Action, item T) error {
+	p := t.policy(req)
+	if p == nil {
+		return nil
+	}
+	m, ok := interface{}(item).(blueprint.Model)
+	if !ok {
+		return http.ErrInvalidPermission
+	}
+	return authorize(req, p, action, m)
+}
+
+// save validates and saves an item, calling the hooks for the before and
+// after events. Errors are written to the response.
+func (t *Typed[T]) save(resp http.ResponseWriter, req *http.Request, id string, item T, before, after event) (T, bool) {
+	var zero T
+
+	if err := trigger(req, before, item); err != nil {
+		resp.WriteErrs(req, err)
+		return zero, false
+	}
+
+	if v, ok := interface{}(item).(validator); ok {
+		if err := v.Validate(); err != nil {
+			resp.WriteErrs(req, err)
+			return zero, false
+		}
+	}
+
+	item, err := t.store.Save(req.Context(), id, item)
+	if err != nil {
+		resp.WriteErrs(req, err)
+		return zero, false
+	}
+
+	if err := trigger(req, after, item); err != nil {
+		resp.WriteErrs(req, err)
+		return zero, false
+	}
+	return item, true
+}
+
+// write writes an item with the fields requested through ?fields=.
+func (t *Typed[T]) write(resp http.ResponseWriter, req *http.Request, item T) {
+	v, err := sparse(item, requestedFields(req), resp.Format())
+	if err != nil {
+		resp.WriteErrs(req, err)
+		return
+	}
+	resp.WriteFormat(req, v)
+}
+
+// ModelStore adapts a blueprint.Model to the Store interface, so existing
+// models can be used by Typed resource controllers. T is the type the model's
+// New method returns, such as *User.
+type ModelStore[T blueprint.Model] struct {
+	model T
+}
+
+// Adapt takes a model and returns it as a Store.
+func Adapt[T blueprint.Model](m T) *ModelStore[T] {
+	return &ModelStore[T]{model: m}
+}
+
+// New returns a new item of the model, or the zero value of T if the model
+// returns another type.
+func (s *ModelStore[T]) New() T {
+	item, _ := s.model.New().(T)
+	return item
+}
+
+// new returns a new item of the model.
+func (s *ModelStore[T]) new() (T, error) {
+	item, ok := s.model.New().(T)
+	if !ok {
+		return item, fmt.Errorf("resource: %T.New() does not return a %T", s.model, item)
+	}
+	return item, nil
+}
+
+// Find satisfies the Store interface.
+func (s *ModelStore[T]) Find(ctx context.Context, id string) (T, error) {
+	item, err := s.new()
+	if err != nil {
+		return item, err
+	}
+	return item, findByID(ctx, item, id)
+}
+
+// List satisfies the Store interface.
+func (s *ModelStore[T]) List(ctx context.Context) ([]T, error) {
+	models, err := findAll(ctx, s.model)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]T, len(models))
+	for i, m := range models {
+		item, ok := m.(T)
+		if !ok {
+			return nil, fmt.Errorf("resource: %T.FindAll() returned a %T", s.model, m)
+		}
+		items[i] = item
+	}
+	return items, nil
+}
+
+// Save satisfies the Store interface.
+func (s *ModelStore[T]) Save(ctx context.Context, id string, item T) (T, error) {
+	if len(id) > 0 {
+		if err := item.SetID(id); err != nil {
+			return item, err
+		}
+	}
+	return item, persist(ctx, item)
+}
+
+// Delete satisfies the Store interface. Items are trashed if their model
+// supports it, after they are loaded so trashing saves what is stored.
+func (s *ModelStore[T]) Delete(ctx context.Context, id string) error {
+	item, err := s.Find(ctx, id)
+	if err != nil {
+		return err
+	}
+	return remove(ctx, item)
+}
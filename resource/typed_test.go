@@ -0,0 +1,248 @@
+package resource
+
+import (
+	"context"
+	"encoding/json"
+	stdhttp "net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/blueprint/blueprint"
+	http "github.com/blueprint/blueprint/transport"
+	"github.com/julienschmidt/httprouter"
+)
+
+// gadget is a plain struct kept in a gadgets store.
+type gadget struct {
+	ID   string `json:"id" xml:"id" yaml:"id"`
+	Name string `json:"name" xml:"name" yaml:"name"`
+}
+
+func (g gadget) Validate() error {
+	if len(g.Name) == 0 {
+		return http.ErrInvalidInput
+	}
+	return nil
+}
+
+// gadgets is an in memory store of gadgets.
+type gadgets map[string]gadget
+
+func (s gadgets) Find(ctx context.Context, id string) (gadget, error) {
+	g, ok := s[id]
+	if !ok {
+		return g, http.ErrInvalidID
+	}
+	return g, nil
+}
+
+func (s gadgets) List(ctx context.Context) ([]gadget, error) {
+	var list []gadget
+	for i := 1; i <= len(s); i++ {
+		list = append(list, s[strconv.Itoa(i)])
+	}
+	return list, nil
+}
+
+func (s gadgets) Save(ctx context.Context, id string, g gadget) (gadget, error) {
+	if len(id) == 0 {
+		id = strconv.Itoa(len(s) + 1)
+	}
+	g.ID = id
+	s[id] = g
+	return g, nil
+}
+
+func (s gadgets) Delete(ctx context.Context, id string) error {
+	delete(s, id)
+	return nil
+}
+
+func typedRequest(method, uri, id, body string, action http.HandlerFunc) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, uri, strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	action.ServeHTTP(http.NewResponse(rec, "json"), http.NewRequest(r, httprouter.Params{{Key: "gadget", Value: id}}))
+	return rec
+}
+
+func TestTyped(t *testing.T) {
+	s := gadgets{}
+	r := NewTyped[gadget](s)
+	r.Mount("/gadgets", "gadget")
+
+	tests := []struct {
+		method string
+		id     string
+		body   string
+		action http.HandlerFunc
+		status int
+	}{{"POST", "", `{"name": "lamp"}`, r.Store, stdhttp.StatusCreated},
+		{"POST", "", `{"id": "x"}`, r.Store, stdhttp.StatusBadRequest},
+		{"POST", "", `{"name": "desk"}`, r.Store, stdhttp.StatusCreated},
+		{"PATCH", "1", `{"name": "light"}`, r.Apply, stdhttp.StatusOK},
+		{"PUT", "2", `{"name": "table"}`, r.Update, stdhttp.StatusOK},
+		{"GET", "3", "", r.Show, stdhttp.StatusBadRequest},
+		{"DELETE", "2", "", r.Destroy, stdhttp.StatusNoContent}}
+
+	for _, test := range tests {
+		rec := typedRequest(test.method, "/gadgets", test.id, test.body, test.action)
+		if rec.Code != test.status {
+			t.Errorf("%s %s: expected status %d got %d: %s", test.method, test.body, test.status, rec.Code, rec.Body.String())
+		}
+	}
+
+	if expected := (gadgets{"1": {ID: "1", Name: "light"}}); !reflect.DeepEqual(s, expected) {
+		t.Errorf("expected %v got %v", expected, s)
+	}
+
+	rec := typedRequest("GET", "/gadgets?fields=name", "", "", r.Index)
+	if body := strings.TrimSpace(rec.Body.String()); body != `[{"name":"light"}]` {
+		t.Errorf("expected sparse list got %s", body)
+	}
+}
+
+func TestTypedModel(t *testing.T) {
+	m := newLedger()
+	r := NewTyped[*ledger](Adapt(m))
+	r.Mount("/ledgers", "gadget")
+
+	rec := typedRequest("PATCH", "/ledgers/a", "a", `{"name": "A"}`, r.Apply)
+	var item ledger
+	if err := json.Unmarshal(rec.Body.Bytes(), &item); err != nil {
+		t.Fatal(err)
+	}
+	if item.ID != "a" || item.Name != "A" {
+		t.Errorf("expected ledger a named A got %+v", item)
+	}
+
+	typedRequest("DELETE", "/ledgers/b", "b", "", r.Destroy)
+	if expected := []string{"save a", "delete b"}; !reflect.DeepEqual(*m.log, expected) {
+		t.Errorf("expected %v got %v", expected, *m.log)
+	}
+}
+
+func TestTypedTrash(t *testing.T) {
+	m := newBin()
+	r := NewTyped[*bin](Adapt(m))
+	r.Mount("/bins", "gadget")
+
+	if rec := typedRequest("DELETE", "/bins/a", "a", "", r.Destroy); rec.Code != stdhttp.StatusNoContent {
+		t.Errorf("expected status %d got %d", stdhttp.StatusNoContent, rec.Code)
+	}
+	if rec := typedRequest("DELETE", "/bins/x", "x", "", r.Destroy); rec.Code != stdhttp.StatusBadRequest {
+		t.Errorf("expected status %d got %d", stdhttp.StatusBadRequest, rec.Code)
+	}
+
+	if ids := listed(t, typedRequest("GET", "/bins", "", "", r.Index)); ids != "b" {
+		t.Errorf("expected items 'b' got '%s'", ids)
+	}
+	if ids := listed(t, typedRequest("GET", "/bins?trashed=true", "", "", r.Index)); ids != "a,c" {
+		t.Errorf("expected trashed items 'a,c' got '%s'", ids)
+	}
+}
+
+func TestTypedTrashedItems(t *testing.T) {
+	m := newBin()
+	r := NewTyped[*bin](Adapt(m))
+	r.Mount("/bins", "gadget")
+
+	tests := []struct {
+		method string
+		uri    string
+		action http.HandlerFunc
+		status int
+	}{{"GET", "/bins/c", r.Show, stdhttp.StatusNotFound},
+		{"GET", "/bins/c?trashed=true", r.Show, stdhttp.StatusOK},
+		{"PUT", "/bins/c", r.Update, stdhttp.StatusNotFound},
+		{"PATCH", "/bins/c", r.Apply, stdhttp.StatusNotFound},
+		{"DELETE", "/bins/c", r.Destroy, stdhttp.StatusNotFound}}
+
+	for _, test := range tests {
+		if rec := typedRequest(test.method, test.uri, "c", `{"id": "c"}`, test.action); rec.Code != test.status {
+			t.Errorf("%s %s: expected status %d got %d: %s", test.method, test.uri, test.status, rec.Code, rec.Body.String())
+		}
+	}
+	if !m.store["c"] {
+		t.Error("expected item 'c' to stay trashed")
+	}
+}
+
+func TestTypedPolicy(t *testing.T) {
+	m := newBin()
+	r := NewTyped[*bin](Adapt(m))
+	r.Mount("/bins", "gadget")
+	r.Policy = owners
+
+	if ids := listed(t, trashRequest("GET", "/bins", &http.APIKey{Key: "a"}, nil, r.Index)); ids != "a" {
+		t.Errorf("expected items 'a' got '%s'", ids)
+	}
+
+	tests := []struct {
+		method string
+		id     string
+		action http.HandlerFunc
+		status int
+	}{{"GET", "b", r.Show, stdhttp.StatusForbidden},
+		{"GET", "a", r.Show, stdhttp.StatusOK},
+		{"PATCH", "b", r.Apply, stdhttp.StatusForbidden},
+		{"PUT", "b", r.Update, stdhttp.StatusForbidden},
+		{"DELETE", "b", r.Destroy, stdhttp.StatusForbidden},
+		{"DELETE", "a", r.Destroy, stdhttp.StatusNoContent}}
+
+	for _, test := range tests {
+		ps := httprouter.Params{{Key: "gadget", Value: test.id}}
+		rec := trashRequest(test.method, "/bins/"+test.id, &http.APIKey{Key: "a"}, ps, test.action)
+		if rec.Code != test.status {
+			t.Errorf("%s %s: expected status %d got %d", test.method, test.id, test.status, rec.Code)
+		}
+	}
+	if m.store["b"] {
+		t.Error("expected item 'b' not to be trashed")
+	}
+
+	req := httptest.NewRequest("POST", "/bins", strings.NewReader(`{"id": "d"}`))
+	req = req.WithContext(http.WithAPIKey(req.Context(), http.APIKey{Key: "a"}))
+	rec := httptest.NewRecorder()
+	r.Store(http.NewResponse(rec, "json"), http.NewRequest(req, nil))
+	if rec.Code != stdhttp.StatusForbidden {
+		t.Errorf("expected storing item 'd' to be forbidden, got %d", rec.Code)
+	}
+}
+
+func TestTypedPolicyContext(t *testing.T) {
+	allow := PolicyFunc(func(ctx context.Context, principal interface{}, action Action, item blueprint.Model) bool {
+		return true
+	})
+	deny := PolicyFunc(func(ctx context.Context, principal interface{}, action Action, item blueprint.Model) bool {
+		return false
+	})
+	with := func(p Policy, action http.HandlerFunc) http.HandlerFunc {
+		return func(resp http.ResponseWriter, req *http.Request) {
+			req.SetContext(WithPolicy(req.Context(), p))
+			action(resp, req)
+		}
+	}
+
+	bins := NewTyped[*bin](Adapt(newBin()))
+	bins.Mount("/bins", "gadget")
+	if rec := typedRequest("GET", "/bins/a", "a", "", with(deny, bins.Show)); rec.Code != stdhttp.StatusForbidden {
+		t.Errorf("expected the route policy to deny, got %d", rec.Code)
+	}
+	if rec := typedRequest("GET", "/bins/a", "a", "", with(allow, bins.Show)); rec.Code != stdhttp.StatusOK {
+		t.Errorf("expected the route policy to allow, got %d", rec.Code)
+	}
+
+	// policies can not judge items that are not models
+	s := gadgets{"1": {ID: "1", Name: "lamp"}}
+	r := NewTyped[gadget](s)
+	r.Mount("/gadgets", "gadget")
+	if rec := typedRequest("GET", "/gadgets/1", "1", "", with(allow, r.Show)); rec.Code != stdhttp.StatusForbidden {
+		t.Errorf("expected a plain item to be denied, got %d", rec.Code)
+	}
+	if rec := typedRequest("GET", "/gadgets/1", "1", "", r.Show); rec.Code != stdhttp.StatusOK {
+		t.Errorf("expected a plain item without a policy to be shown, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,25 @@
+package router
+
+import (
+	http "github.com/blueprint/blueprint/transport"
+)
+
+// BodyLimit returns middleware that limits request bodies to n bytes. Bodies
+// read through Request.Bytes, Unmarshal or Upload that are larger fail with
+// http.ErrLargeBody, which WriteErrs answers with 413 Request Entity Too
+// Large.
+//
+// Use it on a router to limit all of its routes, or on a route to give it a
+// limit of its own. Route middleware run after router middleware, so a route
+// limit replaces the router limit, even when it is larger.
+//
+//	m.Middleware(router.BodyLimit(1 << 20))
+//	m.POST("/uploads", upload, router.BodyLimit(32<<20))
+func BodyLimit(n int64) Middleware {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+			req.SetBodyLimit(n)
+			h.ServeHTTP(resp, req)
+		})
+	}
+}
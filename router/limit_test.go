@@ -0,0 +1,42 @@
+package router
+
+import (
+	stdhttp "net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	http "github.com/blueprint/blueprint/transport"
+)
+
+func TestBodyLimit(t *testing.T) {
+	echo := func(resp http.ResponseWriter, req *http.Request) {
+		var v map[string]string
+		if err := req.Unmarshal(&v); err != nil {
+			resp.WriteErrs(req, err)
+			return
+		}
+		resp.WriteJSON(false, v)
+	}
+
+	mux := NewMux()
+	mux.Middleware(BodyLimit(8))
+	mux.POST("/small", echo)
+	mux.POST("/large", echo, BodyLimit(64))
+
+	tests := []struct {
+		path string
+		code int
+	}{
+		{"/small", stdhttp.StatusRequestEntityTooLarge},
+		{"/large", stdhttp.StatusOK},
+	}
+
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest("POST", tt.path, strings.NewReader(`{"name":"gopher"}`)))
+		if rec.Code != tt.code {
+			t.Errorf("expected %s to respond %d got %d", tt.path, tt.code, rec.Code)
+		}
+	}
+}
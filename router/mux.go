@@ -1,11 +1,9 @@
 package router
 
 import (
-	"log"
 	"net/http"
-	"os"
 	"path"
-	"path/filepath"
+	"strings"
 
 	"github.com/blueprint/blueprint/resource"
 	"github.com/julienschmidt/httprouter"
@@ -64,89 +62,59 @@ func (m *Mux) Subrouter(path string) Router {
 
 // GET registers a URL path with an Action.
 func (m *Mux) GET(uri string, f http.HandlerFunc, middleware ...Middleware) {
-	url := path.Join(m.prefix, uri)
-	// if the path ends with a param use a dynamic formatted action, otherwise
-	// statically define the routes for better performance
-	if paramEnd(uri) {
-		m.mux.GET(url, m.action(f, middleware...))
-	} else {
-		m.mux.GET(url, m.actionWithFormat(JSON, f, middleware...))
-		m.mux.GET(url+dotJSON, m.actionWithFormat(JSON, f, middleware...))
-		m.mux.GET(url+dotXML, m.actionWithFormat(XML, f, middleware...))
-		m.mux.GET(url+dotYML, m.actionWithFormat(YML, f, middleware...))
-	}
-	endpoints = append(endpoints, Endpoint{Type: "GET", Path: url})
+	m.register("GET", uri, f, middleware...)
 }
 
 // POST registers a URL path with an Action.
 func (m *Mux) POST(uri string, f http.HandlerFunc, mw ...Middleware) {
-	url := path.Join(m.prefix, uri)
-	// if the path ends with a param use a dynamic formatted action, otherwise
-	// statically define the routes for better performance
-	if paramEnd(uri) {
-		m.mux.POST(url, m.action(f, mw...))
-	} else {
-		m.mux.POST(url, m.actionWithFormat(JSON, f, mw...))
-		m.mux.POST(url+dotJSON, m.actionWithFormat(JSON, f, mw...))
-		m.mux.POST(url+dotXML, m.actionWithFormat(XML, f, mw...))
-		m.mux.POST(url+dotYML, m.actionWithFormat(YML, f, mw...))
-	}
-	endpoints = append(endpoints, Endpoint{Type: "POST", Path: url})
+	m.register("POST", uri, f, mw...)
 }
 
 // PATCH registers a URL path with an Action.
 func (m *Mux) PATCH(uri string, f http.HandlerFunc, mw ...Middleware) {
-	url := path.Join(m.prefix, uri)
-	// if the path ends with a param use a dynamic formatted action, otherwise
-	// statically define the routes for better performance
-	if paramEnd(uri) {
-		m.mux.PATCH(url, m.action(f, mw...))
-	} else {
-		m.mux.PATCH(url, m.actionWithFormat(JSON, f, mw...))
-		m.mux.PATCH(url+dotJSON, m.actionWithFormat(JSON, f, mw...))
-		m.mux.PATCH(url+dotXML, m.actionWithFormat(XML, f, mw...))
-		m.mux.PATCH(url+dotYML, m.actionWithFormat(YML, f, mw...))
-	}
-	endpoints = append(endpoints, Endpoint{Type: "PATCH", Path: url})
+	m.register("PATCH", uri, f, mw...)
 }
 
 // PUT registers a URL path with an Action.
 func (m *Mux) PUT(uri string, f http.HandlerFunc, mw ...Middleware) {
-	url := path.Join(m.prefix, uri)
-	// if the path ends with a param use a dynamic formatted action, otherwise
-	// statically define the routes for better performance
-	if paramEnd(uri) {
-		m.mux.PUT(url, m.action(f, mw...))
-	} else {
-		m.mux.PUT(url, m.actionWithFormat(JSON, f, mw...))
-		m.mux.PUT(url+dotJSON, m.actionWithFormat(JSON, f, mw...))
-		m.mux.PUT(url+dotXML, m.actionWithFormat(XML, f, mw...))
-		m.mux.PUT(url+dotYML, m.actionWithFormat(YML, f, mw...))
-	}
-	endpoints = append(endpoints, Endpoint{Type: "PUT", Path: url})
+	m.register("PUT", uri, f, mw...)
 }
 
 // DELETE registers a URL path with an Action.
 func (m *Mux) DELETE(uri string, f http.HandlerFunc, mw ...Middleware) {
+	m.register("DELETE", uri, f, mw...)
+}
+
+// register registers a URL path with an Action for a method and adds it to
+// the endpoints.
+func (m *Mux) register(method, uri string, f http.HandlerFunc, mw ...Middleware) {
+	url := m.handle(method, uri, f, mw...)
+	endpoints = append(endpoints, Endpoint{Type: method, Path: url})
+}
+
+// handle registers a URL path with an Action for a method and returns the
+// full path.
+func (m *Mux) handle(method, uri string, f http.HandlerFunc, mw ...Middleware) string {
 	url := path.Join(m.prefix, uri)
 	// if the path ends with a param use a dynamic formatted action, otherwise
 	// statically define the routes for better performance
 	if paramEnd(uri) {
-		m.mux.DELETE(url, m.action(f, mw...))
+		m.mux.Handle(method, url, m.action(url, f, mw...))
 	} else {
-		m.mux.DELETE(url, m.actionWithFormat(JSON, f, mw...))
-		m.mux.DELETE(url+dotJSON, m.actionWithFormat(JSON, f, mw...))
-		m.mux.DELETE(url+dotXML, m.actionWithFormat(XML, f, mw...))
-		m.mux.DELETE(url+dotYML, m.actionWithFormat(YML, f, mw...))
+		m.mux.Handle(method, url, m.actionWithFormat(url, JSON, f, mw...))
+		m.mux.Handle(method, url+dotJSON, m.actionWithFormat(url, JSON, f, mw...))
+		m.mux.Handle(method, url+dotXML, m.actionWithFormat(url, XML, f, mw...))
+		m.mux.Handle(method, url+dotYML, m.actionWithFormat(url, YML, f, mw...))
 	}
-	endpoints = append(endpoints, Endpoint{Type: "DELETE", Path: url})
+	return url
 }
 
 // action is a private HTTP handler that executes a controller method.
 //
 // action also takes multiple negroni.Handler objects to create the middleware
-// chain for a route.
-func (m *Mux) action(h http.Handler, mw ...Middleware) httprouter.Handle {
+// chain for a route. The route pattern is passed to the request context, see
+// http.RouteFrom.
+func (m *Mux) action(route string, h http.Handler, mw ...Middleware) httprouter.Handle {
 	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
 		var format string
 		if len(ps) > 0 {
@@ -166,6 +134,7 @@ func (m *Mux) action(h http.Handler, mw ...Middleware) httprouter.Handle {
 
 		resp := http.NewResponse(w, format)
 		req := http.NewRequest(r, ps)
+		req.SetContext(http.WithRoute(req.Context(), route))
 
 		if len(mw) > 0 {
 			chain := m.mc.Append(mw...).Then(h)
@@ -191,10 +160,11 @@ func paramEnd(path string) bool {
 }
 
 // actionWithFormat executes an action method, but also specifies the return format.
-func (m *Mux) actionWithFormat(format string, h http.Handler, mw ...Middleware) httprouter.Handle {
+func (m *Mux) actionWithFormat(route, format string, h http.Handler, mw ...Middleware) httprouter.Handle {
 	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
 		resp := http.NewResponse(w, format)
 		req := http.NewRequest(r, ps)
+		req.SetContext(http.WithRoute(req.Context(), route))
 
 		if len(mw) > 0 {
 			chain := m.mc.Append(mw...).Then(h)
@@ -207,46 +177,131 @@ func (m *Mux) actionWithFormat(format string, h http.Handler, mw ...Middleware)
 }
 
 // Resource registers a URL path with a Controller that impliments all
-// Index, Store, Show, Update, Apply, and Destory Actions.
+// Index, Store, Show, Update, Apply, and Destory Actions, such as a
+// resource.Resource or a resource.Typed.
+//
+// Resource controllers that satisfy resource.Mounter are told the full path
+// and id parameter they are routed under. Those that satisfy
+// resource.BulkResourcer also have their bulk actions routed to the path, and
+// those that satisfy resource.Trasher have restore and purge actions.
+//
+// Resource controllers that satisfy resource.Actioner choose which of these
+// actions are routed, and add custom actions on the collection, such as
+// GET /orders/export, or on single items, such as POST /orders/:id/cancel.
+//
+// Resource controllers that satisfy resource.Nester are routed under the full
+// item path their owner was mounted at, whatever router mounted it, unless
+// the path already starts with it. Resource panics if the owner has not been
+// routed yet, as it does for duplicate routes:
+//
+//	m.Resource("/users", "user", users)
+//	m.Resource("/posts", "post", posts)          // /users/:user/posts
+//	m.Resource("/comments", "comment", comments) // /users/:user/posts/:post/comments
 func (m *Mux) Resource(path, id string, r resource.Resourcer, mw ...Middleware) {
-	pathID := m.prefix + path + "/:" + id
-	m.GET(path, r.Index, mw...)
-	m.GET(pathID, r.Show, mw...)
-	m.POST(path, r.Store, mw...)
-	m.PUT(pathID, r.Update, mw...)
-	m.PATCH(pathID, r.Apply, mw...)
-	m.DELETE(pathID, r.Destroy, mw...)
-}
+	if nester, ok := r.(resource.Nester); ok {
+		owner := nester.OwnerPath()
+		if len(owner) == 0 {
+			panic("router: the owner of resource " + m.prefix + path + " must be routed first")
+		}
+		if full := m.prefix + path; strings.HasPrefix(full+"/", owner+"/") {
+			path = full
+		} else {
+			path = owner + path
+		}
 
-// Static registers a URL path with a public directory to serve its content.
-// This directory is meant to serve public static files such as image files,
-// CSS files, JavaScript files, and more.
-func (m *Mux) Static(uri, dir string) {
-	uri = path.Clean(uri)
-	if uri == "/" {
-		uri = ""
+		// the path is complete, so it is routed without the prefix
+		m = &Mux{mux: m.mux, mc: m.mc}
 	}
 
-	url := path.Join(m.prefix, uri)
-	files := path.Join(url, "*filepath")
+	if mounter, ok := r.(resource.Mounter); ok {
+		mounter.Mount(m.prefix+path, id)
+	}
+
+	routed := func(resource.Action) bool { return true }
+	var routes []resource.Route
+	if actioner, ok := r.(resource.Actioner); ok {
+		routed = actioner.Routed
+		routes = actioner.Routes()
+	}
+
+	pathID := path + "/:" + id
+
+	// httprouter cannot route a static segment next to the id parameter, so
+	// collection actions are dispatched by the routes of single items, and
+	// resource controllers reject items stored with their names as ids
+	collection := map[string]map[string]http.HandlerFunc{}
+	for _, route := range routes {
+		if route.Member {
+			m.register(route.Method, pathID+"/"+route.Name, route.Handler, mw...)
+			continue
+		}
+		if collection[route.Method] == nil {
+			collection[route.Method] = map[string]http.HandlerFunc{}
+		}
+		collection[route.Method][route.Name] = route.Handler
+		endpoints = append(endpoints, Endpoint{Type: route.Method, Path: m.prefix + path + "/" + route.Name})
+	}
 
-	// check directory path is valid
-	if _, err := os.Stat(dir); err != nil {
+	item := func(method string, action resource.Action, f http.HandlerFunc) {
+		names := collection[method]
+		delete(collection, method)
 
-		// since dir is not a valid directory path, assume the path given is
-		// relative to the binary executing
-		current, err := filepath.Abs(filepath.Dir(os.Args[0]))
-		if err != nil {
-			log.Fatalln(err)
+		switch {
+		case f != nil && routed(action) && names == nil:
+			m.register(method, pathID, f, mw...)
+		case f != nil && routed(action):
+			m.register(method, pathID, dispatch(id, names, f), mw...)
+		case names != nil:
+			m.handle(method, pathID, dispatch(id, names, invalidID), mw...)
 		}
+	}
 
-		dir = filepath.Join(current, dir)
+	if routed(resource.IndexAction) {
+		m.GET(path, r.Index, mw...)
+	}
+	if routed(resource.StoreAction) {
+		m.POST(path, r.Store, mw...)
+	}
+	item("GET", resource.ShowAction, r.Show)
+	item("PUT", resource.UpdateAction, r.Update)
+	item("PATCH", resource.ApplyAction, r.Apply)
+	item("DELETE", resource.DestroyAction, r.Destroy)
+	for method := range collection {
+		item(method, "", nil)
+	}
 
-		// serve all files in the directory
-		m.mux.ServeFiles(files, http.Dir(dir))
-		return
+	if bulk, ok := r.(resource.BulkResourcer); ok {
+		if routed(resource.ApplyAction) {
+			m.PATCH(path, bulk.BulkApply, mw...)
+		}
+		if routed(resource.DestroyAction) {
+			m.DELETE(path, bulk.BulkDestroy, mw...)
+		}
 	}
 
-	// serve all files in the directory
-	m.mux.ServeFiles(files, http.Dir(dir))
+	if trash, ok := r.(resource.Trasher); ok && trash.Trashable() {
+		if routed(resource.RestoreAction) {
+			m.POST(pathID+"/restore", trash.Restore, mw...)
+		}
+		if routed(resource.PurgeAction) {
+			m.DELETE(pathID+"/purge", trash.Purge, mw...)
+		}
+	}
+}
+
+// dispatch returns an Action that runs the collection action named by the id
+// parameter, if any, and f otherwise.
+func dispatch(id string, names map[string]http.HandlerFunc, f http.HandlerFunc) http.HandlerFunc {
+	return func(resp http.ResponseWriter, req *http.Request) {
+		if action, ok := names[req.Param(id)]; ok {
+			action(resp, req)
+			return
+		}
+		f(resp, req)
+	}
+}
+
+// invalidID is the Action for item routes whose standard action is not routed.
+func invalidID(resp http.ResponseWriter, req *http.Request) {
+	resp.WriteErrs(req, http.ErrInvalidID)
 }
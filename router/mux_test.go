@@ -3,6 +3,9 @@ package router
 import (
 	"net/http"
 	"testing"
+
+	"github.com/blueprint/blueprint/mock"
+	"github.com/blueprint/blueprint/resource"
 )
 
 func TestNewMux(t *testing.T) {
@@ -49,3 +52,53 @@ func TestMuxDELETE(t *testing.T) {
 	mux.DELETE("/some/endpoint", func(resp http.ResponseWriter, req *http.Request) {})
 	mux.DELETE("/some/endpoint/:id", func(resp http.ResponseWriter, req *http.Request) {})
 }
+
+func TestMuxResourceNested(t *testing.T) {
+	mux := NewMux().Subrouter("/v1")
+
+	users := resource.New(mock.NewModel())
+	posts := users.Extend(mock.NewModel())
+	comments := posts.Extend(mock.NewModel())
+	likes := comments.Extend(mock.NewModel())
+
+	mux.Resource("/users", "user", users)
+	mux.Resource("/posts", "post", posts)
+	mux.Resource("/users/:user/posts/:post/comments", "comment", comments)
+
+	if path := likes.OwnerPath(); path != "/v1/users/:user/posts/:post/comments/:comment" {
+		t.Errorf("expected owner path /v1/users/:user/posts/:post/comments/:comment got %s", path)
+	}
+}
+
+func TestMuxResourceOwner(t *testing.T) {
+	mux := NewMux()
+
+	users := resource.New(mock.NewModel())
+	posts := users.Extend(mock.NewModel())
+	comments := posts.Extend(mock.NewModel())
+
+	// an owner that was never routed is reported at registration
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("expected routing a resource with an unrouted owner to panic")
+			}
+		}()
+		mux.Resource("/posts", "post", posts)
+	}()
+
+	// owners are found by their mount, whatever the prefix of the router
+	mux.Subrouter("/v1").Resource("/users", "user", users)
+	mux.Subrouter("/admin").Resource("/posts", "post", posts)
+	mux.Resource("/comments", "comment", comments)
+
+	if path := comments.OwnerPath(); path != "/v1/users/:user/posts/:post" {
+		t.Errorf("expected owner path /v1/users/:user/posts/:post got %s", path)
+	}
+
+	for _, uri := range []string{"/v1/users/1/posts/2", "/v1/users/1/posts/2/comments"} {
+		if h, _, _ := mux.mux.Lookup("GET", uri); h == nil {
+			t.Errorf("expected a route for %s", uri)
+		}
+	}
+}
@@ -0,0 +1,20 @@
+package router
+
+import (
+	"net/http"
+
+	"github.com/blueprint/blueprint/resource"
+)
+
+// Authorize returns middleware that registers a policy for the resource
+// controllers of a route. Controllers with a Policy of their own ignore it.
+//
+//	m.Resource("/posts", "post", posts, router.Authorize(policy))
+func Authorize(p resource.Policy) Middleware {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+			req.SetContext(resource.WithPolicy(req.Context(), p))
+			h.ServeHTTP(resp, req)
+		})
+	}
+}
@@ -1,9 +1,11 @@
 package http
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"mime"
 	"net/http"
 	"net/url"
 	"strings"
@@ -17,6 +19,8 @@ type Request struct {
 	params  httprouter.Params
 	queries url.Values
 	buf     []byte
+	upload  *Upload
+	limit   int64
 }
 
 // NewRequest takes a http.Request returns a new Request.
@@ -97,30 +101,92 @@ func (r *Request) QueryBool(name string) bool {
 	return out
 }
 
+// Accepts checks if the Accept header of the http.Request lists a media type.
+func (r *Request) Accepts(mediaType string) bool {
+	for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+		if i := strings.Index(accept, ";"); i > -1 {
+			accept = accept[:i]
+		}
+		if strings.EqualFold(strings.TrimSpace(accept), mediaType) {
+			return true
+		}
+	}
+	return false
+}
+
+// ContentType returns the media type of the http.Request body without any
+// parameters. An empty string is returned if no valid type is provided.
+func (r *Request) ContentType() string {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return ""
+	}
+	return mediaType
+}
+
+// SetContext replaces the context of the Request. Middleware use it to pass
+// values down to the handlers after them.
+func (r *Request) SetContext(ctx context.Context) {
+	r.Request = *r.Request.WithContext(ctx)
+}
+
+// SetBodyLimit limits the body of the Request to n bytes. Larger bodies fail
+// to read with ErrLargeBody. Later limits replace earlier ones, as long as the
+// body has not been read yet.
+func (r *Request) SetBodyLimit(n int64) {
+	r.limit = n
+}
+
+// body returns the body of the http.Request, limited by SetBodyLimit.
+func (r *Request) body() (io.Reader, error) {
+	if r.limit <= 0 {
+		return r.Body, nil
+	}
+	if r.ContentLength > r.limit {
+		return nil, fmt.Errorf("%w: over %d bytes", ErrLargeBody, r.limit)
+	}
+	return http.MaxBytesReader(nil, r.Body, r.limit), nil
+}
+
 // Bytes returns the bytes of http.Request body.
 func (r *Request) Bytes() ([]byte, error) {
 	// check if the http.Request has already been read
 	if r.buf == nil {
-		body, err := ioutil.ReadAll(r.Body)
+		body, err := r.body()
 		if err != nil {
 			return nil, err
 		}
+		buf, err := ioutil.ReadAll(body)
+		if err != nil {
+			return nil, bodyError(err)
+		}
 
 		// save body bytes for later use
-		r.buf = body
+		r.buf = buf
 	}
 	return r.buf, nil
 }
 
 // Unmarshal parses the JSON-encoded data in the Request body and stores the result in the value pointed to by v.
+// The body is decoded with the DefaultDecoder, so by default unknown fields
+// and deeply nested values are rejected with ErrInvalidJSON.
+//
+// Multipart and urlencoded form bodies are parsed with Upload and bound to v,
+// so a model can take a file alongside its JSON metadata.
 func (r *Request) Unmarshal(v interface{}) error {
+	switch r.ContentType() {
+	case MediaForm, MediaMultipart:
+		u, err := r.Upload()
+		if err != nil {
+			return err
+		}
+		return u.Bind(v)
+	}
+
 	bytes, err := r.Bytes()
 	if err != nil {
 		return err
 	}
 
-	if err := json.Unmarshal(bytes, v); err != nil {
-		return fmt.Errorf("%s: %s", InvalidJSON, err)
-	}
-	return nil
+	return DefaultDecoder.Decode(bytes, v)
 }
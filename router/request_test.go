@@ -262,3 +262,41 @@ func TestRequestUnmarshalJSON(t *testing.T) {
 type helloworld struct {
 	Title string `json:"title"`
 }
+
+func TestRequestAccepts(t *testing.T) {
+
+	tests := []struct {
+		accept    string
+		mediaType string
+		result    bool
+	}{{"application/json", "application/json", true},
+		{"text/html, application/vnd.api+json; q=0.9", "application/vnd.api+json", true},
+		{"application/hal+json", "application/vnd.api+json", false},
+		{"", "application/json", false}}
+
+	for _, test := range tests {
+		r, err := http.NewRequest("GET", "foo.com/some/endpoint", strings.NewReader(""))
+		if err != nil {
+			t.Error(err)
+		}
+		r.Header.Set("Accept", test.accept)
+		req := NewRequest(r, []httprouter.Param{})
+
+		if ok := req.Accepts(test.mediaType); ok != test.result {
+			t.Errorf("%s: expected %t got %t", test.accept, test.result, ok)
+		}
+	}
+}
+
+func TestRequestContentType(t *testing.T) {
+	r, err := http.NewRequest("POST", "foo.com/some/endpoint", strings.NewReader(""))
+	if err != nil {
+		t.Error(err)
+	}
+	r.Header.Set("Content-Type", "application/vnd.api+json; charset=utf-8")
+	req := NewRequest(r, []httprouter.Param{})
+
+	if ct := req.ContentType(); ct != "application/vnd.api+json" {
+		t.Errorf("expected 'application/vnd.api+json' got '%s'", ct)
+	}
+}
@@ -0,0 +1,92 @@
+package router
+
+import (
+	stdhttp "net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/blueprint/blueprint"
+	"github.com/blueprint/blueprint/mock"
+	"github.com/blueprint/blueprint/resource"
+	http "github.com/blueprint/blueprint/transport"
+)
+
+func serveResource(mux *Mux, method, uri string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(method, uri, nil))
+	return rec
+}
+
+func TestMuxResourceActions(t *testing.T) {
+	mux := NewMux()
+
+	var member blueprint.Model
+	orders := resource.New(mock.NewModel())
+	orders.Only = []resource.Action{resource.IndexAction, resource.ShowAction}
+	orders.Collection("GET", "export", func(resp http.ResponseWriter, req *http.Request) {
+		resp.Status(http.StatusAccepted)
+		resp.WriteFormat(req, "export")
+	})
+	orders.Member("POST", "cancel", func(resp http.ResponseWriter, req *http.Request, item blueprint.Model) {
+		member = item
+		resp.WriteFormat(req, "cancelled")
+	})
+	orders.Collection("POST", "import", func(resp http.ResponseWriter, req *http.Request) {
+		resp.Status(http.StatusAccepted)
+		resp.WriteFormat(req, "import")
+	})
+
+	before := len(Endpoints())
+	mux.Resource("/orders", "order", orders)
+
+	tests := []struct {
+		method string
+		uri    string
+		status int
+	}{{"GET", "/orders", stdhttp.StatusOK},
+		{"GET", "/orders/1", stdhttp.StatusOK},
+		{"GET", "/orders/export", stdhttp.StatusAccepted},
+		{"GET", "/orders/export.json", stdhttp.StatusAccepted},
+		{"POST", "/orders/import", stdhttp.StatusAccepted},
+		{"POST", "/orders/1", stdhttp.StatusBadRequest},
+		{"POST", "/orders/1/cancel", stdhttp.StatusOK},
+		{"POST", "/orders", stdhttp.StatusNotFound},
+		{"DELETE", "/orders/1", stdhttp.StatusNotFound}}
+
+	for _, test := range tests {
+		if rec := serveResource(mux, test.method, test.uri); rec.Code != test.status {
+			t.Errorf("%s %s: expected status %d got %d", test.method, test.uri, test.status, rec.Code)
+		}
+	}
+	if member == nil {
+		t.Error("expected the member action to get the order")
+	}
+
+	registered := map[string]bool{}
+	for _, e := range Endpoints()[before:] {
+		registered[e.Type+" "+e.Path] = true
+	}
+	for _, e := range []string{"GET /orders", "GET /orders/:order", "GET /orders/export", "POST /orders/import", "POST /orders/:order/cancel"} {
+		if !registered[e] {
+			t.Errorf("expected endpoint %s to be registered", e)
+		}
+	}
+	if len(registered) != 5 {
+		t.Errorf("expected 5 endpoints got %v", registered)
+	}
+}
+
+func TestMuxResourceExcept(t *testing.T) {
+	mux := NewMux()
+
+	things := resource.New(mock.NewModel())
+	things.Except = []resource.Action{resource.DestroyAction}
+	mux.Resource("/things", "thing", things)
+
+	if rec := serveResource(mux, "DELETE", "/things/1"); rec.Code != stdhttp.StatusNotFound {
+		t.Errorf("expected status %d got %d", stdhttp.StatusNotFound, rec.Code)
+	}
+	if rec := serveResource(mux, "GET", "/things/1"); rec.Code != stdhttp.StatusOK {
+		t.Errorf("expected status %d got %d", stdhttp.StatusOK, rec.Code)
+	}
+}
@@ -2,6 +2,7 @@
 package router
 
 import (
+	"io/fs"
 	"net/http"
 
 	"github.com/blueprint/blueprint/resource"
@@ -26,8 +27,11 @@ type Router interface {
 	// Subrouting for API versioning support.
 	Subrouter(path string) Router
 
+	// Serve WebSocket connections.
+	WebSocket(uri string, h http.WebSocketHandler, m ...Middleware)
+
 	// Serve static files.
-	Static(uri, dir string)
+	Static(uri string, files fs.FS, options ...StaticOption)
 
 	// Impliment the http.Handler interface.
 	ServeHTTP(w http.ResponseWriter, req *http.Request)
@@ -0,0 +1,210 @@
+package router
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	stdhttp "net/http"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// StaticOption configures how Static serves files.
+type StaticOption func(*static)
+
+// CacheControl sets the Cache-Control header value for files whose path
+// matches pattern. Patterns use path.Match syntax and are tried against both
+// the full file path and its base name, so "*.css" matches "css/site.css".
+// The first matching pattern wins.
+func CacheControl(pattern, value string) StaticOption {
+	return func(s *static) {
+		s.cache = append(s.cache, cacheRule{pattern: pattern, value: value})
+	}
+}
+
+// SPA serves the root index.html in place of any file that does not exist, so
+// a single page application's client side router can resolve the path.
+func SPA() StaticOption {
+	return func(s *static) { s.spa = true }
+}
+
+// cacheRule pairs a path.Match pattern with a Cache-Control value.
+type cacheRule struct {
+	pattern string
+	value   string
+}
+
+// static serves files from a fs.FS without directory listings.
+type static struct {
+	files fs.FS
+	spa   bool
+	cache []cacheRule
+
+	// etags caches content hashes for files without a modification time,
+	// such as those in an embed.FS.
+	etags sync.Map
+}
+
+// Static registers a URL path with a file system to serve its content. This is
+// meant to serve public static files such as image files, CSS files,
+// JavaScript files, and more. Any fs.FS works, including embed.FS and
+// os.DirFS.
+//
+// Directories are never listed, a directory is served through its index.html
+// or not at all. If the client accepts gzip and a precompressed ".gz" sibling
+// exists, the sibling is served instead.
+func (m *Mux) Static(uri string, files fs.FS, options ...StaticOption) {
+	uri = path.Clean(uri)
+	if uri == "/" {
+		uri = ""
+	}
+
+	s := &static{files: files}
+	for _, option := range options {
+		option(s)
+	}
+
+	pattern := path.Join("/", m.prefix, uri, "*filepath")
+	m.mux.GET(pattern, s.serve)
+	m.mux.HEAD(pattern, s.serve)
+}
+
+// serve satisfies the httprouter.Handle type.
+func (s *static) serve(w stdhttp.ResponseWriter, r *stdhttp.Request, ps httprouter.Params) {
+	name := strings.TrimPrefix(path.Clean("/"+ps.ByName("filepath")), "/")
+	if name == "" {
+		name = "."
+	}
+
+	name, info, ok := s.resolve(name)
+	if !ok && s.spa {
+		name, info, ok = s.resolve("index.html")
+	}
+	if !ok {
+		stdhttp.NotFound(w, r)
+		return
+	}
+
+	// the original name decides the content type and cache rules, even when a
+	// precompressed sibling is served in its place
+	served := name
+	if acceptsGzip(r) {
+		if gz, err := fs.Stat(s.files, name+".gz"); err == nil && gz.Mode().IsRegular() {
+			if ctype := mime.TypeByExtension(path.Ext(name)); ctype != "" {
+				w.Header().Set("Content-Type", ctype)
+				w.Header().Set("Content-Encoding", "gzip")
+				served, info = name+".gz", gz
+			}
+		}
+	}
+	w.Header().Add("Vary", "Accept-Encoding")
+
+	if value := s.cacheControl(name); value != "" {
+		w.Header().Set("Cache-Control", value)
+	}
+
+	f, err := s.files.Open(served)
+	if err != nil {
+		stdhttp.Error(w, stdhttp.StatusText(stdhttp.StatusInternalServerError), stdhttp.StatusInternalServerError)
+		return
+	}
+	defer f.Close()
+
+	// files that cannot seek are read into memory so ranges still work
+	content, ok := f.(io.ReadSeeker)
+	if !ok {
+		b, err := io.ReadAll(f)
+		if err != nil {
+			stdhttp.Error(w, stdhttp.StatusText(stdhttp.StatusInternalServerError), stdhttp.StatusInternalServerError)
+			return
+		}
+		content = bytes.NewReader(b)
+	}
+
+	etag, err := s.etag(served, info, content)
+	if err != nil {
+		stdhttp.Error(w, stdhttp.StatusText(stdhttp.StatusInternalServerError), stdhttp.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("ETag", etag)
+
+	// ServeContent handles Last-Modified, If-None-Match, and range requests
+	stdhttp.ServeContent(w, r, name, info.ModTime(), content)
+}
+
+// resolve finds the regular file to serve for name. Directories resolve to
+// their index.html, and are otherwise treated as missing.
+func (s *static) resolve(name string) (string, fs.FileInfo, bool) {
+	info, err := fs.Stat(s.files, name)
+	if err != nil {
+		return "", nil, false
+	}
+	if info.IsDir() {
+		name = path.Join(name, "index.html")
+		if info, err = fs.Stat(s.files, name); err != nil || info.IsDir() {
+			return "", nil, false
+		}
+	}
+	return name, info, info.Mode().IsRegular()
+}
+
+// etag returns an entity tag for a file. Files with a modification time are
+// tagged by size and time, files without one (embed.FS) are tagged by a hash
+// of their content.
+func (s *static) etag(name string, info fs.FileInfo, content io.ReadSeeker) (string, error) {
+	if !info.ModTime().IsZero() {
+		return fmt.Sprintf(`"%x-%x"`, info.Size(), info.ModTime().UnixNano()), nil
+	}
+	if etag, ok := s.etags.Load(name); ok {
+		return etag.(string), nil
+	}
+
+	h := sha1.New()
+	if _, err := io.Copy(h, content); err != nil {
+		return "", err
+	}
+	if _, err := content.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+
+	etag := `"` + hex.EncodeToString(h.Sum(nil)) + `"`
+	s.etags.Store(name, etag)
+	return etag, nil
+}
+
+// cacheControl returns the Cache-Control value of the first rule matching name.
+func (s *static) cacheControl(name string) string {
+	for _, rule := range s.cache {
+		if ok, _ := path.Match(rule.pattern, name); ok {
+			return rule.value
+		}
+		if ok, _ := path.Match(rule.pattern, path.Base(name)); ok {
+			return rule.value
+		}
+	}
+	return ""
+}
+
+// acceptsGzip checks if a request accepts a gzip content encoding.
+func acceptsGzip(r *stdhttp.Request) bool {
+	for _, enc := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		enc = strings.TrimSpace(enc)
+		if i := strings.Index(enc, ";"); i > -1 {
+			if strings.TrimSpace(enc[i+1:]) == "q=0" {
+				continue
+			}
+			enc = strings.TrimSpace(enc[:i])
+		}
+		if enc == "gzip" || enc == "*" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,135 @@
+package router
+
+import (
+	stdhttp "net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func staticFiles() fstest.MapFS {
+	return fstest.MapFS{
+		"index.html":       {Data: []byte("<html>home</html>")},
+		"css/site.css":     {Data: []byte("body{}"), ModTime: time.Unix(1500000000, 0)},
+		"js/app.js":        {Data: []byte("plain")},
+		"js/app.js.gz":     {Data: []byte("gzipped")},
+		"docs/index.html":  {Data: []byte("<html>docs</html>")},
+		"images/logo.png":  {Data: []byte("png")},
+		"images/other.png": {Data: []byte("png")},
+	}
+}
+
+func serveStatic(mux *Mux, uri string, header stdhttp.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", uri, nil)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestStatic(t *testing.T) {
+	mux := NewMux()
+	mux.Static("/public", staticFiles())
+
+	tests := []struct {
+		uri    string
+		status int
+		body   string
+	}{{"/public/css/site.css", stdhttp.StatusOK, "body{}"},
+		{"/public/docs/", stdhttp.StatusOK, "<html>docs</html>"},
+		{"/public/images/", stdhttp.StatusNotFound, ""},
+		{"/public/missing.txt", stdhttp.StatusNotFound, ""},
+		{"/public/../index.html", stdhttp.StatusOK, "<html>home</html>"}}
+
+	for _, test := range tests {
+		rec := serveStatic(mux, test.uri, nil)
+		if rec.Code != test.status {
+			t.Errorf("%s: expected status %d got %d", test.uri, test.status, rec.Code)
+		}
+		if test.body != "" && rec.Body.String() != test.body {
+			t.Errorf("%s: expected body '%s' got '%s'", test.uri, test.body, rec.Body.String())
+		}
+	}
+}
+
+func TestStaticETag(t *testing.T) {
+	mux := NewMux()
+	mux.Static("/public", staticFiles())
+
+	for _, uri := range []string{"/public/css/site.css", "/public/index.html"} {
+		rec := serveStatic(mux, uri, nil)
+		etag := rec.Header().Get("ETag")
+		if etag == "" {
+			t.Errorf("%s: expected an ETag header", uri)
+			continue
+		}
+
+		rec = serveStatic(mux, uri, stdhttp.Header{"If-None-Match": {etag}})
+		if rec.Code != stdhttp.StatusNotModified {
+			t.Errorf("%s: expected status %d got %d", uri, stdhttp.StatusNotModified, rec.Code)
+		}
+	}
+
+	rec := serveStatic(mux, "/public/css/site.css", nil)
+	if rec.Header().Get("Last-Modified") == "" {
+		t.Error("expected a Last-Modified header")
+	}
+}
+
+func TestStaticGzip(t *testing.T) {
+	mux := NewMux()
+	mux.Static("/public", staticFiles())
+
+	rec := serveStatic(mux, "/public/js/app.js", stdhttp.Header{"Accept-Encoding": {"br, gzip"}})
+	if rec.Body.String() != "gzipped" {
+		t.Errorf("expected the gzip sibling got '%s'", rec.Body.String())
+	}
+	if rec.Header().Get("Content-Encoding") != "gzip" {
+		t.Errorf("expected Content-Encoding gzip got '%s'", rec.Header().Get("Content-Encoding"))
+	}
+	if rec.Header().Get("Content-Type") != "text/javascript; charset=utf-8" {
+		t.Errorf("expected a javascript Content-Type got '%s'", rec.Header().Get("Content-Type"))
+	}
+
+	rec = serveStatic(mux, "/public/js/app.js", nil)
+	if rec.Body.String() != "plain" {
+		t.Errorf("expected the plain file got '%s'", rec.Body.String())
+	}
+}
+
+func TestStaticCacheControl(t *testing.T) {
+	mux := NewMux()
+	mux.Static("/public", staticFiles(),
+		CacheControl("images/logo.png", "no-cache"),
+		CacheControl("*.png", "public, max-age=86400"))
+
+	tests := []struct {
+		uri   string
+		value string
+	}{{"/public/images/logo.png", "no-cache"},
+		{"/public/images/other.png", "public, max-age=86400"},
+		{"/public/css/site.css", ""}}
+
+	for _, test := range tests {
+		rec := serveStatic(mux, test.uri, nil)
+		if v := rec.Header().Get("Cache-Control"); v != test.value {
+			t.Errorf("%s: expected Cache-Control '%s' got '%s'", test.uri, test.value, v)
+		}
+	}
+}
+
+func TestStaticSPA(t *testing.T) {
+	mux := NewMux()
+	mux.Static("/", staticFiles(), SPA())
+
+	rec := serveStatic(mux, "/users/42/settings", nil)
+	if rec.Code != stdhttp.StatusOK {
+		t.Errorf("expected status %d got %d", stdhttp.StatusOK, rec.Code)
+	}
+	if rec.Body.String() != "<html>home</html>" {
+		t.Errorf("expected index.html got '%s'", rec.Body.String())
+	}
+}
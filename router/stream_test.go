@@ -0,0 +1,53 @@
+package router
+
+import (
+	"bufio"
+	stdhttp "net/http"
+	"net/http/httptest"
+	"testing"
+
+	http "github.com/blueprint/blueprint/transport"
+)
+
+// wrapped is a ResponseWriter wrapped by middleware.
+type wrapped struct {
+	http.ResponseWriter
+}
+
+func (w wrapped) Unwrap() stdhttp.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func TestMuxEventStream(t *testing.T) {
+	mux := NewMux()
+	mux.Middleware(func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+			h.ServeHTTP(wrapped{resp}, req)
+		})
+	})
+	mux.GET("/events", func(resp http.ResponseWriter, req *http.Request) {
+		s, err := http.NewEventStream(resp, req)
+		if err != nil {
+			resp.WriteErrs(req, err)
+			return
+		}
+		s.Send(http.Event{ID: "1", Data: "streamed"})
+		<-s.Done()
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	res, err := stdhttp.Get(server.URL + "/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	r := bufio.NewReader(res.Body)
+	id, _ := r.ReadString('\n')
+	data, _ := r.ReadString('\n')
+	if id != "id: 1\n" || data != "data: streamed\n" {
+		t.Errorf("expected the event to be streamed got %q %q", id, data)
+	}
+}
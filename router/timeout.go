@@ -0,0 +1,178 @@
+package router
+
+import (
+	"context"
+	"fmt"
+	"log"
+	stdhttp "net/http"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+
+	http "github.com/blueprint/blueprint/transport"
+)
+
+// Timeout returns middleware that gives handlers d to respond. The request
+// context gets a deadline, which reaches resource controllers and the
+// blueprint.ContextModel calls they make. Once it passes the client is
+// answered with http.ErrRequestTimeout, 503 Service Unavailable, unless the
+// handler already started its response, and anything the handler writes
+// after that is dropped.
+//
+// Use it on a router to limit all of its routes, or on a route to give it a
+// limit of its own. Deadlines only ever shorten, so a route limit longer than
+// the limit of its router has no effect.
+//
+//	m.Middleware(router.Timeout(10 * time.Second))
+//	m.GET("/reports", reports, router.Timeout(2*time.Second))
+//
+// Requests that upgrade their connection, such as to a WebSocket, and requests
+// that accept an event stream, as EventSource clients do, outlive any timeout
+// and are passed on without one.
+func Timeout(d time.Duration) Middleware {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+			if streaming(req) {
+				h.ServeHTTP(resp, req)
+				return
+			}
+
+			ctx, cancel := context.WithTimeout(req.Context(), d)
+			defer cancel()
+
+			// the handler keeps its own request and response, so whatever
+			// it does after the timeout does not touch ours
+			r := *req
+			r.SetContext(ctx)
+			tw := &timeoutWriter{rw: NewResponseWriter(resp), header: stdhttp.Header{}}
+			w := http.WrapResponse(resp, tw)
+
+			// a panic of the handler is raised again here, unless the
+			// handler timed out, as then nothing waits for it and it is
+			// logged instead
+			done := make(chan struct{})
+			panicked := make(chan interface{}, 1)
+			go func() {
+				defer func() {
+					if p := recover(); p != nil {
+						tw.mu.Lock()
+						defer tw.mu.Unlock()
+						if tw.timedOut {
+							log.Printf("router: panic serving %s %s after a timeout of %s: %v\n%s", req.Method, req.URL.Path, d, p, debug.Stack())
+							return
+						}
+						panicked <- p
+					}
+				}()
+				h.ServeHTTP(w, &r)
+				close(done)
+			}()
+
+			select {
+			case p := <-panicked:
+				panic(p)
+			case <-done:
+				return
+			case <-ctx.Done():
+			}
+
+			tw.mu.Lock()
+			defer tw.mu.Unlock()
+
+			select {
+			case p := <-panicked:
+				panic(p) // the handler panicked as the deadline passed
+			case <-done:
+				return // the handler finished as the deadline passed
+			default:
+			}
+
+			tw.timedOut = true
+			if tw.rw.Written() || req.Context().Err() != nil {
+				return // too late to respond, or the client is gone
+			}
+			resp.WriteErrs(req, fmt.Errorf("%w: no response within %s", http.ErrRequestTimeout, d))
+		})
+	}
+}
+
+// streaming checks if a request upgrades its connection or accepts an event
+// stream.
+func streaming(req *http.Request) bool {
+	if len(req.Header.Get("Upgrade")) > 0 {
+		return true
+	}
+	for _, accept := range req.Header.Values("Accept") {
+		if strings.Contains(accept, http.MediaEventStream) {
+			return true
+		}
+	}
+	return false
+}
+
+// timeoutWriter is the http.ResponseWriter of a handler with a timeout. It
+// passes writes on until the handler times out, and drops them after.
+type timeoutWriter struct {
+	rw     ResponseWriter
+	header stdhttp.Header
+
+	// mu serializes writes of the handler with the timeout response
+	mu       sync.Mutex
+	timedOut bool
+}
+
+// Header returns the header of the handler, which is copied to the response
+// as it is written so that a timeout response does not share it.
+func (tw *timeoutWriter) Header() stdhttp.Header {
+	return tw.header
+}
+
+// WriteHeader satisfies the http.ResponseWriter interface.
+func (tw *timeoutWriter) WriteHeader(code int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	if tw.timedOut || tw.rw.Written() {
+		return
+	}
+	tw.writeHeader(code)
+}
+
+// writeHeader copies the header of the handler and writes it. The caller must
+// hold mu.
+func (tw *timeoutWriter) writeHeader(code int) {
+	for key, values := range tw.header {
+		tw.rw.Header()[key] = append([]string(nil), values...)
+	}
+	tw.rw.WriteHeader(code)
+}
+
+// Write satisfies the http.ResponseWriter interface.
+func (tw *timeoutWriter) Write(b []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	if tw.timedOut {
+		return 0, stdhttp.ErrHandlerTimeout
+	}
+	if !tw.rw.Written() {
+		tw.writeHeader(stdhttp.StatusOK)
+	}
+	return tw.rw.Write(b)
+}
+
+// Flush satisfies the http.Flusher interface.
+func (tw *timeoutWriter) Flush() {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	if !tw.timedOut {
+		tw.rw.Flush()
+	}
+}
+
+// Unwrap returns the wrapped http.ResponseWriter, for http.ResponseController.
+func (tw *timeoutWriter) Unwrap() stdhttp.ResponseWriter {
+	return tw.rw
+}
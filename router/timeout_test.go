@@ -0,0 +1,178 @@
+package router
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"log"
+	"net"
+	stdhttp "net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	http "github.com/blueprint/blueprint/transport"
+)
+
+func TestTimeout(t *testing.T) {
+	late := make(chan error, 1)
+
+	mux := NewMux()
+	mux.Middleware(Timeout(time.Second))
+	mux.GET("/fast", func(resp http.ResponseWriter, req *http.Request) {
+		if _, ok := req.Context().Deadline(); !ok {
+			t.Error("expected the request context to have a deadline")
+		}
+		resp.Header().Set("X-Fast", "yes")
+		resp.WriteJSON(false, "done")
+	})
+	mux.GET("/slow", func(resp http.ResponseWriter, req *http.Request) {
+		<-req.Context().Done()
+		time.Sleep(10 * time.Millisecond)
+		_, err := resp.Write([]byte("too late"))
+		late <- err
+	}, Timeout(20*time.Millisecond))
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("GET", "/fast", nil))
+	if rec.Code != stdhttp.StatusOK || rec.Header().Get("X-Fast") != "yes" {
+		t.Errorf("expected the fast response got %d %v", rec.Code, rec.Header())
+	}
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("GET", "/slow", nil))
+	if rec.Code != stdhttp.StatusServiceUnavailable || !strings.Contains(rec.Body.String(), http.RequestTimeout) {
+		t.Errorf("expected a timeout response got %d %s", rec.Code, rec.Body)
+	}
+	if err := <-late; !errors.Is(err, stdhttp.ErrHandlerTimeout) {
+		t.Errorf("expected the late write to fail got %v", err)
+	}
+	if strings.Contains(rec.Body.String(), "too late") {
+		t.Error("expected the late write to be dropped")
+	}
+}
+
+func TestTimeoutResponse(t *testing.T) {
+	h := Timeout(time.Second)(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		resp.WriteJSON(false, "created")
+	}))
+
+	rec := httptest.NewRecorder()
+	resp := http.NewResponse(rec, "json")
+	resp.Status(stdhttp.StatusCreated)
+	h.ServeHTTP(resp, http.NewRequest(httptest.NewRequest("POST", "/", nil), nil))
+	if rec.Code != stdhttp.StatusCreated {
+		t.Errorf("expected the status set before the timeout got %d", rec.Code)
+	}
+}
+
+// logWriter passes what is logged to a channel.
+type logWriter chan string
+
+func (w logWriter) Write(b []byte) (int, error) {
+	w <- string(b)
+	return len(b), nil
+}
+
+func TestTimeoutPanic(t *testing.T) {
+	logged := make(logWriter, 1)
+	log.SetOutput(logged)
+	defer log.SetOutput(os.Stderr)
+
+	mux := NewMux()
+	mux.Middleware(Timeout(20 * time.Millisecond))
+	mux.GET("/early", func(resp http.ResponseWriter, req *http.Request) {
+		panic("early")
+	})
+	mux.GET("/late", func(resp http.ResponseWriter, req *http.Request) {
+		<-req.Context().Done()
+		time.Sleep(10 * time.Millisecond)
+		panic("late")
+	})
+
+	func() {
+		defer func() {
+			if p := recover(); p != "early" {
+				t.Errorf("expected the early panic to be raised again got %v", p)
+			}
+		}()
+		mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/early", nil))
+	}()
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("GET", "/late", nil))
+	if rec.Code != stdhttp.StatusServiceUnavailable {
+		t.Errorf("expected a timeout response got %d", rec.Code)
+	}
+	select {
+	case msg := <-logged:
+		if !strings.Contains(msg, "panic serving GET /late") || !strings.Contains(msg, "late") {
+			t.Errorf("expected the late panic to be logged got %q", msg)
+		}
+	case <-time.After(time.Second):
+		t.Error("expected the late panic to be logged")
+	}
+}
+
+func TestTimeoutStreams(t *testing.T) {
+	mux := NewMux()
+	mux.Middleware(Timeout(20 * time.Millisecond))
+	mux.WebSocket("/socket", func(ws *http.WebSocket, req *http.Request) {
+		time.Sleep(50 * time.Millisecond)
+		ws.WriteFormat("late")
+	})
+	mux.GET("/events", func(resp http.ResponseWriter, req *http.Request) {
+		s, err := http.NewEventStream(resp, req)
+		if err != nil {
+			resp.WriteErrs(req, err)
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+		if err := req.Context().Err(); err != nil {
+			t.Errorf("expected the stream to outlive the timeout got %v", err)
+		}
+		s.Send(http.Event{Data: "late"})
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	host := strings.TrimPrefix(server.URL, "http://")
+
+	conn, err := net.Dial("tcp", host)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.Write([]byte("GET /socket HTTP/1.1\r\nHost: " + host + "\r\n" +
+		"Upgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Version: 13\r\n" +
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n\r\n"))
+
+	r := bufio.NewReader(conn)
+	res, err := stdhttp.ReadResponse(r, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != stdhttp.StatusSwitchingProtocols {
+		t.Fatalf("expected status 101 got %d", res.StatusCode)
+	}
+	head := make([]byte, 2)
+	io.ReadFull(r, head)
+	payload := make([]byte, head[1]&0x7f)
+	io.ReadFull(r, payload)
+	if string(payload) != `"late"` {
+		t.Errorf("expected the message after the timeout got %q", payload)
+	}
+
+	req, _ := stdhttp.NewRequest("GET", server.URL+"/events", nil)
+	req.Header.Set("Accept", http.MediaEventStream)
+	res, err = stdhttp.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if data, _ := bufio.NewReader(res.Body).ReadString('\n'); data != "data: late\n" {
+		t.Errorf("expected the event after the timeout got %q", data)
+	}
+}
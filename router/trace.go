@@ -0,0 +1,58 @@
+package router
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/blueprint/blueprint/trace"
+	http "github.com/blueprint/blueprint/transport"
+)
+
+// Trace returns middleware that wraps requests in a span of t, named by
+// method and route pattern, such as "GET /users/:user". Requests that carry
+// a W3C traceparent header continue the trace of their caller. The span is
+// held by the request context, so spans of resource controllers, their
+// models and the dba package nest under it.
+//
+//	m.Middleware(router.Trace(trace.DefaultTracer))
+func Trace(t *trace.Tracer) Middleware {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+			ctx := req.Context()
+			if sc, ok := trace.Extract(req.Header); ok {
+				ctx = trace.WithRemote(ctx, sc)
+			}
+
+			route := http.RouteFrom(ctx)
+			if len(route) == 0 {
+				route = req.URL.Path
+			}
+			ctx, span := t.Start(ctx, req.Method+" "+route)
+			span.SetAttribute("http.method", req.Method)
+			span.SetAttribute("http.route", route)
+			span.SetAttribute("http.target", req.URL.RequestURI())
+
+			defer func() {
+				if p := recover(); p != nil {
+					span.SetError(fmt.Errorf("panic: %v", p))
+					span.End()
+					panic(p)
+				}
+			}()
+
+			req.SetContext(ctx)
+			rw := NewResponseWriter(resp)
+			h.ServeHTTP(http.WrapResponse(resp, rw), req)
+
+			status := rw.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+			span.SetAttribute("http.status_code", status)
+			if status >= http.StatusInternalServerError {
+				span.SetError(errors.New(http.StatusText(status)))
+			}
+			span.End()
+		})
+	}
+}
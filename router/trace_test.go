@@ -0,0 +1,75 @@
+package router
+
+import (
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/blueprint/blueprint/trace"
+	http "github.com/blueprint/blueprint/transport"
+)
+
+// spans keeps the spans it exports.
+type spans struct {
+	mu   sync.Mutex
+	list []*trace.Span
+}
+
+func (s *spans) Export(span *trace.Span) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.list = append(s.list, span)
+	return nil
+}
+
+func TestTrace(t *testing.T) {
+	exported := &spans{}
+	tracer := &trace.Tracer{Exporter: exported}
+
+	mux := NewMux()
+	mux.Middleware(Trace(tracer))
+	mux.GET("/users/:user", func(resp http.ResponseWriter, req *http.Request) {
+		_, span := tracer.Start(req.Context(), "FindByID")
+		span.End()
+		resp.WriteErrs(req, http.ErrInvalidID)
+	})
+
+	req := httptest.NewRequest("GET", "/users/1?fields=name", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	mux.ServeHTTP(httptest.NewRecorder(), req)
+
+	if len(exported.list) != 2 {
+		t.Fatalf("expected two spans got %d", len(exported.list))
+	}
+	child, server := exported.list[0], exported.list[1]
+	if server.Name != "GET /users/:user" || server.Attributes["http.target"] != "/users/1?fields=name" {
+		t.Errorf("expected the span to be named by route pattern got %q %v", server.Name, server.Attributes)
+	}
+	if server.Context.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" || server.Parent.String() != "00f067aa0ba902b7" {
+		t.Errorf("expected the trace of the caller to continue got %+v", server.Context)
+	}
+	if child.Parent != server.Context.SpanID {
+		t.Error("expected spans of the handler to nest in the server span")
+	}
+	if server.Attributes["http.status_code"] != http.StatusBadRequest || server.Err != nil {
+		t.Errorf("expected a 400 status without an error got %v %v", server.Attributes["http.status_code"], server.Err)
+	}
+}
+
+func TestTraceResponse(t *testing.T) {
+	exported := &spans{}
+	h := Trace(&trace.Tracer{Exporter: exported})(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		resp.WriteJSON(false, "created")
+	}))
+
+	rec := httptest.NewRecorder()
+	resp := http.NewResponse(rec, "json")
+	resp.Status(http.StatusCreated)
+	h.ServeHTTP(resp, http.NewRequest(httptest.NewRequest("POST", "/users", nil), nil))
+	if rec.Code != http.StatusCreated {
+		t.Errorf("expected the status set before the middleware got %d", rec.Code)
+	}
+	if len(exported.list) != 1 || exported.list[0].Attributes["http.status_code"] != http.StatusCreated {
+		t.Errorf("expected a span with the status set before the middleware got %v", exported.list)
+	}
+}
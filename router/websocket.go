@@ -0,0 +1,25 @@
+package router
+
+import (
+	http "github.com/blueprint/blueprint/transport"
+)
+
+// WebSocket registers a URL path with a handler for WebSocket connections.
+//
+// Middleware run before the upgrade, so requests without a valid API key, or
+// that fail any other check, get a regular error response and never connect.
+// Requests that are not a valid handshake fail with http.ErrInvalidUpgrade.
+//
+// Messages read with ReadFormat and written with WriteFormat use the format
+// of the URL type extension, such as /chat.xml, like any other route.
+func (m *Mux) WebSocket(uri string, h http.WebSocketHandler, mw ...Middleware) {
+	m.GET(uri, func(resp http.ResponseWriter, req *http.Request) {
+		ws, err := http.Upgrade(resp, req)
+		if err != nil {
+			resp.WriteErrs(req, err)
+			return
+		}
+		defer ws.Close()
+		h(ws, req)
+	}, mw...)
+}
@@ -0,0 +1,66 @@
+package router
+
+import (
+	"bufio"
+	"io"
+	"net"
+	stdhttp "net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/blueprint/blueprint/middleware"
+	http "github.com/blueprint/blueprint/transport"
+)
+
+type greeting struct {
+	XMLName  struct{} `xml:"message"`
+	Greeting string   `xml:"greeting"`
+}
+
+func TestMuxWebSocket(t *testing.T) {
+	mux := NewMux()
+	mux.WebSocket("/socket", func(ws *http.WebSocket, req *http.Request) {
+		ws.WriteFormat(greeting{Greeting: "hello"})
+	}, middleware.NewKeys(map[string][]string{"secret": nil}).Do)
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	host := strings.TrimPrefix(server.URL, "http://")
+
+	// the key middleware rejects the request before the upgrade
+	res, err := stdhttp.Get(server.URL + "/socket.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode == stdhttp.StatusSwitchingProtocols {
+		t.Fatal("expected the request without a key to be rejected")
+	}
+
+	conn, err := net.Dial("tcp", host)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.Write([]byte("GET /socket.xml?key=secret HTTP/1.1\r\nHost: " + host + "\r\n" +
+		"Upgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Version: 13\r\n" +
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n\r\n"))
+
+	r := bufio.NewReader(conn)
+	res, err = stdhttp.ReadResponse(r, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != stdhttp.StatusSwitchingProtocols {
+		t.Fatalf("expected status 101 got %d", res.StatusCode)
+	}
+
+	head := make([]byte, 2)
+	io.ReadFull(r, head)
+	payload := make([]byte, head[1]&0x7f)
+	io.ReadFull(r, payload)
+	if string(payload) != "<message><greeting>hello</greeting></message>" {
+		t.Errorf("expected the message in the XML format of the route got %q", payload)
+	}
+}
@@ -204,3 +204,46 @@ func YML(w http.ResponseWriter, code int, v interface{}) (int, error) {
 	w.WriteHeader(code)
 	return w.Write(bytes)
 }
+
+// JSONAPI writes a JSON:API document to a http.ResponseWriter.
+func JSONAPI(w http.ResponseWriter, code int, pretty bool, v interface{}) (int, error) {
+	return media(w, code, pretty, "application/vnd.api+json", v)
+}
+
+// HAL writes a HAL document to a http.ResponseWriter.
+func HAL(w http.ResponseWriter, code int, pretty bool, v interface{}) (int, error) {
+	return media(w, code, pretty, "application/hal+json", v)
+}
+
+// media writes an object to a http.ResponseWriter as JSON under a JSON based
+// media type.
+func media(w http.ResponseWriter, code int, pretty bool, contentType string, v interface{}) (int, error) {
+	var bytes []byte
+	var err error
+
+	if pretty {
+		bytes, err = json.MarshalIndent(v, "", "  ")
+	} else {
+		bytes, err = json.Marshal(v)
+	}
+	if err != nil {
+		n, _ := w.Write([]byte(err.Error()))
+		return n, err
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(code)
+	num, err := w.Write(bytes)
+	if err != nil {
+		return num, err
+	}
+
+	if pretty {
+		n, err := w.Write([]byte("\n"))
+		num += n
+		if err != nil {
+			return num, err
+		}
+	}
+	return num, nil
+}
@@ -0,0 +1,85 @@
+package trace
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// WriterExporter writes spans as JSON lines, one span per line, for local use
+// such as to stdout or a file.
+//
+//	trace.DefaultTracer.Exporter = trace.NewWriterExporter(os.Stdout)
+type WriterExporter struct {
+	mu  sync.Mutex
+	w   io.Writer
+	enc *json.Encoder
+}
+
+// NewWriterExporter returns a WriterExporter that writes to w.
+func NewWriterExporter(w io.Writer) *WriterExporter {
+	return &WriterExporter{w: w, enc: json.NewEncoder(w)}
+}
+
+// NewFileExporter returns a WriterExporter that appends to the file at path,
+// creating it if needed. Close closes the file.
+func NewFileExporter(path string) (*WriterExporter, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return NewWriterExporter(f), nil
+}
+
+// record is the JSON line of a span.
+type record struct {
+	TraceID    string                 `json:"trace_id"`
+	SpanID     string                 `json:"span_id"`
+	Parent     string                 `json:"parent_id,omitempty"`
+	Name       string                 `json:"name"`
+	Service    string                 `json:"service,omitempty"`
+	Start      time.Time              `json:"start"`
+	Duration   float64                `json:"duration_ms"`
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+	Error      string                 `json:"error,omitempty"`
+}
+
+// Export satisfies the Exporter interface.
+func (e *WriterExporter) Export(s *Span) error {
+	r := record{
+		TraceID:    s.Context.TraceID.String(),
+		SpanID:     s.Context.SpanID.String(),
+		Name:       s.Name,
+		Service:    s.Service,
+		Start:      s.StartTime,
+		Duration:   float64(s.EndTime.Sub(s.StartTime)) / float64(time.Millisecond),
+		Attributes: s.Attributes,
+	}
+	if s.Parent.IsValid() {
+		r.Parent = s.Parent.String()
+	}
+	if s.Err != nil {
+		r.Error = s.Err.Error()
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.enc.Encode(r)
+}
+
+// Close closes the underlying writer if it is an io.Closer, other than
+// stdout and stderr.
+func (e *WriterExporter) Close() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.w == os.Stdout || e.w == os.Stderr {
+		return nil
+	}
+	if c, ok := e.w.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
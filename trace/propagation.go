@@ -0,0 +1,151 @@
+package trace
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// The W3C trace context headers.
+const (
+	TraceparentHeader = "traceparent"
+	TracestateHeader  = "tracestate"
+)
+
+// ErrInvalidTraceparent is returned by ParseTraceparent.
+var ErrInvalidTraceparent = errors.New("invalid traceparent")
+
+// sampledFlag is the trace flag of sampled traces.
+const sampledFlag = 0x01
+
+// Traceparent formats the span context as a version 00 traceparent header,
+// such as "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01".
+func (sc SpanContext) Traceparent() string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return fmt.Sprintf("00-%s-%s-%s", sc.TraceID, sc.SpanID, flags)
+}
+
+// ParseTraceparent parses a traceparent header. Versions after 00 are parsed
+// as 00, as the W3C recommendation asks, and version ff is invalid.
+func ParseTraceparent(s string) (SpanContext, error) {
+	var sc SpanContext
+
+	s = strings.TrimSpace(s)
+	if len(s) < 55 || (len(s) > 55 && (s[:2] == "00" || s[55] != '-')) {
+		return sc, fmt.Errorf("%w: %q", ErrInvalidTraceparent, s)
+	}
+	parts := strings.Split(s[:55], "-")
+	if len(parts) != 4 || parts[0] == "ff" || !lowerHex(s[:55]) {
+		return sc, fmt.Errorf("%w: %q", ErrInvalidTraceparent, s)
+	}
+
+	version, _ := hex.DecodeString(parts[0])
+	traceID, _ := hex.DecodeString(parts[1])
+	spanID, _ := hex.DecodeString(parts[2])
+	flags, _ := hex.DecodeString(parts[3])
+	if len(version) != 1 || len(traceID) != 16 || len(spanID) != 8 || len(flags) != 1 {
+		return sc, fmt.Errorf("%w: %q", ErrInvalidTraceparent, s)
+	}
+
+	copy(sc.TraceID[:], traceID)
+	copy(sc.SpanID[:], spanID)
+	sc.Sampled = flags[0]&sampledFlag != 0
+	if !sc.IsValid() {
+		return sc, fmt.Errorf("%w: %q has a zero id", ErrInvalidTraceparent, s)
+	}
+	return sc, nil
+}
+
+// lowerHex reports whether s holds only lowercase hex digits and dashes.
+func lowerHex(s string) bool {
+	for _, c := range s {
+		if c != '-' && (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// Extract returns the span context of the traceparent and tracestate
+// headers. ok is false if there is no valid traceparent header, in which
+// case tracestate is ignored too.
+func Extract(h http.Header) (sc SpanContext, ok bool) {
+	values := h.Values(TraceparentHeader)
+	if len(values) != 1 {
+		return sc, false
+	}
+	sc, err := ParseTraceparent(values[0])
+	if err != nil {
+		return sc, false
+	}
+	sc.State = strings.Join(h.Values(TracestateHeader), ",")
+	return sc, true
+}
+
+// Inject sets the traceparent and tracestate headers from the span context
+// held by ctx, so that the service called continues the trace. Headers are
+// left as they are if ctx holds no span context.
+func Inject(ctx context.Context, h http.Header) {
+	sc := SpanContextFrom(ctx)
+	if !sc.IsValid() {
+		return
+	}
+	h.Set(TraceparentHeader, sc.Traceparent())
+	if len(sc.State) > 0 {
+		h.Set(TracestateHeader, sc.State)
+	} else {
+		h.Del(TracestateHeader)
+	}
+}
+
+// Transport is a http.RoundTripper that wraps outgoing requests in a span
+// and injects its trace context into their headers.
+//
+//	client := &http.Client{Transport: &trace.Transport{}}
+//	req, _ := http.NewRequestWithContext(req.Context(), "GET", url, nil)
+//	resp, err := client.Do(req)
+type Transport struct {
+
+	// Base makes the requests, http.DefaultTransport if nil.
+	Base http.RoundTripper
+
+	// Tracer starts the spans, DefaultTracer if nil.
+	Tracer *Tracer
+}
+
+// RoundTrip satisfies the http.RoundTripper interface.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	tracer, base := t.Tracer, t.Base
+	if tracer == nil {
+		tracer = DefaultTracer
+	}
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	ctx, span := tracer.Start(req.Context(), "HTTP "+req.Method)
+	defer span.End()
+	span.SetAttribute("http.method", req.Method)
+	span.SetAttribute("http.url", req.URL.Redacted())
+
+	// round trippers must not change the request they are given
+	r := req.Clone(ctx)
+	Inject(ctx, r.Header)
+
+	resp, err := base.RoundTrip(r)
+	if err != nil {
+		span.SetError(err)
+		return nil, err
+	}
+	span.SetAttribute("http.status_code", resp.StatusCode)
+	if resp.StatusCode >= http.StatusInternalServerError {
+		span.SetError(errors.New(resp.Status))
+	}
+	return resp, nil
+}
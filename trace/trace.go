@@ -0,0 +1,219 @@
+// Package trace follows requests across services with spans, timed and
+// named operations that nest into a trace. Trace contexts travel between
+// services in the W3C traceparent and tracestate headers.
+//
+//	ctx, span := trace.Start(ctx, "charge card")
+//	defer span.End()
+//
+// Spans are exported once they end, if the trace is sampled, by the
+// Exporter of their Tracer.
+package trace
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"sync"
+	"time"
+)
+
+// TraceID identifies a trace.
+type TraceID [16]byte
+
+// IsValid reports whether the id is not all zeros.
+func (id TraceID) IsValid() bool {
+	return id != TraceID{}
+}
+
+// String returns the id in lowercase hex.
+func (id TraceID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// SpanID identifies a span within a trace.
+type SpanID [8]byte
+
+// IsValid reports whether the id is not all zeros.
+func (id SpanID) IsValid() bool {
+	return id != SpanID{}
+}
+
+// String returns the id in lowercase hex.
+func (id SpanID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// SpanContext is the part of a span that propagates to other services.
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Sampled bool
+
+	// State holds the vendor specific tracestate header, passed on as is.
+	State string
+}
+
+// IsValid reports whether the trace and span ids are set.
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID.IsValid() && sc.SpanID.IsValid()
+}
+
+// Span is a timed operation of a trace. Its methods may be called on a nil
+// Span, which does nothing, and are safe for concurrent use.
+type Span struct {
+	Name    string
+	Context SpanContext
+
+	// Parent is the span id of the parent span, zero for the root of a trace.
+	Parent SpanID
+
+	// Service is the Service of the Tracer the span was started by.
+	Service string
+
+	StartTime  time.Time
+	EndTime    time.Time
+	Attributes map[string]interface{}
+
+	// Err is the error the operation failed with, if it did.
+	Err error
+
+	tracer *Tracer
+	mu     sync.Mutex
+	ended  bool
+}
+
+// SetAttribute annotates the span, such as with "http.status_code".
+func (s *Span) SetAttribute(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.ended {
+		return
+	}
+	if s.Attributes == nil {
+		s.Attributes = map[string]interface{}{}
+	}
+	s.Attributes[key] = value
+}
+
+// SetError marks the span as failed with err, if err is not nil.
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.ended {
+		s.Err = err
+	}
+}
+
+// End ends the span and exports it if it is sampled. Spans end once, later
+// calls do nothing, and must not be changed after.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.EndTime = time.Now()
+	s.mu.Unlock()
+
+	if !s.Context.Sampled || s.tracer == nil || s.tracer.Exporter == nil {
+		return
+	}
+	if err := s.tracer.Exporter.Export(s); err != nil {
+		log.Printf("error exporting span %s of trace %s: %s", s.Context.SpanID, s.Context.TraceID, err)
+	}
+}
+
+// Exporter sends ended spans somewhere, such as to a file or a collector.
+// Export is called from the goroutine that ended the span, and must be safe
+// for concurrent use.
+type Exporter interface {
+	Export(s *Span) error
+}
+
+// Tracer starts spans and exports them with its Exporter.
+//
+// Traces that start at a Tracer are sampled if it has an Exporter, traces
+// that start elsewhere keep the decision of the service they came from.
+// Spans that are not sampled are still started, so their trace context
+// propagates.
+type Tracer struct {
+	Service  string
+	Exporter Exporter
+}
+
+// DefaultTracer is used by Start. It has no Exporter until one is set.
+var DefaultTracer = &Tracer{}
+
+// Start starts a span with the DefaultTracer.
+func Start(ctx context.Context, name string) (context.Context, *Span) {
+	return DefaultTracer.Start(ctx, name)
+}
+
+// Start starts a span that is a child of the span held by ctx, or of the
+// remote span context held by ctx, or else the root of a new trace. It
+// returns a copy of ctx holding the span, which the caller must End.
+func (t *Tracer) Start(ctx context.Context, name string) (context.Context, *Span) {
+	s := &Span{
+		Name:      name,
+		Service:   t.Service,
+		StartTime: time.Now(),
+		tracer:    t,
+	}
+
+	if parent := SpanContextFrom(ctx); parent.IsValid() {
+		s.Context = parent
+		s.Parent = parent.SpanID
+	} else {
+		rand.Read(s.Context.TraceID[:])
+		s.Context.Sampled = t.Exporter != nil
+	}
+	for !s.Context.SpanID.IsValid() || s.Context.SpanID == s.Parent {
+		rand.Read(s.Context.SpanID[:])
+	}
+
+	return context.WithValue(ctx, spanContextKey, s), s
+}
+
+// contextKey is the type of the keys context values are stored under.
+type contextKey int
+
+const (
+	spanContextKey contextKey = iota
+	remoteContextKey
+)
+
+// SpanFrom returns the span held by a context, or nil if there is none.
+func SpanFrom(ctx context.Context) *Span {
+	s, _ := ctx.Value(spanContextKey).(*Span)
+	return s
+}
+
+// WithRemote returns a copy of a context holding the span context of a
+// caller, such as one extracted from the headers of a request. Spans started
+// from it continue the trace of the caller.
+func WithRemote(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, remoteContextKey, sc)
+}
+
+// SpanContextFrom returns the span context of the span held by a context, or
+// else its remote span context. The result is invalid if it holds neither.
+func SpanContextFrom(ctx context.Context) SpanContext {
+	if s := SpanFrom(ctx); s != nil {
+		return s.Context
+	}
+	sc, _ := ctx.Value(remoteContextKey).(SpanContext)
+	return sc
+}
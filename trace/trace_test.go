@@ -0,0 +1,187 @@
+package trace
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// collector keeps the spans it exports.
+type collector struct {
+	mu    sync.Mutex
+	spans []*Span
+}
+
+func (c *collector) Export(s *Span) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.spans = append(c.spans, s)
+	return nil
+}
+
+func TestParseTraceparent(t *testing.T) {
+	tests := []struct {
+		header  string
+		valid   bool
+		sampled bool
+	}{{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", true, true},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00", true, false},
+		{"01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-future", true, true},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", false, false},
+		{"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", false, false},
+		{"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01", false, false},
+		{"00-00000000000000000000000000000000-00f067aa0ba902b7-01", false, false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01", false, false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7", false, false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e47-3600f067aa0ba902b7-01", false, false}}
+
+	for _, test := range tests {
+		sc, err := ParseTraceparent(test.header)
+		if test.valid != (err == nil) {
+			t.Errorf("expected %q to be valid %t got %v", test.header, test.valid, err)
+			continue
+		}
+		if err != nil {
+			if !errors.Is(err, ErrInvalidTraceparent) {
+				t.Errorf("expected ErrInvalidTraceparent got %v", err)
+			}
+			continue
+		}
+		if sc.Sampled != test.sampled || sc.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" || sc.SpanID.String() != "00f067aa0ba902b7" {
+			t.Errorf("unexpected span context %+v of %q", sc, test.header)
+		}
+	}
+}
+
+func TestPropagation(t *testing.T) {
+	in := http.Header{}
+	in.Set(TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	in.Add(TracestateHeader, "congo=t61rcWkgMzE")
+	in.Add(TracestateHeader, "rojo=00f067aa0ba902b7")
+
+	sc, ok := Extract(in)
+	if !ok || sc.State != "congo=t61rcWkgMzE,rojo=00f067aa0ba902b7" {
+		t.Fatalf("expected the span context to be extracted got %+v", sc)
+	}
+
+	ctx, span := (&Tracer{}).Start(WithRemote(context.Background(), sc), "handle")
+	out := http.Header{}
+	Inject(ctx, out)
+
+	want := "00-4bf92f3577b34da6a3ce929d0e0e4736-" + span.Context.SpanID.String() + "-01"
+	if out.Get(TraceparentHeader) != want || out.Get(TracestateHeader) != sc.State {
+		t.Errorf("expected %s got %v", want, out)
+	}
+
+	out = http.Header{}
+	Inject(context.Background(), out)
+	if len(out) > 0 {
+		t.Errorf("expected no headers without a span got %v", out)
+	}
+}
+
+func TestTracer(t *testing.T) {
+	c := &collector{}
+	tracer := &Tracer{Service: "users", Exporter: c}
+
+	ctx, root := tracer.Start(context.Background(), "GET /users/:user")
+	_, child := tracer.Start(ctx, "FindByID")
+	child.SetAttribute("model.id", "1")
+	child.SetError(errors.New("not found"))
+	child.End()
+	child.End()
+	root.End()
+
+	if len(c.spans) != 2 || c.spans[0] != child || c.spans[1] != root {
+		t.Fatalf("expected the child and root spans exported once got %v", c.spans)
+	}
+	if !root.Context.Sampled || root.Parent.IsValid() {
+		t.Errorf("expected a sampled root span got %+v", root.Context)
+	}
+	if child.Context.TraceID != root.Context.TraceID || child.Parent != root.Context.SpanID || child.Context.SpanID == root.Context.SpanID {
+		t.Errorf("expected the child to nest in the root span")
+	}
+	if child.Attributes["model.id"] != "1" || child.Err == nil || child.Service != "users" {
+		t.Errorf("unexpected child span %+v", child)
+	}
+
+	// traces are not sampled without an exporter, or when the caller did not
+	ctx = WithRemote(context.Background(), SpanContext{TraceID: root.Context.TraceID, SpanID: root.Context.SpanID})
+	_, remote := tracer.Start(ctx, "unsampled")
+	remote.End()
+	_, unexported := (&Tracer{}).Start(context.Background(), "unexported")
+	if remote.Context.Sampled || unexported.Context.Sampled || len(c.spans) != 2 {
+		t.Error("expected unsampled spans to not be exported")
+	}
+
+	var nilSpan *Span
+	nilSpan.SetAttribute("key", "value")
+	nilSpan.End()
+	if SpanFrom(context.Background()) != nil {
+		t.Error("expected no span in an empty context")
+	}
+}
+
+func TestWriterExporter(t *testing.T) {
+	var b bytes.Buffer
+	tracer := &Tracer{Service: "users", Exporter: NewWriterExporter(&b)}
+
+	ctx, root := tracer.Start(context.Background(), "root")
+	_, child := tracer.Start(ctx, "child")
+	child.SetAttribute("db.system", "mysql")
+	child.SetError(errors.New("timeout"))
+	child.End()
+	root.End()
+
+	var records []map[string]interface{}
+	dec := json.NewDecoder(&b)
+	for dec.More() {
+		var r map[string]interface{}
+		if err := dec.Decode(&r); err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, r)
+	}
+
+	if len(records) != 2 {
+		t.Fatalf("expected two lines got %d", len(records))
+	}
+	if records[0]["name"] != "child" || records[0]["parent_id"] != root.Context.SpanID.String() || records[0]["error"] != "timeout" || records[0]["service"] != "users" {
+		t.Errorf("unexpected child record %v", records[0])
+	}
+	if _, ok := records[1]["parent_id"]; ok || records[1]["trace_id"] != root.Context.TraceID.String() {
+		t.Errorf("unexpected root record %v", records[1])
+	}
+}
+
+func TestTransport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get(TraceparentHeader)))
+	}))
+	defer server.Close()
+
+	c := &collector{}
+	tracer := &Tracer{Exporter: c}
+	ctx, root := tracer.Start(context.Background(), "root")
+
+	req, _ := http.NewRequestWithContext(ctx, "GET", server.URL, nil)
+	resp, err := (&http.Client{Transport: &Transport{Tracer: tracer}}).Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var b bytes.Buffer
+	b.ReadFrom(resp.Body)
+
+	if len(c.spans) != 1 || c.spans[0].Parent != root.Context.SpanID {
+		t.Fatalf("expected a client span in the root span got %v", c.spans)
+	}
+	if b.String() != c.spans[0].Context.Traceparent() || req.Header.Get(TraceparentHeader) != "" {
+		t.Errorf("expected the client span to be injected into a copy of the request got %q", b.String())
+	}
+}
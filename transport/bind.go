@@ -0,0 +1,336 @@
+package http
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ParameterError is an invalid path, query, header or form parameter. It
+// wraps ErrInvalidParameter.
+type ParameterError struct {
+	In     string
+	Name   string
+	Reason string
+}
+
+func (e *ParameterError) Error() string {
+	return fmt.Sprintf("%s: %s %s %s", InvalidParameter, e.In, e.Name, e.Reason)
+}
+
+// Unwrap returns ErrInvalidParameter, so the error maps to its status code.
+func (e *ParameterError) Unwrap() error {
+	return ErrInvalidParameter
+}
+
+// Bind stores the path, query and header parameters of the Request in the
+// struct pointed to by v. Fields name the parameter they bind through their
+// tags:
+//
+//	type Search struct {
+//		ID     int           `path:"id"`
+//		Limit  int           `query:"limit" default:"20" validate:"min=1,max=100"`
+//		Sort   string        `query:"sort" default:"asc" validate:"oneof=asc desc"`
+//		Tags   []string      `query:"tag"`
+//		Since  time.Time     `query:"since"`
+//		Within time.Duration `query:"within"`
+//		Tenant string        `header:"X-Tenant" validate:"required"`
+//	}
+//
+// Parameters convert to strings, booleans, integers, floats, durations,
+// pointers to them, and any type that implements encoding.TextUnmarshaler,
+// such as time.Time. Slices bind repeated parameters, and query parameters
+// also bind comma separated values. Missing parameters take the value of
+// their default tag.
+//
+// The validate tag holds comma separated rules: required, min=n and max=n,
+// which limit numbers or the length of strings and slices, and oneof=a b c.
+// Embedded structs are bound as well, and once all fields are bound v is
+// validated by its Validate method, if it has one.
+//
+// Every invalid field fails with a *ParameterError, and they are returned
+// together, joined by errors.Join.
+func (r *Request) Bind(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("%w: parameters bind to struct pointers", ErrInvalidInput)
+	}
+
+	var errs []error
+	r.bind(rv.Elem(), &errs)
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+
+	if validator, ok := v.(interface{ Validate() error }); ok {
+		return validator.Validate()
+	}
+	return nil
+}
+
+// bind binds the fields of a struct, collecting their errors. The exported
+// fields of embedded structs are bound even if their type is unexported, as
+// they are promoted, while fields that can not be set are skipped.
+func (r *Request) bind(rv reflect.Value, errs *[]error) {
+	for i := 0; i < rv.NumField(); i++ {
+		field := rv.Type().Field(i)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			r.bind(rv.Field(i), errs)
+			continue
+		}
+		if !field.IsExported() || !rv.Field(i).CanSet() {
+			continue
+		}
+
+		in, name, values := r.parameter(field)
+		if len(in) == 0 {
+			continue
+		}
+		if err := bindField(rv.Field(i), field, in, name, values); err != nil {
+			*errs = append(*errs, err)
+		}
+	}
+}
+
+// parameter returns where the parameter of a field comes from, its name and
+// its values.
+func (r *Request) parameter(field reflect.StructField) (string, string, []string) {
+	if name, ok := field.Tag.Lookup("path"); ok {
+		if value := r.Param(name); len(value) > 0 {
+			return "path", name, []string{value}
+		}
+		return "path", name, nil
+	}
+	if name, ok := field.Tag.Lookup("query"); ok {
+		return "query", name, r.queries[name]
+	}
+	if name, ok := field.Tag.Lookup("header"); ok {
+		return "header", name, r.Header.Values(name)
+	}
+	return "", "", nil
+}
+
+// bindField sets and validates a field from the values of its parameter.
+func bindField(v reflect.Value, field reflect.StructField, in, name string, values []string) error {
+	if len(values) == 0 {
+		if def, ok := field.Tag.Lookup("default"); ok {
+			values = []string{def}
+		}
+	}
+
+	if len(values) > 0 {
+		if err := setValues(v, values, in == "query"); err != nil {
+			return &ParameterError{In: in, Name: name, Reason: "must be " + expected(v.Type())}
+		}
+	}
+
+	if reason := validate(v, len(values) > 0, field.Tag.Get("validate")); len(reason) > 0 {
+		return &ParameterError{In: in, Name: name, Reason: reason}
+	}
+	return nil
+}
+
+// setValues sets a field from one or more values. Slices take every value,
+// split on commas if split is set, other fields take the first.
+func setValues(v reflect.Value, values []string, split bool) error {
+	if v.Kind() != reflect.Slice || v.Type().Elem().Kind() == reflect.Uint8 {
+		return setValue(v, values[0])
+	}
+
+	parts := values
+	if split {
+		parts = nil
+		for _, value := range values {
+			for _, part := range strings.Split(value, ",") {
+				if part = strings.TrimSpace(part); len(part) > 0 {
+					parts = append(parts, part)
+				}
+			}
+		}
+	}
+
+	slice := reflect.MakeSlice(v.Type(), len(parts), len(parts))
+	for i, part := range parts {
+		if err := setValue(slice.Index(i), part); err != nil {
+			return err
+		}
+	}
+	v.Set(slice)
+	return nil
+}
+
+// durationType is the type of time.Duration, which binds from strings such
+// as "1m30s" rather than as an integer.
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// setValue converts a string to the type of a field and sets it.
+func setValue(v reflect.Value, s string) error {
+	if v.Kind() == reflect.Ptr {
+		ptr := reflect.New(v.Type().Elem())
+		if err := setValue(ptr.Elem(), s); err != nil {
+			return err
+		}
+		v.Set(ptr)
+		return nil
+	}
+
+	if v.CanAddr() {
+		if u, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+			return u.UnmarshalText([]byte(s))
+		}
+	}
+
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		i, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(i)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	default:
+		return fmt.Errorf("unsupported kind %s", v.Kind())
+	}
+	return nil
+}
+
+// expected describes the values a type binds from, for error reasons.
+func expected(t reflect.Type) string {
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice {
+		t = t.Elem()
+	}
+	if reflect.PointerTo(t).Implements(reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()) {
+		return "a valid " + strings.ToLower(t.Name())
+	}
+	if t == durationType {
+		return "a duration"
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return "an integer"
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "a positive integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	}
+	return "a " + t.Kind().String()
+}
+
+// validate checks a field against the rules of its validate tag, and returns
+// the reason it is invalid, if it is. set tells if the parameter was given or
+// had a default.
+func validate(v reflect.Value, set bool, rules string) string {
+	if len(rules) == 0 {
+		return ""
+	}
+	for v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+
+	for _, rule := range strings.Split(rules, ",") {
+		key, arg, _ := strings.Cut(strings.TrimSpace(rule), "=")
+		if key == "required" {
+			if !set {
+				return "is required"
+			}
+			continue
+		}
+		if !set {
+			continue
+		}
+
+		switch key {
+		case "min", "max":
+			limit, err := strconv.ParseFloat(arg, 64)
+			if err != nil {
+				return fmt.Sprintf("has an invalid %s rule %q", key, arg)
+			}
+			size, unit := measure(v)
+			if key == "min" && size < limit {
+				return fmt.Sprintf("must be at least %s%s", arg, unit)
+			}
+			if key == "max" && size > limit {
+				return fmt.Sprintf("must be at most %s%s", arg, unit)
+			}
+		case "oneof":
+			options := strings.Fields(arg)
+			if !oneOf(v, options) {
+				return "must be one of " + strings.Join(options, ", ")
+			}
+		}
+	}
+	return ""
+}
+
+// measure returns the size min and max rules compare, the value of numbers
+// and the length of strings and slices, with its unit.
+func measure(v reflect.Value) (float64, string) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), ""
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), ""
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), ""
+	case reflect.String:
+		return float64(len([]rune(v.String()))), " characters"
+	case reflect.Slice:
+		return float64(v.Len()), " values"
+	}
+	return 0, ""
+}
+
+// oneOf checks a value, or each value of a slice, against options.
+func oneOf(v reflect.Value, options []string) bool {
+	if v.Kind() == reflect.Slice {
+		for i := 0; i < v.Len(); i++ {
+			if !oneOf(v.Index(i), options) {
+				return false
+			}
+		}
+		return true
+	}
+
+	s := fmt.Sprint(v.Interface())
+	for _, option := range options {
+		if s == option {
+			return true
+		}
+	}
+	return false
+}
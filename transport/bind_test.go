@@ -0,0 +1,112 @@
+package http
+
+import (
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+type paging struct {
+	Limit int `query:"limit" default:"20" validate:"min=1,max=100"`
+}
+
+type search struct {
+	paging
+	ID     int           `path:"id"`
+	Sort   string        `query:"sort" default:"asc" validate:"oneof=asc desc"`
+	Tags   []string      `query:"tag"`
+	Since  *time.Time    `query:"since"`
+	Within time.Duration `query:"within"`
+	Tenant string        `header:"X-Tenant" validate:"required"`
+}
+
+func bindRequest(target string, tenant string) *Request {
+	r := httptest.NewRequest("GET", target, nil)
+	if len(tenant) > 0 {
+		r.Header.Set("X-Tenant", tenant)
+	}
+	return NewRequest(r, httprouter.Params{{Key: "id", Value: "42.json"}})
+}
+
+func TestRequestBind(t *testing.T) {
+	req := bindRequest("/search/42.json?tag=go,web&tag=api&since=2024-01-02T15:04:05Z&within=90m", "acme")
+
+	var s search
+	if err := req.Bind(&s); err != nil {
+		t.Fatal(err)
+	}
+	if s.ID != 42 || s.Limit != 20 || s.Sort != "asc" || s.Tenant != "acme" || s.Within != 90*time.Minute {
+		t.Errorf("unexpected binding %+v", s)
+	}
+	if strings.Join(s.Tags, " ") != "go web api" {
+		t.Errorf("expected repeated and comma separated tags got %v", s.Tags)
+	}
+	if s.Since == nil || s.Since.Year() != 2024 {
+		t.Errorf("expected since to be parsed got %v", s.Since)
+	}
+}
+
+// filter embeds an unexported struct that embeds another.
+type filter struct {
+	cursor
+	State string `query:"state"`
+}
+
+type cursor struct {
+	paging
+	After *time.Time `query:"after"`
+	Seen  []string   `query:"seen"`
+	skip  int        `query:"skip"`
+}
+
+func TestRequestBindEmbedded(t *testing.T) {
+	req := bindRequest("/search?limit=5&after=2024-01-02T15:04:05Z&seen=a,b&skip=3&state=open", "")
+
+	var f filter
+	if err := req.Bind(&f); err != nil {
+		t.Fatal(err)
+	}
+	if f.Limit != 5 || f.After == nil || len(f.Seen) != 2 || f.State != "open" {
+		t.Errorf("expected the promoted fields to be bound got %+v", f)
+	}
+	if f.skip != 0 {
+		t.Errorf("expected the unexported field to be skipped got %d", f.skip)
+	}
+}
+
+func TestRequestBindErrors(t *testing.T) {
+	req := bindRequest("/search/42?limit=500&sort=up&within=soon", "")
+
+	var s search
+	err := req.Bind(&s)
+	if !errors.Is(err, ErrInvalidParameter) {
+		t.Fatalf("expected %s got %v", ErrInvalidParameter, err)
+	}
+
+	expected := []string{
+		"invalid parameter: query limit must be at most 100",
+		"invalid parameter: query sort must be one of asc, desc",
+		"invalid parameter: query within must be a duration",
+		"invalid parameter: header X-Tenant is required",
+	}
+	errs := split([]error{err})
+	if len(errs) != len(expected) {
+		t.Fatalf("expected %d field errors got %v", len(expected), errs)
+	}
+	for i, e := range errs {
+		var param *ParameterError
+		if !errors.As(e, &param) || e.Error() != expected[i] {
+			t.Errorf("expected %q got %q", expected[i], e)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	NewResponse(rec, "json").WriteErrs(req, err)
+	if rec.Code != StatusBadRequest || strings.Count(rec.Body.String(), "invalid parameter") != len(expected) {
+		t.Errorf("expected each field error to be written got %d %s", rec.Code, rec.Body)
+	}
+}
@@ -0,0 +1,63 @@
+package http
+
+import "context"
+
+// contextKey is the type of the keys Request context values are stored under.
+type contextKey int
+
+const (
+	apiKeyContextKey contextKey = iota
+	principalContextKey
+	routeContextKey
+)
+
+// APIKey describes the API key a request was authorized with.
+type APIKey struct {
+	Key string
+
+	// Privileged keys may perform destructive actions, such as purging
+	// trashed items.
+	Privileged bool
+}
+
+// WithAPIKey returns a copy of a context holding an API key.
+func WithAPIKey(ctx context.Context, key APIKey) context.Context {
+	return context.WithValue(ctx, apiKeyContextKey, key)
+}
+
+// APIKeyFrom returns the API key held by a context, if there is one.
+func APIKeyFrom(ctx context.Context) (APIKey, bool) {
+	key, ok := ctx.Value(apiKeyContextKey).(APIKey)
+	return key, ok
+}
+
+// WithPrincipal returns a copy of a context holding the principal a request
+// is made by, such as an authenticated user.
+func WithPrincipal(ctx context.Context, principal interface{}) context.Context {
+	return context.WithValue(ctx, principalContextKey, principal)
+}
+
+// PrincipalFrom returns the principal held by a context. Requests authorized
+// only with an API key are made by the APIKey, and anonymous requests by nil.
+func PrincipalFrom(ctx context.Context) interface{} {
+	if principal := ctx.Value(principalContextKey); principal != nil {
+		return principal
+	}
+	if key, ok := APIKeyFrom(ctx); ok {
+		return key
+	}
+	return nil
+}
+
+// WithRoute returns a copy of a context holding the route pattern a request
+// matched, such as "/users/:user".
+func WithRoute(ctx context.Context, pattern string) context.Context {
+	return context.WithValue(ctx, routeContextKey, pattern)
+}
+
+// RouteFrom returns the route pattern held by a context, or an empty string
+// if the request was not routed.
+func RouteFrom(ctx context.Context) string {
+	pattern, _ := ctx.Value(routeContextKey).(string)
+	return pattern
+}
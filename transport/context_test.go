@@ -0,0 +1,34 @@
+package http
+
+import (
+	"context"
+	"testing"
+)
+
+func TestAPIKey(t *testing.T) {
+	if _, ok := APIKeyFrom(context.Background()); ok {
+		t.Error("expected no API key")
+	}
+
+	ctx := WithAPIKey(context.Background(), APIKey{Key: "secret", Privileged: true})
+	key, ok := APIKeyFrom(ctx)
+	if !ok || key.Key != "secret" || !key.Privileged {
+		t.Errorf("expected the privileged key 'secret' got %+v", key)
+	}
+}
+
+func TestPrincipal(t *testing.T) {
+	if principal := PrincipalFrom(context.Background()); principal != nil {
+		t.Errorf("expected no principal got %v", principal)
+	}
+
+	ctx := WithAPIKey(context.Background(), APIKey{Key: "secret"})
+	if principal, ok := PrincipalFrom(ctx).(APIKey); !ok || principal.Key != "secret" {
+		t.Errorf("expected the API key as principal got %v", PrincipalFrom(ctx))
+	}
+
+	ctx = WithPrincipal(ctx, "gopher")
+	if principal := PrincipalFrom(ctx); principal != "gopher" {
+		t.Errorf("expected principal 'gopher' got %v", principal)
+	}
+}
@@ -0,0 +1,119 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Decoder decodes JSON request bodies.
+type Decoder struct {
+
+	// AllowUnknownFields accepts object keys that have no field in the value
+	// decoded into. Unknown fields fail with ErrInvalidJSON otherwise.
+	AllowUnknownFields bool
+
+	// MaxDepth limits how deeply objects and arrays may nest. Deeper bodies
+	// fail with ErrInvalidJSON before they are decoded. Zero means no limit.
+	MaxDepth int
+}
+
+// DefaultDecoder is used by Request.Unmarshal.
+var DefaultDecoder = &Decoder{MaxDepth: 32}
+
+// Decode parses JSON-encoded data and stores the result in the value pointed
+// to by v. Errors wrap ErrInvalidJSON and tell the line and column where
+// decoding failed.
+func (d *Decoder) Decode(data []byte, v interface{}) error {
+	if d.MaxDepth > 0 {
+		if offset := depth(data, d.MaxDepth); offset > -1 {
+			return fmt.Errorf("%w: %s: nested deeper than %d", ErrInvalidJSON, position(data, int64(offset)), d.MaxDepth)
+		}
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if !d.AllowUnknownFields {
+		dec.DisallowUnknownFields()
+	}
+
+	if err := dec.Decode(v); err != nil {
+		if err == io.EOF {
+			return fmt.Errorf("%w: empty body", ErrInvalidJSON)
+		}
+
+		offset := dec.InputOffset()
+		var syntax *json.SyntaxError
+		var typ *json.UnmarshalTypeError
+		switch {
+		case errors.As(err, &syntax):
+			offset = syntax.Offset
+		case errors.As(err, &typ):
+			offset = typ.Offset
+		}
+		return fmt.Errorf("%w: %s: %s", ErrInvalidJSON, position(data, offset), strings.TrimPrefix(err.Error(), "json: "))
+	}
+
+	if _, err := dec.Token(); err != io.EOF {
+		return fmt.Errorf("%w: %s: unexpected data after the value", ErrInvalidJSON, position(data, dec.InputOffset()))
+	}
+	return nil
+}
+
+// depth returns the offset where data nests objects and arrays deeper than
+// max, or -1 if it does not.
+func depth(data []byte, max int) int {
+	var (
+		level   int
+		str     bool
+		escaped bool
+	)
+
+	for i, c := range data {
+		switch {
+		case escaped:
+			escaped = false
+		case str:
+			switch c {
+			case '\\':
+				escaped = true
+			case '"':
+				str = false
+			}
+		case c == '"':
+			str = true
+		case c == '{' || c == '[':
+			level++
+			if level > max {
+				return i
+			}
+		case c == '}' || c == ']':
+			level--
+		}
+	}
+	return -1
+}
+
+// position turns an offset into data into a line and column.
+func position(data []byte, offset int64) string {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	before := data[:offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+	column := len(before) - bytes.LastIndexByte(before, '\n')
+	return fmt.Sprintf("line %d column %d", line, column)
+}
+
+// bodyError reports a body read past the limit of a http.MaxBytesReader as
+// ErrLargeBody.
+func bodyError(err error) error {
+	var large *http.MaxBytesError
+	if errors.As(err, &large) {
+		return fmt.Errorf("%w: over %d bytes", ErrLargeBody, large.Limit)
+	}
+	return err
+}
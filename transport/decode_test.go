@@ -0,0 +1,84 @@
+package http
+
+import (
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDecoderDecode(t *testing.T) {
+	type person struct {
+		Name    string            `json:"name"`
+		Age     int               `json:"age"`
+		Tags    interface{}       `json:"tags"`
+		Details map[string]string `json:"details"`
+	}
+
+	tests := []struct {
+		name string
+		body string
+		err  string
+	}{
+		{"valid", `{"name":"gopher","age":13}`, ""},
+		{"unknown field", `{"name":"gopher","admin":true}`, `line 1 column 31: unknown field "admin"`},
+		{"wrong type", "{\n  \"age\": \"old\"\n}", "line 2 column 15: cannot unmarshal"},
+		{"syntax", "{\n  \"name\": gopher\n}", "line 2 column 12: invalid character"},
+		{"too deep", `{"tags":[[[[1]]]]}`, "line 1 column 12: nested deeper than 4"},
+		{"brackets in strings", `{"name":"[[[[[[","tags":[1]}`, ""},
+		{"trailing data", `{"name":"gopher"} {}`, "unexpected data after the value"},
+		{"empty", ``, "empty body"},
+	}
+
+	d := &Decoder{MaxDepth: 4}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var p person
+			err := d.Decode([]byte(tt.body), &p)
+			if len(tt.err) == 0 {
+				if err != nil {
+					t.Errorf("unexpected error %s", err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrInvalidJSON) || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("expected %s error with %q got %v", ErrInvalidJSON, tt.err, err)
+			}
+		})
+	}
+
+	d.AllowUnknownFields = true
+	var p person
+	if err := d.Decode([]byte(`{"name":"gopher","admin":true}`), &p); err != nil {
+		t.Errorf("expected unknown fields to be allowed got %s", err)
+	}
+}
+
+func TestRequestBodyLimit(t *testing.T) {
+	r := httptest.NewRequest("POST", "/", strings.NewReader(`{"name":"gopher"}`))
+	req := NewRequest(r, nil)
+	req.SetBodyLimit(8)
+	if _, err := req.Bytes(); !errors.Is(err, ErrLargeBody) {
+		t.Errorf("expected %s for a declared length got %v", ErrLargeBody, err)
+	}
+
+	// chunked bodies do not declare their length
+	r = httptest.NewRequest("POST", "/", strings.NewReader(`{"name":"gopher"}`))
+	r.ContentLength = -1
+	req = NewRequest(r, nil)
+	req.SetBodyLimit(8)
+	if _, err := req.Bytes(); !errors.Is(err, ErrLargeBody) {
+		t.Errorf("expected %s for a read past the limit got %v", ErrLargeBody, err)
+	}
+	if code, _ := StatusCode(ErrLargeBody); code != StatusRequestEntityTooLarge {
+		t.Errorf("expected status 413 got %d", code)
+	}
+
+	r = httptest.NewRequest("POST", "/", strings.NewReader(`{"name":"gopher"}`))
+	req = NewRequest(r, nil)
+	req.SetBodyLimit(8)
+	req.SetBodyLimit(64)
+	if _, err := req.Bytes(); err != nil {
+		t.Errorf("expected a later limit to replace the first got %s", err)
+	}
+}
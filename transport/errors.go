@@ -1,6 +1,11 @@
 package http
 
-import "errors"
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+)
 
 // Error values should consist of two lowercased words to keep API errors
 // responses short and sweet.
@@ -21,9 +26,13 @@ const (
 	InvalidPermission   = "invalid permission"
 	InvalidRegistration = "invalid registration"
 	InvalidSession      = "invalid session"
+	InvalidUpgrade      = "invalid upgrade"
 	InvalidUser         = "invalid user"
+	LargeBody           = "large body"
+	LargeFile           = "large file"
 	MissingSession      = "missing session"
 	MissingUser         = "missing user"
+	RequestTimeout      = "request timeout"
 )
 
 var (
@@ -43,9 +52,13 @@ var (
 	ErrInvalidPermission   = errors.New(InvalidPermission)
 	ErrInvalidRegistration = errors.New(InvalidRegistration)
 	ErrInvalidSession      = errors.New(InvalidSession)
+	ErrInvalidUpgrade      = errors.New(InvalidUpgrade)
 	ErrInvalidUser         = errors.New(InvalidUser)
+	ErrLargeBody           = errors.New(LargeBody)
+	ErrLargeFile           = errors.New(LargeFile)
 	ErrMissingSession      = errors.New(MissingSession)
 	ErrMissingUser         = errors.New(MissingUser)
+	ErrRequestTimeout      = errors.New(RequestTimeout)
 )
 
 // ErrorMap is a map of error messages to HTTP status codes.
@@ -69,9 +82,16 @@ var ErrorMap = map[error]int{
 	ErrInvalidPermission:   StatusForbidden,
 	ErrInvalidRegistration: StatusBadRequest,
 	ErrInvalidSession:      StatusBadRequest,
+	ErrInvalidUpgrade:      StatusBadRequest,
 	ErrInvalidUser:         StatusBadRequest,
+	ErrLargeBody:           StatusRequestEntityTooLarge,
+	ErrLargeFile:           StatusRequestEntityTooLarge,
 	ErrMissingSession:      StatusBadRequest,
 	ErrMissingUser:         StatusBadRequest,
+	ErrRequestTimeout:      StatusServiceUnavailable,
+
+	// deadlines of model and database calls
+	context.DeadlineExceeded: StatusGatewayTimeout,
 }
 
 // ApplyErrorCode inserts an error key and status code value into the ErrorMap.
@@ -83,3 +103,54 @@ func ApplyErrorCode(err error, statusCode int) bool {
 	ErrorMap[err] = statusCode
 	return true
 }
+
+// StatusCode returns the HTTP status code the ErrorMap holds for an error.
+// Wrapped errors match the status code of the errors they wrap, searched in
+// order from the outermost, so an error wrapping both ErrRequestTimeout and
+// context.DeadlineExceeded always matches the one it wraps first.
+func StatusCode(err error) (int, bool) {
+	if code, ok := wrappedCode(err); ok {
+		return code, true
+	}
+
+	// errors that match others through an Is method, in order of message
+	var matched []error
+	for e := range ErrorMap {
+		if errors.Is(err, e) {
+			matched = append(matched, e)
+		}
+	}
+	if len(matched) == 0 {
+		return 0, false
+	}
+	sort.Slice(matched, func(i, j int) bool {
+		return matched[i].Error() < matched[j].Error()
+	})
+	return ErrorMap[matched[0]], true
+}
+
+// wrappedCode returns the status code of the first error of the ErrorMap in
+// the chain of errors err wraps, depth first.
+func wrappedCode(err error) (int, bool) {
+	for err != nil {
+		if reflect.TypeOf(err).Comparable() {
+			if code, ok := ErrorMap[err]; ok {
+				return code, true
+			}
+		}
+		switch e := err.(type) {
+		case interface{ Unwrap() error }:
+			err = e.Unwrap()
+		case interface{ Unwrap() []error }:
+			for _, err := range e.Unwrap() {
+				if code, ok := wrappedCode(err); ok {
+					return code, true
+				}
+			}
+			return 0, false
+		default:
+			return 0, false
+		}
+	}
+	return 0, false
+}
@@ -1,7 +1,9 @@
 package http
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"testing"
 )
 
@@ -17,3 +19,38 @@ func TestApplyErrorCode(t *testing.T) {
 		t.Errorf("expected invalidError: %s as %d to fail", validError, StatusBadRequest)
 	}
 }
+
+func TestStatusCode(t *testing.T) {
+	if code, ok := StatusCode(ErrInvalidParameter); !ok || code != StatusBadRequest {
+		t.Errorf("expected %s as %d got %d", ErrInvalidParameter, StatusBadRequest, code)
+	}
+
+	wrapped := fmt.Errorf("%w: unknown field name", ErrInvalidParameter)
+	if code, ok := StatusCode(wrapped); !ok || code != StatusBadRequest {
+		t.Errorf("expected %s as %d got %d", wrapped, StatusBadRequest, code)
+	}
+
+	if _, ok := StatusCode(errors.New("unmapped error")); ok {
+		t.Error("expected an unmapped error to have no status code")
+	}
+}
+
+func TestStatusCodeOrder(t *testing.T) {
+	tests := []struct {
+		err  error
+		code int
+	}{
+		{fmt.Errorf("%w: %w", ErrRequestTimeout, context.DeadlineExceeded), StatusServiceUnavailable},
+		{fmt.Errorf("%w: %w", context.DeadlineExceeded, ErrRequestTimeout), StatusGatewayTimeout},
+		{fmt.Errorf("%w: no response", fmt.Errorf("%w: %v", ErrRequestTimeout, context.DeadlineExceeded)), StatusServiceUnavailable},
+		{errors.Join(ErrInvalidPermission, ErrInvalidID), StatusForbidden},
+	}
+	for _, test := range tests {
+		// the map is ranged in a random order, so try more than once
+		for i := 0; i < 20; i++ {
+			if code, ok := StatusCode(test.err); !ok || code != test.code {
+				t.Fatalf("expected %s as %d got %d", test.err, test.code, code)
+			}
+		}
+	}
+}
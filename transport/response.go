@@ -29,9 +29,18 @@ type ResponseWriter interface {
 	WriteXML(prettyprint bool, v interface{})
 	WriteJSON(prettyprint bool, v interface{})
 	WriteJSONP(prettyprint bool, callback string, v interface{})
+	WriteJSONAPI(prettyprint bool, v interface{})
+	WriteHAL(prettyprint bool, v interface{})
 	WriteErrs(req *Request, errs ...error)
+	Format() string
 }
 
+// Hypermedia types a Request can ask for through its Accept header.
+const (
+	MediaJSONAPI = "application/vnd.api+json"
+	MediaHAL     = "application/hal+json"
+)
+
 // Response describes a HTTP response object.
 type Response struct {
 	code   int
@@ -46,6 +55,40 @@ func NewResponse(w http.ResponseWriter, format string) *Response {
 	return &Response{ResponseWriter: w, code: http.StatusOK, list: false, format: format}
 }
 
+// WrapResponse returns a ResponseWriter that writes through w, for middleware
+// that wraps the http.ResponseWriter of a response, such as to record the
+// status it writes. A *Response is copied with the status, body and format
+// set on it, so wrapping it does not lose them. Other ResponseWriters get a
+// new Response of their format.
+func WrapResponse(resp ResponseWriter, w http.ResponseWriter) ResponseWriter {
+	r, ok := resp.(*Response)
+	if !ok {
+		return NewResponse(w, resp.Format())
+	}
+	wrapped := *r
+	wrapped.bytes = append([]byte(nil), r.bytes...)
+	wrapped.ResponseWriter = w
+	return &wrapped
+}
+
+// Flush sends any data written so far to the client, if the http.ResponseWriter
+// the Response wraps supports it. It satisfies the http.Flusher interface.
+func (r *Response) Flush() {
+	r.FlushError()
+}
+
+// FlushError flushes like Flush, but returns an error if the
+// http.ResponseWriter the Response wraps does not support flushing.
+func (r *Response) FlushError() error {
+	return http.NewResponseController(r.ResponseWriter).Flush()
+}
+
+// Unwrap returns the http.ResponseWriter the Response wraps, for
+// http.ResponseController.
+func (r *Response) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
 // Raw sends a HTTP response.
 //
 // Raw first looks for bytes its has read, then sends them.
@@ -93,6 +136,9 @@ func (r *Response) WriteFormatList(req *Request, v interface{}) {
 	r.WriteFormat(req, v)
 }
 
+// Format returns the format requested through the URL type extension.
+func (r *Response) Format() string { return r.format }
+
 // Status sets the status code for the HTTP response.
 func (r *Response) Status(code int) { r.code = code }
 
@@ -156,6 +202,16 @@ func (r *Response) WriteJSONP(prettyprint bool, callback string, v interface{})
 	render.JSONP(r, r.code, prettyprint, callback, v)
 }
 
+// WriteJSONAPI takes a JSON:API document and writes it as a HTTP response.
+func (r *Response) WriteJSONAPI(prettyprint bool, v interface{}) {
+	render.JSONAPI(r, r.code, prettyprint, v)
+}
+
+// WriteHAL takes a HAL document and writes it as a HTTP response.
+func (r *Response) WriteHAL(prettyprint bool, v interface{}) {
+	render.HAL(r, r.code, prettyprint, v)
+}
+
 // WriteErrs takes many errors.
 //
 // If the code is nil, the error string will attempt to match with its code
@@ -166,17 +222,21 @@ func (r *Response) WriteJSONP(prettyprint bool, callback string, v interface{})
 //
 // If nothing is found and the Response Status is 200, then the HTTP Response
 // code will default to 500.
+//
+// Errors joined with errors.Join, such as those of Request.Bind, are written
+// as separate errors.
 func (r *Response) WriteErrs(req *Request, errs ...error) {
 	var body struct {
 		Status string   `json:"status,omitempty"`
 		Errs   []string `json:"errors,omitempty"`
 	}
+	errs = split(errs)
 
 	// iterate over errors
 	for i, err := range errs {
 		// first error
 		if i == 0 {
-			code, ok := ErrorMap[err]
+			code, ok := StatusCode(err)
 			if !ok {
 				// code is not 200, use Status
 				if r.code != http.StatusOK {
@@ -193,3 +253,16 @@ func (r *Response) WriteErrs(req *Request, errs ...error) {
 
 	r.WriteFormat(req, body)
 }
+
+// split expands joined errors into the errors they join.
+func split(errs []error) []error {
+	var out []error
+	for _, err := range errs {
+		if joined, ok := err.(interface{ Unwrap() []error }); ok {
+			out = append(out, split(joined.Unwrap())...)
+			continue
+		}
+		out = append(out, err)
+	}
+	return out
+}
@@ -16,6 +16,29 @@ func TestNewResponse(t *testing.T) {
 	}
 }
 
+func TestWrapResponse(t *testing.T) {
+	outer := httptest.NewRecorder()
+	resp := NewResponse(outer, "xml")
+	resp.Status(http.StatusCreated)
+	resp.Read([]byte("hello world"))
+
+	inner := httptest.NewRecorder()
+	wrapped := WrapResponse(resp, inner)
+	if wrapped.Format() != "xml" {
+		t.Errorf("expected format xml got %s", wrapped.Format())
+	}
+	if string(wrapped.Bytes()) != "hello world" {
+		t.Errorf("expected the body of the response got %s", wrapped.Bytes())
+	}
+	wrapped.WriteJSON(false, "created")
+	if inner.Code != http.StatusCreated {
+		t.Errorf("expected the status of the response got %d", inner.Code)
+	}
+	if outer.Body.Len() > 0 {
+		t.Error("expected the wrapped response to write through its writer")
+	}
+}
+
 func TestResponseRaw(t *testing.T) {
 	rec := httptest.NewRecorder()
 	resp := NewResponse(rec, "json")
@@ -173,3 +196,21 @@ func TestResponseHeader(t *testing.T) {
 		t.Errorf("Response.Header failed: expected header Content-Type %s but got %s.", "xyz", rec.HeaderMap["Content-Type"][0])
 	}
 }
+
+func TestResponseJSONAPI(t *testing.T) {
+	rec := httptest.NewRecorder()
+	resp := NewResponse(rec, "json")
+	resp.WriteJSONAPI(prettyprint, map[string]string{"data": "hello world"})
+	if ct := rec.Header().Get("Content-Type"); ct != MediaJSONAPI {
+		t.Errorf("expected Content-Type %s got %s", MediaJSONAPI, ct)
+	}
+}
+
+func TestResponseHAL(t *testing.T) {
+	rec := httptest.NewRecorder()
+	resp := NewResponse(rec, "json")
+	resp.WriteHAL(prettyprint, map[string]string{"name": "hello world"})
+	if ct := rec.Header().Get("Content-Type"); ct != MediaHAL {
+		t.Errorf("expected Content-Type %s got %s", MediaHAL, ct)
+	}
+}
@@ -0,0 +1,191 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MediaEventStream is the media type of Server-Sent Events.
+const MediaEventStream = "text/event-stream"
+
+// ErrClosedStream is returned for events sent after the client of an
+// EventStream went away, or after the stream was closed.
+var ErrClosedStream = errors.New("closed stream")
+
+// Event is a Server-Sent Event. Empty fields are left out, and data spanning
+// several lines is sent as a data field per line.
+type Event struct {
+	ID    string
+	Event string
+	Data  string
+
+	// Retry asks the client to wait this long before reconnecting.
+	Retry time.Duration
+}
+
+// EventStream writes Server-Sent Events. Events are written to the client as
+// they are sent, bypassing the buffered body of a Response.
+//
+// Events are flushed through http.ResponseController, so a ResponseWriter
+// that wraps another, such as in middleware, must implement Flush or
+// Unwrap() http.ResponseWriter for streams to work through it.
+type EventStream struct {
+	w      http.ResponseWriter
+	rc     *http.ResponseController
+	ctx    context.Context
+	cancel context.CancelFunc
+	lastID string
+
+	// mu serializes writes of events and heartbeats
+	mu  sync.Mutex
+	err error
+}
+
+// NewEventStream starts a Server-Sent Events response. The stream is done
+// once the client disconnects, which also cancels its context.
+func NewEventStream(resp ResponseWriter, req *Request) (*EventStream, error) {
+	rc := http.NewResponseController(resp)
+
+	h := resp.Header()
+	h.Set("Content-Type", MediaEventStream)
+	h.Set("Cache-Control", "no-cache")
+	h.Set("Connection", "keep-alive")
+	h.Set("X-Accel-Buffering", "no") // disable proxy buffering, such as nginx
+	resp.WriteHeader(http.StatusOK)
+
+	if err := rc.Flush(); err != nil {
+		return nil, fmt.Errorf("http: event streams require a response that flushes: %w", err)
+	}
+
+	// streams outlive the write timeout of the server
+	rc.SetWriteDeadline(time.Time{})
+
+	lastID := req.Header.Get("Last-Event-ID")
+	if len(lastID) == 0 {
+		// EventSource polyfills send the id as a query parameter
+		lastID = req.URL.Query().Get("lastEventId")
+	}
+
+	ctx, cancel := context.WithCancel(req.Context())
+	return &EventStream{w: resp, rc: rc, ctx: ctx, cancel: cancel, lastID: lastID}, nil
+}
+
+// LastEventID returns the id of the last event a reconnecting client
+// received, so that the events after it can be sent again.
+func (s *EventStream) LastEventID() string {
+	return s.lastID
+}
+
+// Context returns a context that is done once the client disconnects or the
+// stream is closed.
+func (s *EventStream) Context() context.Context {
+	return s.ctx
+}
+
+// Done returns a channel that is closed once the client disconnects or the
+// stream is closed.
+func (s *EventStream) Done() <-chan struct{} {
+	return s.ctx.Done()
+}
+
+// Send writes an event and flushes it to the client.
+func (s *EventStream) Send(e Event) error {
+	var b strings.Builder
+	if len(e.ID) > 0 {
+		b.WriteString("id: " + field(e.ID) + "\n")
+	}
+	if len(e.Event) > 0 {
+		b.WriteString("event: " + field(e.Event) + "\n")
+	}
+	if e.Retry > 0 {
+		b.WriteString("retry: " + strconv.FormatInt(e.Retry.Milliseconds(), 10) + "\n")
+	}
+	if len(e.Data) > 0 {
+		data := strings.ReplaceAll(e.Data, "\r\n", "\n")
+		for _, line := range strings.Split(data, "\n") {
+			b.WriteString("data: " + line + "\n")
+		}
+	}
+	b.WriteString("\n")
+	return s.write(b.String())
+}
+
+// Comment writes a comment, which clients ignore. Comments keep idle
+// connections from being closed by proxies.
+func (s *EventStream) Comment(text string) error {
+	var b strings.Builder
+	for _, line := range strings.Split(text, "\n") {
+		b.WriteString(": " + line + "\n")
+	}
+	b.WriteString("\n")
+	return s.write(b.String())
+}
+
+// Heartbeat sends a comment every interval until the stream is done.
+func (s *EventStream) Heartbeat(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-s.ctx.Done():
+				return
+			case <-ticker.C:
+				if err := s.Comment("heartbeat"); err != nil {
+					return
+				}
+			}
+		}
+	}()
+}
+
+// Close ends the stream, stopping heartbeats. Handlers return after closing
+// the stream so the response ends.
+func (s *EventStream) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.err == nil {
+		s.err = ErrClosedStream
+	}
+	s.cancel()
+}
+
+// write writes and flushes a chunk of the stream. Failed writes mean the
+// client went away, so the stream is done.
+func (s *EventStream) write(chunk string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.err != nil {
+		return s.err
+	}
+	if s.ctx.Err() != nil {
+		s.err = ErrClosedStream
+		return s.err
+	}
+
+	if _, err := s.w.Write([]byte(chunk)); err != nil {
+		s.err = fmt.Errorf("%w: %s", ErrClosedStream, err)
+		s.cancel()
+		return s.err
+	}
+	if err := s.rc.Flush(); err != nil {
+		s.err = fmt.Errorf("%w: %s", ErrClosedStream, err)
+		s.cancel()
+		return s.err
+	}
+	return nil
+}
+
+// field removes line breaks from a single line field.
+func field(v string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(v)
+}
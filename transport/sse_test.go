@@ -0,0 +1,97 @@
+package http
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// unflushable is a http.ResponseWriter that cannot flush.
+type unflushable struct {
+	http.ResponseWriter
+}
+
+func TestEventStream(t *testing.T) {
+	rec := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/events", nil)
+	r.Header.Set("Last-Event-ID", "41")
+
+	s, err := NewEventStream(NewResponse(rec, "json"), NewRequest(r, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id := s.LastEventID(); id != "41" {
+		t.Errorf("expected last event id 41 got %s", id)
+	}
+
+	s.Send(Event{ID: "42", Event: "update", Data: "line one\nline two", Retry: 3 * time.Second})
+	s.Comment("heartbeat")
+	s.Close()
+
+	expected := "id: 42\nevent: update\nretry: 3000\ndata: line one\ndata: line two\n\n: heartbeat\n\n"
+	if body := rec.Body.String(); body != expected {
+		t.Errorf("expected %q got %q", expected, body)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != MediaEventStream {
+		t.Errorf("expected Content-Type %s got %s", MediaEventStream, ct)
+	}
+	if !rec.Flushed {
+		t.Error("expected events to be flushed")
+	}
+	if err := s.Send(Event{Data: "late"}); !errors.Is(err, ErrClosedStream) {
+		t.Errorf("expected %s got %v", ErrClosedStream, err)
+	}
+}
+
+func TestEventStreamUnflushable(t *testing.T) {
+	r := httptest.NewRequest("GET", "/events", nil)
+	resp := NewResponse(unflushable{httptest.NewRecorder()}, "json")
+	if _, err := NewEventStream(resp, NewRequest(r, nil)); err == nil {
+		t.Error("expected an error for a response that cannot flush")
+	}
+}
+
+func TestEventStreamDisconnect(t *testing.T) {
+	done := make(chan error, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s, err := NewEventStream(NewResponse(w, "json"), NewRequest(r, nil))
+		if err != nil {
+			done <- err
+			return
+		}
+		s.Heartbeat(5 * time.Millisecond)
+		s.Send(Event{Data: "hello"})
+
+		<-s.Done()
+		done <- s.Context().Err()
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req, _ := http.NewRequestWithContext(ctx, "GET", server.URL, nil)
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	line, _ := bufio.NewReader(res.Body).ReadString('\n')
+	if !strings.HasPrefix(line, "data: hello") {
+		t.Errorf("expected the first event got %q", line)
+	}
+	cancel()
+	res.Body.Close()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("expected the stream to be cancelled got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Error("expected the disconnect to be detected")
+	}
+}
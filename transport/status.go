@@ -12,6 +12,7 @@ const (
 	StatusNoContent            = 204
 	StatusResetContent         = 205
 	StatusPartialContent       = 206
+	StatusMultiStatus          = 207 // RFC 4918
 
 	StatusMultipleChoices   = 300
 	StatusMovedPermanently  = 301
@@ -40,6 +41,7 @@ const (
 	StatusRequestedRangeNotSatisfiable = 416
 	StatusExpectationFailed            = 417
 	StatusTeapot                       = 418
+	StatusFailedDependency             = 424 // RFC 4918
 
 	StatusInternalServerError     = 500
 	StatusNotImplemented          = 501
@@ -67,6 +69,7 @@ var statusText = map[int]string{
 	StatusNoContent:            "No Content",
 	StatusResetContent:         "Reset Content",
 	StatusPartialContent:       "Partial Content",
+	StatusMultiStatus:          "Multi-Status",
 
 	StatusMultipleChoices:   "Multiple Choices",
 	StatusMovedPermanently:  "Moved Permanently",
@@ -95,6 +98,7 @@ var statusText = map[int]string{
 	StatusRequestedRangeNotSatisfiable: "Requested Range Not Satisfiable",
 	StatusExpectationFailed:            "Expectation Failed",
 	StatusTeapot:                       "I'm a teapot",
+	StatusFailedDependency:             "Failed Dependency",
 
 	StatusInternalServerError:     "Internal Server Error",
 	StatusNotImplemented:          "Not Implemented",
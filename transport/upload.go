@@ -0,0 +1,409 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"strings"
+)
+
+// MediaForm and MediaMultipart are the media types of HTML form bodies.
+const (
+	MediaForm      = "application/x-www-form-urlencoded"
+	MediaMultipart = "multipart/form-data"
+)
+
+// sniffLen is the number of bytes http.DetectContentType considers.
+const sniffLen = 512
+
+// File is an uploaded file, saved to the FileStore of its Uploader. Files
+// marshal to their metadata, so models can keep them in fields next to their
+// other properties.
+type File struct {
+	Field    string `json:"-"`
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Size     int64  `json:"size"`
+	Location string `json:"location"`
+
+	store FileStore
+}
+
+// Open opens the saved content of the file.
+func (f *File) Open(ctx context.Context) (io.ReadCloser, error) {
+	if f.store == nil {
+		return nil, fmt.Errorf("%w: %s is not stored", ErrInvalidFile, f.Name)
+	}
+	return f.store.Open(ctx, f.Location)
+}
+
+// Remove deletes the saved content of the file.
+func (f *File) Remove(ctx context.Context) error {
+	if f.store == nil {
+		return nil
+	}
+	return f.store.Remove(ctx, f.Location)
+}
+
+// FileStore saves uploaded files as they stream in, such as to disk or to an
+// object store.
+type FileStore interface {
+
+	// Save reads a file until EOF and returns the location it was saved to.
+	Save(ctx context.Context, name string, r io.Reader) (string, error)
+
+	// Open opens the file saved to a location.
+	Open(ctx context.Context, location string) (io.ReadCloser, error)
+
+	// Remove deletes the file saved to a location.
+	Remove(ctx context.Context, location string) error
+}
+
+// TempStore saves files to temporary files in Dir, or the default directory
+// for temporary files if Dir is empty. Locations are file paths.
+type TempStore struct {
+	Dir string
+}
+
+// Save satisfies the FileStore interface.
+func (s TempStore) Save(ctx context.Context, name string, r io.Reader) (string, error) {
+	f, err := os.CreateTemp(s.Dir, "upload-*"+filepath.Ext(name))
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), f.Close()
+}
+
+// Open satisfies the FileStore interface.
+func (s TempStore) Open(ctx context.Context, location string) (io.ReadCloser, error) {
+	return os.Open(location)
+}
+
+// Remove satisfies the FileStore interface.
+func (s TempStore) Remove(ctx context.Context, location string) error {
+	if err := os.Remove(location); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// Uploader parses form bodies, streaming their files to a FileStore.
+type Uploader struct {
+
+	// MaxFileSize limits the size of each file, in bytes.
+	MaxFileSize int64
+
+	// MaxSize limits the size of the whole body, in bytes.
+	MaxSize int64
+
+	// Types lists the media types files may have, such as "image/png", or
+	// "image/*" for all images. Types are sniffed from the content of the
+	// files, not taken from the client. All types are allowed if empty.
+	Types []string
+
+	// Store saves the files, TempStore{} is used if nil.
+	Store FileStore
+}
+
+// DefaultUploader is used by Request.Upload and Request.Unmarshal.
+var DefaultUploader = &Uploader{MaxFileSize: 10 << 20, MaxSize: 32 << 20}
+
+// Upload is a parsed form body.
+//
+// Parts of a multipart body with the application/json media type, files or
+// not, hold metadata that Bind unmarshals before the form values and files.
+type Upload struct {
+	Values   url.Values
+	Files    map[string][]*File
+	Metadata json.RawMessage
+}
+
+// Value returns the first value of a form field.
+func (u *Upload) Value(name string) string {
+	return u.Values.Get(name)
+}
+
+// File returns the first file of a form field, or nil if there is none.
+func (u *Upload) File(name string) *File {
+	if files := u.Files[name]; len(files) > 0 {
+		return files[0]
+	}
+	return nil
+}
+
+// Remove deletes the saved content of all files, such as when the model they
+// were uploaded for fails to save.
+func (u *Upload) Remove(ctx context.Context) error {
+	var errs []error
+	for _, files := range u.Files {
+		for _, f := range files {
+			if err := f.Remove(ctx); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Bind stores the upload in the struct pointed to by v. Metadata is
+// unmarshaled first, then form values and files are set on the fields they
+// are named after. Fields are named by their form tag, then their json tag,
+// then their name. Files bind to fields of type *File or []*File, and values
+// to the same types Request.Bind supports. Slices bind repeated values as
+// they are, since form values are free text, and commas are not split.
+func (u *Upload) Bind(v interface{}) error {
+	if len(u.Metadata) > 0 {
+		if err := DefaultDecoder.Decode(u.Metadata, v); err != nil {
+			return err
+		}
+	}
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("%w: forms bind to struct pointers", ErrInvalidInput)
+	}
+	rv = rv.Elem()
+
+	fileType := reflect.TypeOf(&File{})
+	for i := 0; i < rv.NumField(); i++ {
+		field := rv.Type().Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name := formName(field)
+		if name == "-" {
+			continue
+		}
+
+		switch {
+		case field.Type == fileType:
+			if f := u.File(name); f != nil {
+				rv.Field(i).Set(reflect.ValueOf(f))
+			}
+		case field.Type == reflect.SliceOf(fileType):
+			if files := u.Files[name]; len(files) > 0 {
+				rv.Field(i).Set(reflect.ValueOf(files))
+			}
+		default:
+			values, ok := u.Values[name]
+			if !ok || len(values) == 0 {
+				continue
+			}
+			if err := setValues(rv.Field(i), values, false); err != nil {
+				return &ParameterError{In: "form", Name: name, Reason: "must be " + expected(field.Type)}
+			}
+		}
+	}
+	return nil
+}
+
+// Upload parses a multipart or urlencoded form body with the
+// DefaultUploader. The upload is parsed once, later calls return it again.
+func (r *Request) Upload() (*Upload, error) {
+	if r.upload == nil {
+		u, err := DefaultUploader.Parse(r)
+		if err != nil {
+			return nil, err
+		}
+		r.upload = u
+	}
+	return r.upload, nil
+}
+
+// Parse parses a multipart or urlencoded form body.
+//
+// Files larger than MaxFileSize, and bodies larger than MaxSize, fail with
+// ErrLargeFile. Files of a type not listed in Types, and malformed bodies,
+// fail with ErrInvalidFile. Files saved before a failure are removed.
+func (u *Uploader) Parse(req *Request) (*Upload, error) {
+	body, err := req.body()
+	if err != nil {
+		return nil, err
+	}
+	if req.buf != nil {
+		body = bytes.NewReader(req.buf)
+	}
+	limited := &limitReader{r: body, n: u.MaxSize}
+
+	upload := &Upload{Values: url.Values{}, Files: map[string][]*File{}}
+	mediaType, params, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+
+	switch mediaType {
+	case MediaForm:
+		b, err := io.ReadAll(limited)
+		if err != nil {
+			return nil, limited.wrap(err, "body")
+		}
+		if upload.Values, err = url.ParseQuery(string(b)); err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidInput, err)
+		}
+		return upload, nil
+	case MediaMultipart:
+	default:
+		return nil, fmt.Errorf("%w: unsupported content type %s", ErrInvalidInput, mediaType)
+	}
+
+	if len(params["boundary"]) == 0 {
+		return nil, fmt.Errorf("%w: missing multipart boundary", ErrInvalidFile)
+	}
+
+	mr := multipart.NewReader(limited, params["boundary"])
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF && !limited.over {
+			return upload, nil
+		}
+		if err != nil {
+			upload.Remove(req.Context())
+			return nil, invalid(limited.wrap(err, "body"))
+		}
+
+		if err := u.part(req.Context(), upload, part, limited); err != nil {
+			part.Close()
+			upload.Remove(req.Context())
+			return nil, err
+		}
+		part.Close()
+	}
+}
+
+// part reads one part of a multipart body into the upload.
+func (u *Uploader) part(ctx context.Context, upload *Upload, part *multipart.Part, body *limitReader) error {
+	name := part.FormName()
+	partType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+
+	// values and metadata are kept in memory, so they count against the
+	// file size limit too
+	if len(part.FileName()) == 0 || partType == "application/json" {
+		limited := &limitReader{r: part, n: u.MaxFileSize}
+		b, err := io.ReadAll(limited)
+		if err != nil {
+			return body.wrap(limited.wrap(err, name), "body")
+		}
+		if partType == "application/json" {
+			upload.Metadata = b
+			return nil
+		}
+		upload.Values.Add(name, string(b))
+		return nil
+	}
+
+	// sniff the type from the start of the file
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(part, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return invalid(body.wrap(err, "body"))
+	}
+	head = head[:n]
+
+	f := &File{
+		Field: name,
+		Name:  path.Base(strings.ReplaceAll(part.FileName(), "\\", "/")),
+		Type:  http.DetectContentType(head),
+		store: u.store(),
+	}
+	if !u.allowed(f.Type) {
+		return fmt.Errorf("%w: %s has unsupported type %s", ErrInvalidFile, f.Name, f.Type)
+	}
+
+	limited := &limitReader{r: io.MultiReader(bytes.NewReader(head), part), n: u.MaxFileSize}
+	location, err := f.store.Save(ctx, f.Name, limited)
+	if err != nil {
+		return body.wrap(limited.wrap(err, f.Name), "body")
+	}
+	f.Location = location
+	f.Size = limited.read
+	upload.Files[name] = append(upload.Files[name], f)
+	return nil
+}
+
+// store returns the FileStore of the Uploader.
+func (u *Uploader) store() FileStore {
+	if u.Store == nil {
+		return TempStore{}
+	}
+	return u.Store
+}
+
+// allowed checks a sniffed media type against the allowed Types.
+func (u *Uploader) allowed(contentType string) bool {
+	if len(u.Types) == 0 {
+		return true
+	}
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	for _, t := range u.Types {
+		if strings.EqualFold(t, mediaType) {
+			return true
+		}
+		if strings.HasSuffix(t, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(t, "*")) {
+			return true
+		}
+	}
+	return false
+}
+
+// limitReader reads at most n bytes, failing with ErrLargeFile after that.
+// Limits of zero or less read without a limit.
+type limitReader struct {
+	r    io.Reader
+	n    int64
+	read int64
+	over bool
+}
+
+func (l *limitReader) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	l.read += int64(n)
+	if l.n > 0 && l.read > l.n {
+		l.over = true
+		return n, ErrLargeFile
+	}
+	return n, err
+}
+
+// wrap replaces an error with ErrLargeFile once the limit was passed, since
+// readers such as multipart and FileStores may hide it. Errors of a body
+// limit, such as one set by router.BodyLimit, become ErrLargeBody.
+func (l *limitReader) wrap(err error, name string) error {
+	if l.over {
+		return fmt.Errorf("%w: %s is over %d bytes", ErrLargeFile, name, l.n)
+	}
+	return bodyError(err)
+}
+
+// invalid wraps errors of malformed bodies with ErrInvalidFile, unless they
+// already map to a status code.
+func invalid(err error) error {
+	if _, ok := StatusCode(err); ok {
+		return err
+	}
+	return fmt.Errorf("%w: %s", ErrInvalidFile, err)
+}
+
+// formName returns the name of the form field a struct field binds to.
+func formName(field reflect.StructField) string {
+	for _, key := range []string{"form", "json"} {
+		if tag, ok := field.Tag.Lookup(key); ok {
+			if name := strings.Split(tag, ",")[0]; len(name) > 0 {
+				return name
+			}
+		}
+	}
+	return field.Name
+}
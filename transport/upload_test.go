@@ -0,0 +1,133 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http/httptest"
+	"net/textproto"
+	"strings"
+	"testing"
+)
+
+// png is the signature of a PNG image, enough for it to be sniffed.
+var png = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+type profile struct {
+	Name   string `json:"name"`
+	Age    int    `json:"age"`
+	Avatar *File  `json:"avatar"`
+}
+
+// multipartRequest builds a request with a JSON metadata part and files.
+func multipartRequest(t *testing.T, metadata string, files map[string][]byte) *Request {
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+
+	if len(metadata) > 0 {
+		h := textproto.MIMEHeader{}
+		h.Set("Content-Disposition", `form-data; name="data"`)
+		h.Set("Content-Type", "application/json")
+		part, _ := w.CreatePart(h)
+		part.Write([]byte(metadata))
+	}
+	for name, content := range files {
+		part, err := w.CreateFormFile(name, "../"+name+".png")
+		if err != nil {
+			t.Fatal(err)
+		}
+		part.Write(content)
+	}
+	w.Close()
+
+	r := httptest.NewRequest("POST", "/profiles", &body)
+	r.Header.Set("Content-Type", w.FormDataContentType())
+	return NewRequest(r, nil)
+}
+
+func TestUnmarshalMultipart(t *testing.T) {
+	req := multipartRequest(t, `{"name":"gopher","age":13}`, map[string][]byte{"avatar": png})
+
+	var p profile
+	if err := req.Unmarshal(&p); err != nil {
+		t.Fatal(err)
+	}
+	if p.Name != "gopher" || p.Age != 13 {
+		t.Errorf("expected the metadata to be bound got %+v", p)
+	}
+	if p.Avatar == nil {
+		t.Fatal("expected the avatar to be bound")
+	}
+	defer p.Avatar.Remove(context.Background())
+
+	if p.Avatar.Name != "avatar.png" || p.Avatar.Type != "image/png" || p.Avatar.Size != int64(len(png)) {
+		t.Errorf("unexpected avatar %+v", p.Avatar)
+	}
+	f, err := p.Avatar.Open(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if content, _ := io.ReadAll(f); !bytes.Equal(content, png) {
+		t.Errorf("expected the avatar to be saved got %q", content)
+	}
+}
+
+func TestUploaderLimits(t *testing.T) {
+	u := &Uploader{MaxFileSize: 64, Types: []string{"image/*"}}
+
+	req := multipartRequest(t, "", map[string][]byte{"avatar": append(png, make([]byte, 100)...)})
+	if _, err := u.Parse(req); !errors.Is(err, ErrLargeFile) {
+		t.Errorf("expected %s got %v", ErrLargeFile, err)
+	}
+	if code, _ := StatusCode(ErrLargeFile); code != StatusRequestEntityTooLarge {
+		t.Errorf("expected status 413 got %d", code)
+	}
+
+	req = multipartRequest(t, "", map[string][]byte{"avatar": []byte("#!/bin/sh\necho hi\n")})
+	if _, err := u.Parse(req); !errors.Is(err, ErrInvalidFile) {
+		t.Errorf("expected %s for a file of another type got %v", ErrInvalidFile, err)
+	}
+
+	u = &Uploader{MaxSize: 100}
+	req = multipartRequest(t, "", map[string][]byte{"avatar": png, "banner": png, "icon": png})
+	if _, err := u.Parse(req); !errors.Is(err, ErrLargeFile) {
+		t.Errorf("expected %s for a large body got %v", ErrLargeFile, err)
+	}
+}
+
+func TestUnmarshalForm(t *testing.T) {
+	r := httptest.NewRequest("POST", "/profiles", strings.NewReader("name=gopher&age=13"))
+	r.Header.Set("Content-Type", MediaForm)
+
+	var p profile
+	if err := NewRequest(r, nil).Unmarshal(&p); err != nil {
+		t.Fatal(err)
+	}
+	if p.Name != "gopher" || p.Age != 13 {
+		t.Errorf("expected the form to be bound got %+v", p)
+	}
+
+	r = httptest.NewRequest("POST", "/profiles", strings.NewReader("age=old"))
+	r.Header.Set("Content-Type", MediaForm)
+	if err := NewRequest(r, nil).Unmarshal(&p); !errors.Is(err, ErrInvalidParameter) {
+		t.Errorf("expected %s got %v", ErrInvalidParameter, err)
+	}
+}
+
+func TestUnmarshalFormList(t *testing.T) {
+	r := httptest.NewRequest("POST", "/notes", strings.NewReader("line=a,+b&line=c"))
+	r.Header.Set("Content-Type", MediaForm)
+
+	var n struct {
+		Lines []string `form:"line"`
+	}
+	if err := NewRequest(r, nil).Unmarshal(&n); err != nil {
+		t.Fatal(err)
+	}
+	if len(n.Lines) != 2 || n.Lines[0] != "a, b" || n.Lines[1] != "c" {
+		t.Errorf("expected repeated form values without splitting got %q", n.Lines)
+	}
+}
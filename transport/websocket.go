@@ -0,0 +1,621 @@
+package http
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v2"
+)
+
+// websocketGUID is appended to the key of a handshake to compute its accept
+// value, as RFC 6455 specifies.
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// WebSocket message types.
+const (
+	TextMessage   = 1
+	BinaryMessage = 2
+)
+
+// control and continuation opcodes
+const (
+	continuationFrame = 0
+	closeFrame        = 8
+	pingFrame         = 9
+	pongFrame         = 10
+)
+
+// WebSocket close codes, sent with close frames and returned in CloseError.
+const (
+	CloseNormal          = 1000
+	CloseGoingAway       = 1001
+	CloseProtocolError   = 1002
+	CloseUnsupportedData = 1003
+	CloseNoStatus        = 1005
+	CloseAbnormal        = 1006
+	CloseInvalidPayload  = 1007
+	ClosePolicyViolation = 1008
+	CloseMessageTooBig   = 1009
+	CloseInternalError   = 1011
+	CloseServiceRestart  = 1012
+	CloseTryAgainLater   = 1013
+	CloseBadGateway      = 1014
+)
+
+// DefaultMaxMessageSize is the largest message, in bytes, a WebSocket reads
+// unless SetMaxMessageSize says otherwise.
+const DefaultMaxMessageSize = 1 << 20
+
+// ErrClosedSocket is returned for messages written after a WebSocket was
+// closed.
+var ErrClosedSocket = errors.New("closed socket")
+
+// CloseError is returned by ReadMessage once the connection is closed,
+// either by the client or because it broke the protocol.
+type CloseError struct {
+	Code   int
+	Reason string
+}
+
+func (e *CloseError) Error() string {
+	if len(e.Reason) > 0 {
+		return fmt.Sprintf("websocket closed %d: %s", e.Code, e.Reason)
+	}
+	return fmt.Sprintf("websocket closed %d", e.Code)
+}
+
+// WebSocketHandler handles an upgraded WebSocket connection. The connection
+// is closed once the handler returns.
+type WebSocketHandler func(ws *WebSocket, req *Request)
+
+// Upgrader upgrades HTTP requests to WebSocket connections.
+type Upgrader struct {
+
+	// CheckOrigin decides if a browser request from another origin may
+	// connect. Without it only requests without an Origin header, or from the
+	// same host, are accepted.
+	CheckOrigin func(req *Request) bool
+
+	// Subprotocols the server supports, in order of preference.
+	Subprotocols []string
+}
+
+// DefaultUpgrader is used by Upgrade.
+var DefaultUpgrader = &Upgrader{}
+
+// Upgrade upgrades a request to a WebSocket connection with the
+// DefaultUpgrader.
+func Upgrade(resp ResponseWriter, req *Request) (*WebSocket, error) {
+	return DefaultUpgrader.Upgrade(resp, req)
+}
+
+// Upgrade completes the opening handshake of a WebSocket and takes over the
+// connection of the response. Requests that are not a valid handshake fail
+// with ErrInvalidUpgrade, and requests from a rejected origin fail with
+// ErrInvalidPermission, before anything is written.
+//
+// The connection is hijacked through http.ResponseController, so a
+// ResponseWriter that wraps another, such as in middleware, must implement
+// Hijack or Unwrap() http.ResponseWriter for upgrades to work through it.
+func (u *Upgrader) Upgrade(resp ResponseWriter, req *Request) (*WebSocket, error) {
+	if req.Method != http.MethodGet {
+		return nil, fmt.Errorf("%w: method %s", ErrInvalidUpgrade, req.Method)
+	}
+	if !headerHas(req.Header, "Connection", "upgrade") || !headerHas(req.Header, "Upgrade", "websocket") {
+		return nil, fmt.Errorf("%w: missing upgrade headers", ErrInvalidUpgrade)
+	}
+	if req.Header.Get("Sec-WebSocket-Version") != "13" {
+		resp.Header().Set("Sec-WebSocket-Version", "13")
+		return nil, fmt.Errorf("%w: unsupported version", ErrInvalidUpgrade)
+	}
+	key := req.Header.Get("Sec-WebSocket-Key")
+	if nonce, err := base64.StdEncoding.DecodeString(key); err != nil || len(nonce) != 16 {
+		return nil, fmt.Errorf("%w: invalid key", ErrInvalidUpgrade)
+	}
+
+	check := u.CheckOrigin
+	if check == nil {
+		check = sameOrigin
+	}
+	if !check(req) {
+		return nil, fmt.Errorf("%w: origin %s", ErrInvalidPermission, req.Header.Get("Origin"))
+	}
+
+	conn, brw, err := http.NewResponseController(resp).Hijack()
+	if err != nil {
+		return nil, fmt.Errorf("http: websockets require a response that hijacks: %w", err)
+	}
+
+	// the handshake is done, so the server deadlines no longer apply
+	conn.SetDeadline(time.Time{})
+
+	var b strings.Builder
+	b.WriteString("HTTP/1.1 101 Switching Protocols\r\n")
+	b.WriteString("Upgrade: websocket\r\n")
+	b.WriteString("Connection: Upgrade\r\n")
+	b.WriteString("Sec-WebSocket-Accept: " + acceptKey(key) + "\r\n")
+	protocol := u.subprotocol(req)
+	if len(protocol) > 0 {
+		b.WriteString("Sec-WebSocket-Protocol: " + protocol + "\r\n")
+	}
+	b.WriteString("\r\n")
+
+	if _, err := conn.Write([]byte(b.String())); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.WithoutCancel(req.Context()))
+	return &WebSocket{
+		conn:     conn,
+		r:        brw.Reader,
+		format:   resp.Format(),
+		protocol: protocol,
+		limit:    DefaultMaxMessageSize,
+		ctx:      ctx,
+		cancel:   cancel,
+	}, nil
+}
+
+// subprotocol picks the first of the supported subprotocols the client asked
+// for.
+func (u *Upgrader) subprotocol(req *Request) string {
+	for _, supported := range u.Subprotocols {
+		if headerHas(req.Header, "Sec-WebSocket-Protocol", supported) {
+			return supported
+		}
+	}
+	return ""
+}
+
+// WebSocket is a server side WebSocket connection.
+//
+// A WebSocket supports one reader and any number of writers, so a handler
+// may read in a loop while other goroutines write to it.
+type WebSocket struct {
+	conn     net.Conn
+	r        *bufio.Reader
+	format   string
+	protocol string
+	limit    int64
+
+	// wait is the read deadline of KeepAlive, set while ReadMessage reads
+	wait int64
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// mu serializes writes of frames
+	mu     sync.Mutex
+	closed bool
+}
+
+// Subprotocol returns the subprotocol agreed on in the handshake, if any.
+func (ws *WebSocket) Subprotocol() string {
+	return ws.protocol
+}
+
+// Context returns a context that is done once the connection is closed.
+func (ws *WebSocket) Context() context.Context {
+	return ws.ctx
+}
+
+// Done returns a channel that is closed once the connection is closed.
+func (ws *WebSocket) Done() <-chan struct{} {
+	return ws.ctx.Done()
+}
+
+// SetMaxMessageSize limits the size of the messages read, in bytes. Larger
+// messages close the connection with CloseMessageTooBig. Sizes of zero or
+// less restore DefaultMaxMessageSize.
+func (ws *WebSocket) SetMaxMessageSize(n int64) {
+	if n <= 0 {
+		n = DefaultMaxMessageSize
+	}
+	ws.limit = n
+}
+
+// KeepAlive sends a ping every interval until the connection is closed.
+// Connections that do not answer with a pong, or another frame, within
+// twice the interval are dropped by ReadMessage.
+func (ws *WebSocket) KeepAlive(interval time.Duration) {
+	atomic.StoreInt64(&ws.wait, int64(2*interval))
+	ws.conn.SetReadDeadline(time.Now().Add(2 * interval))
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ws.ctx.Done():
+				return
+			case <-ticker.C:
+				if err := ws.Ping(nil); err != nil {
+					return
+				}
+			}
+		}
+	}()
+}
+
+// ReadMessage reads the next text or binary message. Pings are answered and
+// pongs are consumed along the way. Once the connection is closed, by the
+// client or because it broke the protocol, a *CloseError is returned.
+func (ws *WebSocket) ReadMessage() (int, []byte, error) {
+	var (
+		typ     int
+		message []byte
+	)
+
+	for {
+		fin, op, payload, err := ws.readFrame(int64(len(message)))
+		if err != nil {
+			return 0, nil, err
+		}
+
+		switch op {
+		case pingFrame:
+			if err := ws.writeFrame(pongFrame, payload); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case pongFrame:
+			continue
+		case closeFrame:
+			return 0, nil, ws.closing(payload)
+		case continuationFrame:
+			if typ == 0 {
+				return 0, nil, ws.fail(CloseProtocolError, "unexpected continuation")
+			}
+		case TextMessage, BinaryMessage:
+			if typ != 0 {
+				return 0, nil, ws.fail(CloseProtocolError, "unfinished message")
+			}
+			typ = int(op)
+		default:
+			return 0, nil, ws.fail(CloseProtocolError, "unknown opcode")
+		}
+
+		message = append(message, payload...)
+		if !fin {
+			continue
+		}
+
+		if typ == TextMessage && !utf8.Valid(message) {
+			return 0, nil, ws.fail(CloseInvalidPayload, "invalid utf-8")
+		}
+		return typ, message, nil
+	}
+}
+
+// ReadFormat reads the next message and decodes it in the format of the
+// route, as a Request body would be.
+func (ws *WebSocket) ReadFormat(v interface{}) error {
+	_, message, err := ws.ReadMessage()
+	if err != nil {
+		return err
+	}
+
+	switch ws.format {
+	case "xml":
+		if err := xml.Unmarshal(message, v); err != nil {
+			return fmt.Errorf("%w: %s", ErrInvalidXML, err)
+		}
+	case "yml":
+		if err := yaml.Unmarshal(message, v); err != nil {
+			return fmt.Errorf("%w: %s", ErrInvalidYAML, err)
+		}
+	default:
+		if err := json.Unmarshal(message, v); err != nil {
+			return fmt.Errorf("%w: %s", ErrInvalidJSON, err)
+		}
+	}
+	return nil
+}
+
+// WriteMessage writes a text or binary message in a single frame.
+func (ws *WebSocket) WriteMessage(typ int, data []byte) error {
+	if typ != TextMessage && typ != BinaryMessage {
+		return fmt.Errorf("http: invalid websocket message type %d", typ)
+	}
+	return ws.writeFrame(byte(typ), data)
+}
+
+// WriteFormat encodes v in the format of the route, as a Response would, and
+// writes it as a text message.
+func (ws *WebSocket) WriteFormat(v interface{}) error {
+	var (
+		data []byte
+		err  error
+	)
+
+	switch ws.format {
+	case "xml":
+		data, err = xml.Marshal(v)
+	case "yml":
+		data, err = yaml.Marshal(v)
+	default:
+		data, err = json.Marshal(v)
+	}
+	if err != nil {
+		return err
+	}
+	return ws.writeFrame(TextMessage, data)
+}
+
+// Ping sends a ping, which the client answers with a pong.
+func (ws *WebSocket) Ping(data []byte) error {
+	return ws.writeFrame(pingFrame, data)
+}
+
+// Close closes the connection normally.
+func (ws *WebSocket) Close() error {
+	return ws.CloseWith(CloseNormal, "")
+}
+
+// CloseWith sends a close frame with a code and reason, then closes the
+// connection. Closing a closed WebSocket does nothing.
+func (ws *WebSocket) CloseWith(code int, reason string) error {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	if ws.closed {
+		return nil
+	}
+	return ws.shutdown(closePayload(code, reason))
+}
+
+// shutdown writes a close frame and closes the connection. The caller must
+// hold mu.
+func (ws *WebSocket) shutdown(payload []byte) error {
+	ws.closed = true
+	ws.cancel()
+
+	ws.conn.SetWriteDeadline(time.Now().Add(time.Second))
+	_, err := ws.conn.Write(frame(closeFrame, payload))
+	if cerr := ws.conn.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// closing answers a close frame from the client by echoing its code, and
+// returns the CloseError for it.
+func (ws *WebSocket) closing(payload []byte) error {
+	e := &CloseError{Code: CloseNoStatus}
+
+	switch {
+	case len(payload) == 1:
+		return ws.fail(CloseProtocolError, "invalid close frame")
+	case len(payload) >= 2:
+		e.Code = int(binary.BigEndian.Uint16(payload))
+		e.Reason = string(payload[2:])
+		if !validCloseCode(e.Code) {
+			return ws.fail(CloseProtocolError, "invalid close code")
+		}
+		if !utf8.ValidString(e.Reason) {
+			return ws.fail(CloseInvalidPayload, "invalid utf-8")
+		}
+	}
+
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	if !ws.closed {
+		echo := []byte{}
+		if e.Code != CloseNoStatus {
+			echo = closePayload(e.Code, "")
+		}
+		ws.shutdown(echo)
+	}
+	return e
+}
+
+// fail closes the connection with a code after the client broke the
+// protocol, and returns the CloseError for it.
+func (ws *WebSocket) fail(code int, reason string) error {
+	ws.CloseWith(code, reason)
+	return &CloseError{Code: code, Reason: reason}
+}
+
+// readFrame reads a single frame, unmasking its payload. read is the size of
+// the message read so far, for the message size limit.
+func (ws *WebSocket) readFrame(read int64) (bool, byte, []byte, error) {
+	var head [2]byte
+	if _, err := io.ReadFull(ws.r, head[:]); err != nil {
+		return false, 0, nil, ws.broken(err)
+	}
+	if wait := time.Duration(atomic.LoadInt64(&ws.wait)); wait > 0 {
+		ws.conn.SetReadDeadline(time.Now().Add(wait))
+	}
+
+	fin := head[0]&0x80 != 0
+	op := head[0] & 0x0f
+	if head[0]&0x70 != 0 {
+		return false, 0, nil, ws.fail(CloseProtocolError, "reserved bits set")
+	}
+	if head[1]&0x80 == 0 {
+		return false, 0, nil, ws.fail(CloseProtocolError, "unmasked frame")
+	}
+
+	length := int64(head[1] & 0x7f)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(ws.r, ext[:]); err != nil {
+			return false, 0, nil, ws.broken(err)
+		}
+		length = int64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(ws.r, ext[:]); err != nil {
+			return false, 0, nil, ws.broken(err)
+		}
+		n := binary.BigEndian.Uint64(ext[:])
+		if n > 1<<63-1 {
+			return false, 0, nil, ws.fail(CloseProtocolError, "invalid length")
+		}
+		length = int64(n)
+	}
+
+	if op >= closeFrame {
+		if !fin || length > 125 {
+			return false, 0, nil, ws.fail(CloseProtocolError, "invalid control frame")
+		}
+	} else if read+length > ws.maxMessageSize() {
+		return false, 0, nil, ws.fail(CloseMessageTooBig, "message too big")
+	}
+
+	var mask [4]byte
+	if _, err := io.ReadFull(ws.r, mask[:]); err != nil {
+		return false, 0, nil, ws.broken(err)
+	}
+
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(ws.r, payload); err != nil {
+		return false, 0, nil, ws.broken(err)
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return fin, op, payload, nil
+}
+
+// broken closes a connection that failed without a close frame, and returns
+// the CloseError for it.
+func (ws *WebSocket) broken(err error) error {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	if ws.closed {
+		return &CloseError{Code: CloseNormal}
+	}
+	ws.closed = true
+	ws.cancel()
+	ws.conn.Close()
+	return &CloseError{Code: CloseAbnormal, Reason: err.Error()}
+}
+
+// writeFrame writes a single unmasked frame, as servers must.
+func (ws *WebSocket) writeFrame(op byte, payload []byte) error {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	if ws.closed {
+		return ErrClosedSocket
+	}
+	if _, err := ws.conn.Write(frame(op, payload)); err != nil {
+		ws.closed = true
+		ws.cancel()
+		ws.conn.Close()
+		return fmt.Errorf("%w: %s", ErrClosedSocket, err)
+	}
+	return nil
+}
+
+// frame encodes a final, unmasked frame.
+func frame(op byte, payload []byte) []byte {
+	var b bytes.Buffer
+	b.WriteByte(0x80 | op)
+
+	switch n := len(payload); {
+	case n <= 125:
+		b.WriteByte(byte(n))
+	case n <= 0xffff:
+		b.WriteByte(126)
+		binary.Write(&b, binary.BigEndian, uint16(n))
+	default:
+		b.WriteByte(127)
+		binary.Write(&b, binary.BigEndian, uint64(n))
+	}
+
+	b.Write(payload)
+	return b.Bytes()
+}
+
+// closePayload encodes the code and reason of a close frame. Reasons are
+// cut at a rune boundary to fit the 125 byte limit of control frames, so they
+// stay valid UTF-8.
+func closePayload(code int, reason string) []byte {
+	if len(reason) > 123 {
+		n := 123
+		for n > 0 && !utf8.RuneStart(reason[n]) {
+			n--
+		}
+		reason = reason[:n]
+	}
+	payload := make([]byte, 2, 2+len(reason))
+	binary.BigEndian.PutUint16(payload, uint16(code))
+	return append(payload, reason...)
+}
+
+// maxMessageSize returns the size limit of messages, which is the default
+// for a WebSocket that was not created by Upgrade.
+func (ws *WebSocket) maxMessageSize() int64 {
+	if ws.limit <= 0 {
+		return DefaultMaxMessageSize
+	}
+	return ws.limit
+}
+
+// validCloseCode reports whether a client may send a close code.
+func validCloseCode(code int) bool {
+	switch {
+	case code >= 3000 && code <= 4999:
+		return true
+	case code >= 1000 && code <= CloseBadGateway:
+		return code != 1004 && code != CloseNoStatus && code != CloseAbnormal
+	}
+	return false
+}
+
+// acceptKey computes the Sec-WebSocket-Accept value for a key.
+func acceptKey(key string) string {
+	sum := sha1.Sum([]byte(key + websocketGUID))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// headerHas reports whether a comma separated header holds a token,
+// ignoring case.
+func headerHas(h http.Header, name, token string) bool {
+	for _, v := range h.Values(name) {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// sameOrigin accepts requests without an Origin header, which do not come
+// from browsers, and those whose origin is the host they connect to.
+func sameOrigin(req *Request) bool {
+	origin := req.Header.Get("Origin")
+	if len(origin) == 0 {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Host, req.Host)
+}
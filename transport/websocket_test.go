@@ -0,0 +1,256 @@
+package http
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+// wsClient is a minimal WebSocket client that masks its frames.
+type wsClient struct {
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+// dial opens a WebSocket connection to a test server.
+func dial(t *testing.T, url string) *wsClient {
+	conn, err := net.Dial("tcp", strings.TrimPrefix(url, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Write([]byte("GET /socket HTTP/1.1\r\nHost: " + strings.TrimPrefix(url, "http://") + "\r\n" +
+		"Upgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Version: 13\r\n" +
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n\r\n"))
+
+	r := bufio.NewReader(conn)
+	res, err := http.ReadResponse(r, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("expected status 101 got %d", res.StatusCode)
+	}
+	if accept := res.Header.Get("Sec-WebSocket-Accept"); accept != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("expected the RFC 6455 accept value got %s", accept)
+	}
+	return &wsClient{conn: conn, r: r}
+}
+
+// send writes a masked frame.
+func (c *wsClient) send(fin bool, op byte, payload []byte) {
+	head := []byte{op, 0x80}
+	if fin {
+		head[0] |= 0x80
+	}
+	if len(payload) <= 125 {
+		head[1] |= byte(len(payload))
+	} else {
+		head[1] |= 126
+		head = binary.BigEndian.AppendUint16(head, uint16(len(payload)))
+	}
+	mask := []byte{1, 2, 3, 4}
+	masked := make([]byte, len(payload))
+	for i := range payload {
+		masked[i] = payload[i] ^ mask[i%4]
+	}
+	c.conn.Write(append(append(head, mask...), masked...))
+}
+
+// receive reads an unmasked frame.
+func (c *wsClient) receive(t *testing.T) (byte, []byte) {
+	var head [2]byte
+	if _, err := io.ReadFull(c.r, head[:]); err != nil {
+		t.Fatal(err)
+	}
+	payload := make([]byte, head[1]&0x7f)
+	io.ReadFull(c.r, payload)
+	return head[0] & 0x0f, payload
+}
+
+func serveWebSocket(h WebSocketHandler, format string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		resp, req := NewResponse(w, format), NewRequest(r, nil)
+		ws, err := Upgrade(resp, req)
+		if err != nil {
+			resp.WriteErrs(req, err)
+			return
+		}
+		defer ws.Close()
+		h(ws, req)
+	}))
+}
+
+func TestWebSocketEcho(t *testing.T) {
+	server := serveWebSocket(func(ws *WebSocket, req *Request) {
+		for {
+			var v struct {
+				Name string `json:"name"`
+			}
+			if err := ws.ReadFormat(&v); err != nil {
+				return
+			}
+			ws.WriteFormat(map[string]string{"hello": v.Name})
+		}
+	}, "json")
+	defer server.Close()
+
+	c := dial(t, server.URL)
+	defer c.conn.Close()
+
+	// a fragmented message with a ping between its frames
+	c.send(false, TextMessage, []byte(`{"name":`))
+	c.send(true, pingFrame, []byte("are you there"))
+	c.send(true, continuationFrame, []byte(`"gopher"}`))
+
+	if op, payload := c.receive(t); op != pongFrame || string(payload) != "are you there" {
+		t.Errorf("expected a pong with the ping payload got %d %q", op, payload)
+	}
+	if op, payload := c.receive(t); op != TextMessage || string(payload) != `{"hello":"gopher"}` {
+		t.Errorf("expected the echoed message got %d %q", op, payload)
+	}
+
+	c.send(true, closeFrame, closePayload(CloseGoingAway, "bye"))
+	op, payload := c.receive(t)
+	if op != closeFrame || binary.BigEndian.Uint16(payload) != CloseGoingAway {
+		t.Errorf("expected the close code to be echoed got %d %v", op, payload)
+	}
+}
+
+func TestWebSocketClose(t *testing.T) {
+	tests := []struct {
+		name string
+		send func(c *wsClient)
+		code uint16
+	}{
+		{"message too big", func(c *wsClient) { c.send(true, TextMessage, make([]byte, 200)) }, CloseMessageTooBig},
+		{"invalid utf-8", func(c *wsClient) { c.send(true, TextMessage, []byte{0xff, 0xfe}) }, CloseInvalidPayload},
+		{"unexpected continuation", func(c *wsClient) { c.send(true, continuationFrame, []byte("x")) }, CloseProtocolError},
+		{"unmasked frame", func(c *wsClient) { c.conn.Write([]byte{0x81, 0x01, 'x'}) }, CloseProtocolError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := make(chan error, 1)
+			server := serveWebSocket(func(ws *WebSocket, req *Request) {
+				ws.SetMaxMessageSize(100)
+				_, _, err := ws.ReadMessage()
+				errs <- err
+			}, "json")
+			defer server.Close()
+
+			c := dial(t, server.URL)
+			defer c.conn.Close()
+			tt.send(c)
+
+			op, payload := c.receive(t)
+			if op != closeFrame || binary.BigEndian.Uint16(payload) != tt.code {
+				t.Errorf("expected close code %d got %d %v", tt.code, op, payload)
+			}
+
+			var closed *CloseError
+			if err := <-errs; !errors.As(err, &closed) || closed.Code != int(tt.code) {
+				t.Errorf("expected a close error with code %d got %v", tt.code, err)
+			}
+		})
+	}
+}
+
+func TestWebSocketInvalidUpgrade(t *testing.T) {
+	server := serveWebSocket(func(ws *WebSocket, req *Request) {}, "json")
+	defer server.Close()
+
+	res, err := http.Get(server.URL + "/socket")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected status 400 got %d", res.StatusCode)
+	}
+
+	req, _ := http.NewRequest("GET", server.URL+"/socket", nil)
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	req.Header.Set("Origin", "http://evil.example.com")
+	res, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusForbidden {
+		t.Errorf("expected status 403 for another origin got %d", res.StatusCode)
+	}
+}
+
+func TestWebSocketClosePayload(t *testing.T) {
+	// 61 two byte runes do not fit the 123 bytes left for the reason
+	payload := closePayload(CloseNormal, strings.Repeat("é", 62))
+	if len(payload) != 2+122 || !utf8.Valid(payload[2:]) {
+		t.Errorf("expected the reason cut to 122 bytes of valid utf-8 got %d bytes", len(payload)-2)
+	}
+}
+
+func TestWebSocketKeepAlive(t *testing.T) {
+	read := make(chan struct{})
+	server := serveWebSocket(func(ws *WebSocket, req *Request) {
+		go func() {
+			<-read
+			ws.KeepAlive(time.Second)
+		}()
+		close(read)
+		ws.ReadMessage()
+	}, "json")
+	defer server.Close()
+
+	c := dial(t, server.URL)
+	defer c.conn.Close()
+	c.send(true, TextMessage, []byte("x"))
+	c.send(true, closeFrame, closePayload(CloseNormal, ""))
+}
+
+func TestWebSocketMaxMessageSize(t *testing.T) {
+	for _, n := range []int64{0, -1} {
+		errs := make(chan error, 1)
+		server := serveWebSocket(func(ws *WebSocket, req *Request) {
+			ws.SetMaxMessageSize(n)
+			_, _, err := ws.ReadMessage()
+			errs <- err
+		}, "json")
+
+		c := dial(t, server.URL)
+		// the length is checked before the payload is read
+		head := binary.BigEndian.AppendUint64([]byte{0x80 | BinaryMessage, 0x80 | 127}, DefaultMaxMessageSize+1)
+		c.conn.Write(append(head, 1, 2, 3, 4))
+		op, payload := c.receive(t)
+		if op != closeFrame || binary.BigEndian.Uint16(payload) != CloseMessageTooBig {
+			t.Errorf("%d: expected the default limit got %d %v", n, op, payload)
+		}
+		if err := <-errs; err == nil {
+			t.Errorf("%d: expected the message to be rejected", n)
+		}
+		c.conn.Close()
+		server.Close()
+	}
+}
+
+func TestValidCloseCode(t *testing.T) {
+	for code, valid := range map[int]bool{
+		999: false, CloseNormal: true, 1004: false, CloseNoStatus: false, CloseAbnormal: false,
+		CloseInternalError: true, CloseServiceRestart: true, CloseTryAgainLater: true, CloseBadGateway: true,
+		1015: false, 2999: false, 3000: true, 4999: true, 5000: false,
+	} {
+		if validCloseCode(code) != valid {
+			t.Errorf("%d: expected valid %t", code, valid)
+		}
+	}
+}